@@ -16,7 +16,7 @@ func Run() {
 	newCtx, cancel := context.WithCancel(context.Background())
 
 	go func(cancel context.CancelFunc) {
-		sig := make(chan os.Signal)
+		sig := make(chan os.Signal, 1)
 		signal.Notify(sig, os.Interrupt, os.Kill)
 		<-sig
 		cancel()
@@ -5,7 +5,9 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Mode string
@@ -18,48 +20,321 @@ const (
 	BlocksFetcher Mode = "blocks-fetcher"
 	LongSender    Mode = "long-sender"
 	TxInfo        Mode = "tx-info"
+	FindMaxTPS    Mode = "find-max-tps"
+	BlockWatch    Mode = "block-watch"
+	Compare       Mode = "compare"
 )
 
+type Workload string
+
+func (w Workload) String() string {
+	return string(w)
+}
+
+const (
+	EOAWorkload    Workload = "eoa"
+	ERC20Workload  Workload = "erc20"
+	ERC721Workload Workload = "erc721"
+)
+
+type TxType string
+
+func (t TxType) String() string {
+	return string(t)
+}
+
+const (
+	LegacyTxType     TxType = "legacy"
+	AccessListTxType TxType = "access-list"
+	DynamicFeeTxType TxType = "dynamic"
+)
+
+type LoadProfile string
+
+func (p LoadProfile) String() string {
+	return string(p)
+}
+
+const (
+	FlatProfile  LoadProfile = "flat"
+	RampProfile  LoadProfile = "ramp"
+	StepProfile  LoadProfile = "step"
+	SpikeProfile LoadProfile = "spike"
+	SineProfile  LoadProfile = "sine"
+)
+
+type Balance string
+
+func (b Balance) String() string {
+	return string(b)
+}
+
+const (
+	RoundRobinBalance Balance = "round-robin"
+	WeightedBalance   Balance = "weighted"
+	StickyBalance     Balance = "sticky"
+)
+
+type Finality string
+
+func (f Finality) String() string {
+	return string(f)
+}
+
+const (
+	DepthFinality     Finality = "depth"
+	SafeFinality      Finality = "safe"
+	FinalizedFinality Finality = "finalized"
+)
+
+type Output string
+
+func (o Output) String() string {
+	return string(o)
+}
+
+const (
+	TableOutput Output = "table"
+	JSONOutput  Output = "json"
+	CSVOutput   Output = "csv"
+)
+
+var supportedOutputs = map[Output]struct{}{
+	TableOutput: {},
+	JSONOutput:  {},
+	CSVOutput:   {},
+}
+
+var supportedFinalities = map[Finality]struct{}{
+	DepthFinality:     {},
+	SafeFinality:      {},
+	FinalizedFinality: {},
+}
+
+var supportedBalances = map[Balance]struct{}{
+	RoundRobinBalance: {},
+	WeightedBalance:   {},
+	StickyBalance:     {},
+}
+
+var supportedLoadProfiles = map[LoadProfile]struct{}{
+	FlatProfile:  {},
+	RampProfile:  {},
+	StepProfile:  {},
+	SpikeProfile: {},
+	SineProfile:  {},
+}
+
+var supportedTxTypes = map[TxType]struct{}{
+	LegacyTxType:     {},
+	AccessListTxType: {},
+	DynamicFeeTxType: {},
+}
+
+var supportedWorkloads = map[Workload]struct{}{
+	EOAWorkload:    {},
+	ERC20Workload:  {},
+	ERC721Workload: {},
+}
+
 type Conf struct {
 	Mode Mode
 
 	JsonRPC  string
 	Blocks   Blocks
+	Fetch    Fetch
 	LogLevel string
 
+	Endpoints []Endpoint
+	Balance   Balance
+
 	PrivateKey    string
 	Mnemonic      string
 	TotalAccounts int
 	ToAddress     string
 
+	Workload   Workload
+	ERC20Token string
+	ERC20Mint  int64
+	ERC721Mint int64
+
+	TxType           TxType
+	FeeRefreshSec    int64
+	CreateAccessList bool
+
 	TxPerSec         int64
 	TxSendInterval   int64
 	TxSendTimeoutMin int64
 	IncludeTPSReport bool
+	Workers          int
+	Presign          int
+	PresignDir       string
+	BatchSize        int
+	InclusionWaitSec int64
+
+	Search Search
+
+	LoadProfile      LoadProfile
+	BaseTxPerSec     int64
+	StepTxPerSec     int64
+	ProfilePeriodSec int64
+	SpikeSec         int64
 
 	WaitForConfirm        bool
 	WaitForConfirmTimeout int64
+	ConfirmDepth          int64
+	Finality              Finality
+	StuckTimeoutSec       int64
 
 	TxHashes    []string
 	TxCostInEth bool
 
+	Output       Output
+	OutputFile   string
+	HTMLReport   string
+	ResultsFile  string
+	TPSWindowSec int64
+	// Breakdown reports the senders, the recipients, the transaction types and the gas per contract of the blocks
+	Breakdown    bool
+	BreakdownTop int
+
+	WatchWindowsSec []int64
+	WatchRows       int
+
+	Comparison Comparison
+
 	StartingNonce *int64
 
 	MetricsPort string
+
+	Scenario string
+	Phases   []Phase
+}
+
+// Endpoint is a JSON-RPC node the long-sender and find-max-tps transactions are sent to
+type Endpoint struct {
+	URL    string
+	Weight int
+}
+
+// Phase is a single phase of a scenario, with its own complete configuration
+type Phase struct {
+	Name string
+	Conf Conf
 }
 
+// Search holds the settings of the maximum sustainable TPS search
+type Search struct {
+	MaxTxPerSec      int64
+	Precision        int64
+	SoakSec          int64
+	CooldownSec      int64
+	MaxLatencySec    int64
+	MaxErrorRate     float64
+	MaxPendingGrowth float64
+}
+
+// Fetch holds the settings of the blocks fetching
+type Fetch struct {
+	// Workers is the number of concurrent block requests
+	Workers int
+	// BatchSize is the number of blocks fetched in a single JSON-RPC batch request, batching is disabled if lower than 2
+	BatchSize int
+	// Retries is the number of times a failed block request is retried, with an exponential backoff
+	Retries int
+	// CacheDir is the directory of the on-disk block cache, the blocks are not cached if not set
+	CacheDir string
+	// CacheDepth is the number of blocks below the chain head after which a block is cached
+	CacheDepth int64
+}
+
+// Comparison holds the settings of the compare mode, comparing two saved run results
+type Comparison struct {
+	// Baseline and Candidate are the results files of the runs, the candidate is compared to the baseline
+	Baseline  string
+	Candidate string
+	// TPSTolerancePct is the drop of a throughput metric, in percent of the baseline, tolerated before it is a regression
+	TPSTolerancePct float64
+	// LatencyTolerancePct is the rise of a latency or the block time, in percent of the baseline, tolerated before
+	// it is a regression
+	LatencyTolerancePct float64
+	// ErrorTolerancePct is the rise of an error rate, in percentage points, tolerated before it is a regression
+	ErrorTolerancePct float64
+}
+
+// DefaultFetchWorkers is the number of concurrent block requests, if not set otherwise
+const DefaultFetchWorkers = 16
+
+// DefaultWorkers is the number of workers sending the scheduled transactions, if not set otherwise
+const DefaultWorkers = 100
+
+// DefaultWatchRows is the number of the latest blocks in the block-watch table, if not set otherwise
+const DefaultWatchRows = 20
+
+// DefaultBreakdownTop is the number of the top senders and recipients of the blocks breakdown, if not set otherwise
+const DefaultBreakdownTop = 10
+
+// DefaultTPSWindowSec is the window of the rolling TPS of the blocks report, if not set otherwise
+const DefaultTPSWindowSec = 10
+
 type Blocks struct {
 	Start int64
 	End   int64
 	Range int64
+
+	// Since, Until and Last select the blocks by their timestamps, instead of the block numbers.
+	// Until is the latest block if not set, and Last is the time before the latest block
+	Since time.Time
+	Until time.Time
+	Last  time.Duration
 }
 
 var (
 	ErrJsonRPCNotDefined            = errors.New("json-rpc endpoint not defined")
-	ErrEndBlockNotDefined           = errors.New("end block or block range not defined")
+	ErrEndBlockNotDefined           = errors.New("end block, block range or time window not defined")
+	ErrTimeNotSupported             = errors.New("since and until must be RFC3339 timestamps or unix seconds")
+	ErrTimeWindowInvalid            = errors.New("since must be before until, and last must be greater than zero")
+	ErrTimeWindowConflict           = errors.New("last can not be combined with since")
+	ErrFetchWorkersNegative         = errors.New("number of fetch workers must not be negative")
+	ErrFetchBatchSizeNegative       = errors.New("fetch batch size must not be negative")
+	ErrFetchRetriesNegative         = errors.New("number of fetch retries must not be negative")
+	ErrCacheDepthNegative           = errors.New("block cache depth must not be negative")
+	ErrBreakdownTopNegative         = errors.New("number of the top breakdown entries must not be negative")
 	ErrToAddrNotProvided            = errors.New("to address not provided")
 	ErrPrivKeyOrMnemonicNotProvided = errors.New("private key or mnemonic not provided")
 	ErrTxHashNotProvided            = errors.New("transaction hash must be provided")
+	ErrWorkloadNotSupported         = errors.New("workload not supported")
+	ErrERC721MintNotPositive        = errors.New("number of minted NFTs must be greater than zero")
+	ErrTxTypeNotSupported           = errors.New("transaction type not supported")
+	ErrLoadProfileNotSupported      = errors.New("load profile not supported")
+	ErrBaseTxPerSecNotLower         = errors.New("base tps of the load profile must be lower than tps")
+	ErrProfilePeriodNotPositive     = errors.New("load profile period must be greater than zero")
+	ErrStepTxPerSecNotPositive      = errors.New("step tps must be greater than zero")
+	ErrSpikeNotShorterThanPeriod    = errors.New("spike duration must be greater than zero and shorter than the profile period")
+	ErrWorkersNegative              = errors.New("number of workers must not be negative")
+	ErrPresignNegative              = errors.New("number of pre-signed transactions must not be negative")
+	ErrPresignDynamicFee            = errors.New("dynamic fee transactions can not be pre-signed, their fee caps would fall behind the base fee")
+	ErrBatchSizeNegative            = errors.New("batch size must not be negative")
+	ErrInclusionWaitNegative        = errors.New("inclusion wait must not be negative")
+	ErrConfirmDepthNegative         = errors.New("confirmation depth must not be negative")
+	ErrFinalityNotSupported         = errors.New("finality not supported")
+	ErrStuckTimeoutNegative         = errors.New("stuck nonce timeout must not be negative")
+	ErrOutputNotSupported           = errors.New("output format not supported")
+	ErrTPSWindowNegative            = errors.New("rolling tps window must not be negative")
+	ErrWatchWindowsInvalid          = errors.New("watch windows must be positive integers")
+	ErrWatchRowsNegative            = errors.New("number of watched rows must not be negative")
+	ErrCompareResultsNotDefined     = errors.New("baseline and candidate results not defined")
+	ErrToleranceNegative            = errors.New("regression tolerance must not be negative")
+	ErrEndpointNotDefined           = errors.New("endpoints must not contain an empty endpoint")
+	ErrEndpointWeightsInvalid       = errors.New("endpoint weights must be positive integers, one for each endpoint")
+	ErrBalanceNotSupported          = errors.New("endpoint balance strategy not supported")
+	ErrSearchRangeInvalid           = errors.New("max tps must not be lower than the starting tps, which must be greater than zero")
+	ErrSearchPrecisionNotPositive   = errors.New("search precision must be greater than zero")
+	ErrSoakNotPositive              = errors.New("soak window must be greater than zero")
+	ErrScenarioNotLoaded            = errors.New("could not load scenario file")
+	ErrScenarioFieldNotSupported    = errors.New("scenario field not supported")
+	ErrScenarioPhasesNotSupported   = errors.New("scenario phases are only supported by the long-sender mode")
+	ErrPhaseDurationNotDefined      = errors.New("only the last scenario phase can run indefinitely")
 )
 
 type rawConf struct {
@@ -70,25 +345,88 @@ type rawConf struct {
 	blockStart int64
 	blockEnd   int64
 	blockRange int64
+	since      string
+	until      string
+	last       time.Duration
+
+	fetchWorkers   int
+	fetchBatchSize int
+	fetchRetries   int
+	cacheDir       string
+	cacheDepth     int64
 
 	privKey       string
 	mnemonic      string
 	totalAccounts int
 	toAddr        string
 
+	workload   string
+	erc20Token string
+	erc20Mint  int64
+	erc721Mint int64
+
+	txType           string
+	feeRefreshSec    int64
+	createAccessList bool
+
 	txPerSec         int64
 	txSendTimeoutMin int64
 	txSendInterval   int64
 	includeTpsReport bool
+	workers          int
+	presign          int
+	presignDir       string
+	batchSize        int
+	inclusionWaitSec int64
+
+	endpoints       string
+	endpointWeights string
+	balance         string
+
+	maxTxPerSec      int64
+	searchPrecision  int64
+	soakSec          int64
+	cooldownSec      int64
+	maxLatencySec    int64
+	maxErrorRate     float64
+	maxPendingGrowth float64
+
+	loadProfile      string
+	baseTxPerSec     int64
+	stepTxPerSec     int64
+	profilePeriodSec int64
+	spikeSec         int64
 
 	waitForConfirm        bool
 	waitForConfirmTimeout int64
+	confirmDepth          int64
+	finality              string
+	stuckTimeoutSec       int64
 
 	txHash      string
 	txHashes    []string
 	txCostInEth bool
+	output      string
+	outputFile  string
+	htmlReport  string
+	resultsFile string
+	tpsWindow   int64
+
+	breakdown    bool
+	breakdownTop int
+
+	watchWindows string
+	watchRows    int
+
+	baseline         string
+	candidate        string
+	tpsTolerance     float64
+	latencyTolerance float64
+	errorTolerance   float64
 
 	metricsPort string
+
+	scenario string
 }
 
 func New() (Conf, error) {
@@ -104,74 +442,275 @@ func New() (Conf, error) {
 }
 
 func (c *rawConf) getConfig(test bool) (Conf, error) {
-	flag.StringVar(&c.logLevel, "log-level", "info", "log output level")
-	flag.StringVar(&c.jsonRpc, "json-rpc", "", "JSON-RPC or WS endpoint")
-	flag.Int64Var(&c.blockStart, "block-start", 1, "the start block range")
-	flag.Int64Var(&c.blockEnd, "block-end", 0, "the end block range")
-	flag.Int64Var(&c.blockRange, "block-range", 0, "the range of blocks to fetch from latest")
-	flag.StringVar(&c.privKey, "pk", "", "the private key for the sender account")
-	flag.StringVar(&c.toAddr, "to", "", "address to which the funds will be sent")
-	flag.Int64Var(&c.txPerSec, "tps", 100, "the number of transactions per second to send")
-	flag.Int64Var(&c.txSendInterval, "tx-sec", 1, "the number of seconds to wait between sending transactions")
-	flag.Int64Var(&c.txSendTimeoutMin, "duration", 60, "the number of minutes after witch to stop the send")
-	flag.BoolVar(&c.includeTpsReport, "report", false, "set to true to include tps report after the long-sender node")
-	flag.BoolVar(&c.waitForConfirm, "confirm", false, "wait for transactions to be confirmed")
-	flag.Int64Var(&c.waitForConfirmTimeout, "confirm-timeout", 10, "wait for tx confirmation timeout in minutes")
-	flag.StringVar(&c.mnemonic, "mnemonic", "", "mnemonic string to derive accounts from")
-	flag.IntVar(&c.totalAccounts, "mnemonic-addr", 1, "total number of account to send transactions from")
-	flag.StringVar(&c.txHash, "tx-hashes", "", "comma delimited transaction hashes to get details for")
-	flag.BoolVar(&c.txCostInEth, "tx-cost-eth", false, "present transaction costs in wei instead of eth")
-	flag.StringVar(&c.metricsPort, "metrics-port", "3000", "port where the prometheus metrics will be exposed")
-	flag.StringVar(
-		&c.mode,
-		"mode",
-		BlocksFetcher.String(),
-		fmt.Sprintf("mode of operation (%s, %s)", BlocksFetcher.String(), LongSender.String()),
-	)
+	c.registerFlags(flag.CommandLine)
 	flag.Parse()
 
+	var (
+		phases []rawPhase
+		err    error
+	)
+
+	if c.scenario != "" {
+		phases, err = c.applyScenario(flag.CommandLine)
+		if err != nil {
+			return Conf{}, err
+		}
+	}
+
 	if !test {
-		if err := c.validateRawFlags(); err != nil {
+		if err = c.validateRawFlags(); err != nil {
+			return Conf{}, err
+		}
+
+		if err = c.validatePhases(phases); err != nil {
 			return Conf{}, err
 		}
 	}
 
 	c.processFlags()
 
+	conf := c.toConf()
+
+	for _, phase := range phases {
+		phase.conf.processFlags()
+		conf.Phases = append(conf.Phases, Phase{
+			Name: phase.name,
+			Conf: phase.conf.toConf(),
+		})
+	}
+
+	return conf, nil
+}
+
+// registerFlags binds all configuration flags to the provided flag set
+func (c *rawConf) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.logLevel, "log-level", "info", "log output level")
+	fs.StringVar(&c.jsonRpc, "json-rpc", "", "JSON-RPC or WS endpoint")
+	fs.Int64Var(&c.blockStart, "block-start", 1, "the start block range")
+	fs.Int64Var(&c.blockEnd, "block-end", 0, "the end block range")
+	fs.Int64Var(&c.blockRange, "block-range", 0, "the range of blocks to fetch from latest")
+	fs.StringVar(&c.since, "since", "", "fetch the blocks built at or after the time, as an RFC3339 timestamp or unix seconds, the first block if not set")
+	fs.StringVar(&c.until, "until", "", "fetch the blocks built at or before the time, as an RFC3339 timestamp or unix seconds, the latest block if not set")
+	fs.DurationVar(&c.last, "last", 0, "fetch the blocks built within the duration before until, or before the latest block, like 30m or 2h")
+	fs.IntVar(&c.fetchWorkers, "fetch-workers", DefaultFetchWorkers, "the number of concurrent block requests")
+	fs.IntVar(&c.fetchBatchSize, "fetch-batch", 100, "the number of blocks fetched in a single JSON-RPC batch request, batching is disabled if lower than 2")
+	fs.IntVar(&c.fetchRetries, "fetch-retries", 3, "the number of times a failed block request is retried, with an exponential backoff")
+	fs.StringVar(&c.cacheDir, "cache-dir", "", "directory of the on-disk block cache, the blocks are not cached if not set")
+	fs.Int64Var(&c.cacheDepth, "cache-depth", 64, "the number of blocks below the chain head after which a block is cached, as it can not be replaced by a reorg")
+	fs.StringVar(&c.privKey, "pk", "", "the private key for the sender account")
+	fs.StringVar(&c.toAddr, "to", "", "address to which the funds will be sent")
+	fs.StringVar(
+		&c.workload,
+		"workload",
+		EOAWorkload.String(),
+		fmt.Sprintf(
+			"type of transactions the long-sender will send (%s, %s, %s)",
+			EOAWorkload.String(), ERC20Workload.String(), ERC721Workload.String(),
+		),
+	)
+	fs.StringVar(&c.erc20Token, "erc20-token", "", "address of an existing ERC20 token, held by the first sender account, to use instead of deploying one")
+	fs.Int64Var(&c.erc20Mint, "erc20-mint", 1000000, "the number of tokens minted to each sender account by the erc20 workload")
+	fs.Int64Var(&c.erc721Mint, "erc721-mint", 100, "the minimum number of NFTs minted to each sender account by the erc721 workload, more are minted if a round of transfers would last less than 20 seconds")
+	fs.StringVar(
+		&c.txType,
+		"tx-type",
+		LegacyTxType.String(),
+		fmt.Sprintf(
+			"type of transactions to sign (%s, %s, %s)",
+			LegacyTxType.String(), AccessListTxType.String(), DynamicFeeTxType.String(),
+		),
+	)
+	fs.BoolVar(&c.createAccessList, "create-access-list", false, "populate the access list of contract calls with eth_createAccessList")
+	fs.Int64Var(&c.feeRefreshSec, "fee-refresh", 10, "the number of seconds after which the dynamic transaction fees are refreshed")
+	fs.Int64Var(&c.txPerSec, "tps", 100, "the number of transactions per second to send")
+	fs.Int64Var(&c.txSendInterval, "tx-sec", 1, "the number of seconds to wait between sending transactions")
+	fs.StringVar(
+		&c.loadProfile,
+		"profile",
+		FlatProfile.String(),
+		fmt.Sprintf(
+			"load profile of the long-sender (%s, %s, %s, %s, %s)",
+			FlatProfile.String(), RampProfile.String(), StepProfile.String(), SpikeProfile.String(), SineProfile.String(),
+		),
+	)
+	fs.Int64Var(&c.baseTxPerSec, "base-tps", 10, "the base rate of the load profile, ramps and steps start from it, spikes and sine waves rise from it to tps")
+	fs.Int64Var(&c.stepTxPerSec, "step-tps", 10, "the number of transactions per second added by each step of the step profile")
+	fs.Int64Var(&c.profilePeriodSec, "profile-period", 60, "the number of seconds of the ramp, of each step hold and of each spike or sine period")
+	fs.Int64Var(&c.spikeSec, "spike-duration", 10, "the number of seconds each spike of the spike profile lasts")
+	fs.Int64Var(&c.maxTxPerSec, "max-tps", 10000, "the highest rate tried by the find-max-tps mode")
+	fs.Int64Var(&c.searchPrecision, "search-precision", 10, "the find-max-tps search stops when the highest passing and lowest failing rates are this close")
+	fs.Int64Var(&c.soakSec, "soak", 60, "the number of seconds each rate is sustained by the find-max-tps mode")
+	fs.Int64Var(&c.cooldownSec, "cooldown", 30, "the number of seconds to wait after a failed rate, so the chain can recover")
+	fs.Int64Var(&c.maxLatencySec, "max-latency", 10, "the highest p90 inclusion latency, in seconds, of a sustainable rate")
+	fs.Float64Var(&c.maxErrorRate, "max-error-rate", 1, "the highest percentage of send errors of a sustainable rate")
+	fs.Float64Var(&c.maxPendingGrowth, "max-pending-growth", 10, "the highest pending pool growth of a sustainable rate, as a percentage of the sent transactions")
+	fs.Int64Var(&c.txSendTimeoutMin, "duration", 60, "the number of minutes after witch to stop the send")
+	fs.IntVar(&c.workers, "workers", DefaultWorkers, "the number of workers sending the scheduled transactions concurrently")
+	fs.IntVar(&c.presign, "presign", 0, "the number of transactions signed for each account before the sending starts, not supported with dynamic transactions")
+	fs.StringVar(&c.presignDir, "presign-dir", "", "directory of the on-disk pre-signed transactions pool, the pool is kept in memory if not set")
+	fs.IntVar(&c.batchSize, "batch-size", 0, "the number of transactions sent in a single JSON-RPC batch request, batching is disabled if lower than 2")
+	fs.Int64Var(&c.inclusionWaitSec, "inclusion-wait", 30, "the number of seconds to wait for the sent transactions to be included, after the send stops")
+	fs.StringVar(&c.endpoints, "endpoints", "", "comma delimited JSON-RPC endpoints the long-sender and find-max-tps transactions are sent to, json-rpc is used if not set")
+	fs.StringVar(&c.endpointWeights, "endpoint-weights", "", "comma delimited weights of the endpoints, used by the weighted balance strategy")
+	fs.StringVar(
+		&c.balance,
+		"balance",
+		RoundRobinBalance.String(),
+		fmt.Sprintf(
+			"strategy distributing the transactions between the endpoints (%s, %s, %s)",
+			RoundRobinBalance.String(), WeightedBalance.String(), StickyBalance.String(),
+		),
+	)
+	fs.BoolVar(&c.includeTpsReport, "report", false, "set to true to include tps report after the long-sender node")
+	fs.BoolVar(&c.waitForConfirm, "confirm", false, "wait for transactions to be confirmed")
+	fs.Int64Var(&c.waitForConfirmTimeout, "confirm-timeout", 10, "wait for tx confirmation timeout in minutes")
+	fs.Int64Var(&c.confirmDepth, "confirm-depth", 0, "the number of blocks on top of the including block after which a transaction is final, with the depth finality")
+	fs.StringVar(
+		&c.finality,
+		"finality",
+		DepthFinality.String(),
+		fmt.Sprintf(
+			"when a confirmed transaction is final: after confirm-depth blocks, or once its block is at or below the block tag (%s, %s, %s)",
+			DepthFinality.String(), SafeFinality.String(), FinalizedFinality.String(),
+		),
+	)
+	fs.Int64Var(&c.stuckTimeoutSec, "stuck-timeout", 60, "the number of seconds the oldest pending transaction of an account can wait for inclusion before its nonce is stuck, 0 disables the detection")
+	fs.StringVar(&c.mnemonic, "mnemonic", "", "mnemonic string to derive accounts from")
+	fs.IntVar(&c.totalAccounts, "mnemonic-addr", 1, "total number of account to send transactions from")
+	fs.StringVar(&c.txHash, "tx-hashes", "", "comma delimited transaction hashes to get details for")
+	fs.BoolVar(&c.txCostInEth, "tx-cost-eth", false, "present transaction costs in wei instead of eth")
+	fs.StringVar(
+		&c.output,
+		"output",
+		TableOutput.String(),
+		fmt.Sprintf("format of the blocks and transactions reports (%s, %s, %s)", TableOutput.String(), JSONOutput.String(), CSVOutput.String()),
+	)
+	fs.StringVar(&c.outputFile, "output-file", "", "file the blocks and transactions reports are written to, if not set they are written to stdout")
+	fs.StringVar(&c.htmlReport, "html-report", "", "file the html report of a blocks-fetcher or long-sender run is written to")
+	fs.StringVar(&c.resultsFile, "results-file", "", "file the json results of a blocks-fetcher or long-sender run are written to, for the compare mode")
+	fs.Int64Var(&c.tpsWindow, "tps-window", DefaultTPSWindowSec, "the number of seconds of the rolling tps window of the blocks report")
+	fs.BoolVar(&c.breakdown, "breakdown", false, "decode the transactions of the blocks and report the top senders and recipients, the transaction types and the gas per contract")
+	fs.IntVar(&c.breakdownTop, "breakdown-top", DefaultBreakdownTop, "the number of the top senders, recipients and contracts of the blocks breakdown")
+	fs.StringVar(&c.watchWindows, "watch-windows", "10,60", "comma delimited numbers of seconds of the rolling tps and utilization windows of the block-watch mode")
+	fs.IntVar(&c.watchRows, "watch-rows", DefaultWatchRows, "the number of the latest blocks in the block-watch table")
+	fs.StringVar(&c.baseline, "baseline", "", "the results file of the baseline run of the compare mode")
+	fs.StringVar(&c.candidate, "candidate", "", "the results file of the run compared to the baseline")
+	fs.Float64Var(&c.tpsTolerance, "tps-tolerance", 5, "the drop of a throughput metric, in percent of the baseline, tolerated before it is a regression")
+	fs.Float64Var(&c.latencyTolerance, "latency-tolerance", 10, "the rise of a latency or the block time, in percent of the baseline, tolerated before it is a regression")
+	fs.Float64Var(&c.errorTolerance, "error-tolerance", 1, "the rise of an error rate, in percentage points, tolerated before it is a regression")
+	fs.StringVar(&c.metricsPort, "metrics-port", "3000", "port where the prometheus metrics will be exposed")
+	fs.StringVar(&c.scenario, "scenario", "", "path to a yaml or json scenario file, explicitly set flags override its fields")
+	fs.StringVar(
+		&c.mode,
+		"mode",
+		BlocksFetcher.String(),
+		fmt.Sprintf(
+			"mode of operation (%s, %s, %s, %s, %s, %s)",
+			BlocksFetcher.String(), LongSender.String(), TxInfo.String(), FindMaxTPS.String(), BlockWatch.String(), Compare.String(),
+		),
+	)
+}
+
+func (c *rawConf) toConf() Conf {
 	return Conf{
 		JsonRPC: c.jsonRpc,
 		Blocks: Blocks{
 			Start: c.blockStart,
 			End:   c.blockEnd,
 			Range: c.blockRange,
+			Since: parseTime(c.since),
+			Until: parseTime(c.until),
+			Last:  c.last,
+		},
+		Fetch: Fetch{
+			Workers:    c.fetchWorkers,
+			BatchSize:  c.fetchBatchSize,
+			Retries:    c.fetchRetries,
+			CacheDir:   c.cacheDir,
+			CacheDepth: c.cacheDepth,
+		},
+		Mode:             Mode(c.mode),
+		PrivateKey:       c.privKey,
+		Mnemonic:         c.mnemonic,
+		ToAddress:        c.toAddr,
+		Workload:         Workload(c.workload),
+		ERC20Token:       c.erc20Token,
+		ERC20Mint:        c.erc20Mint,
+		ERC721Mint:       c.erc721Mint,
+		TxType:           TxType(c.txType),
+		FeeRefreshSec:    c.feeRefreshSec,
+		CreateAccessList: c.createAccessList,
+		TxPerSec:         c.txPerSec,
+		TxSendInterval:   c.txSendInterval,
+		TxSendTimeoutMin: c.txSendTimeoutMin,
+		LogLevel:         c.logLevel,
+		Endpoints:        c.endpointList(),
+		Balance:          Balance(c.balance),
+		IncludeTPSReport: c.includeTpsReport,
+		Workers:          c.workers,
+		Presign:          c.presign,
+		PresignDir:       c.presignDir,
+		BatchSize:        c.batchSize,
+		InclusionWaitSec: c.inclusionWaitSec,
+		Search: Search{
+			MaxTxPerSec:      c.maxTxPerSec,
+			Precision:        c.searchPrecision,
+			SoakSec:          c.soakSec,
+			CooldownSec:      c.cooldownSec,
+			MaxLatencySec:    c.maxLatencySec,
+			MaxErrorRate:     c.maxErrorRate,
+			MaxPendingGrowth: c.maxPendingGrowth,
 		},
-		Mode:                  Mode(c.mode),
-		PrivateKey:            c.privKey,
-		Mnemonic:              c.mnemonic,
-		ToAddress:             c.toAddr,
-		TxPerSec:              c.txPerSec,
-		TxSendInterval:        c.txSendInterval,
-		TxSendTimeoutMin:      c.txSendTimeoutMin,
-		LogLevel:              c.logLevel,
-		IncludeTPSReport:      c.includeTpsReport,
+		LoadProfile:           LoadProfile(c.loadProfile),
+		BaseTxPerSec:          c.baseTxPerSec,
+		StepTxPerSec:          c.stepTxPerSec,
+		ProfilePeriodSec:      c.profilePeriodSec,
+		SpikeSec:              c.spikeSec,
 		TotalAccounts:         c.totalAccounts,
 		WaitForConfirm:        c.waitForConfirm,
 		WaitForConfirmTimeout: c.waitForConfirmTimeout,
+		ConfirmDepth:          c.confirmDepth,
+		Finality:              Finality(c.finality),
+		StuckTimeoutSec:       c.stuckTimeoutSec,
 		TxHashes:              c.txHashes,
 		TxCostInEth:           c.txCostInEth,
-		MetricsPort:           c.metricsPort,
-	}, nil
+		Output:                Output(c.output),
+		OutputFile:            c.outputFile,
+		HTMLReport:            c.htmlReport,
+		ResultsFile:           c.resultsFile,
+		TPSWindowSec:          c.tpsWindow,
+		Breakdown:             c.breakdown,
+		BreakdownTop:          c.breakdownTop,
+		WatchWindowsSec:       c.watchWindowList(),
+		WatchRows:             c.watchRows,
+		Comparison: Comparison{
+			Baseline:            c.baseline,
+			Candidate:           c.candidate,
+			TPSTolerancePct:     c.tpsTolerance,
+			LatencyTolerancePct: c.latencyTolerance,
+			ErrorTolerancePct:   c.errorTolerance,
+		},
+		MetricsPort: c.metricsPort,
+		Scenario:    c.scenario,
+	}
 }
 
 func (c *rawConf) validateRawFlags() error {
-	if c.jsonRpc == "" {
+	// the compare mode only reads the saved results
+	if c.jsonRpc == "" && c.mode != Compare.String() {
 		return ErrJsonRPCNotDefined
 	}
-	if c.mode == BlocksFetcher.String() && c.blockEnd == 0 && c.blockRange == 0 {
-		return ErrEndBlockNotDefined
+	if c.mode == BlocksFetcher.String() {
+		if err := c.validateTimeWindow(); err != nil {
+			return err
+		}
+
+		if c.blockEnd == 0 && c.blockRange == 0 && c.since == "" && c.until == "" && c.last == 0 {
+			return ErrEndBlockNotDefined
+		}
 	}
 
-	if c.mode == LongSender.String() {
+	if c.mode == LongSender.String() || c.mode == FindMaxTPS.String() {
 		if c.toAddr == "" {
 			return ErrToAddrNotProvided
 		}
@@ -179,16 +718,332 @@ func (c *rawConf) validateRawFlags() error {
 		if c.privKey == "" && c.mnemonic == "" {
 			return ErrPrivKeyOrMnemonicNotProvided
 		}
+
+		if _, ok := supportedWorkloads[Workload(c.workload)]; c.workload != "" && !ok {
+			return ErrWorkloadNotSupported
+		}
+
+		if c.workload == ERC721Workload.String() && c.erc721Mint < 1 {
+			return ErrERC721MintNotPositive
+		}
+
+		if _, ok := supportedTxTypes[TxType(c.txType)]; c.txType != "" && !ok {
+			return ErrTxTypeNotSupported
+		}
+
+		if err := c.validateLoadProfile(); err != nil {
+			return err
+		}
+
+		if c.workers < 0 {
+			return ErrWorkersNegative
+		}
+
+		if c.presign < 0 {
+			return ErrPresignNegative
+		}
+
+		if c.presign > 0 && c.txType == DynamicFeeTxType.String() {
+			return ErrPresignDynamicFee
+		}
+
+		if c.batchSize < 0 {
+			return ErrBatchSizeNegative
+		}
+
+		if c.inclusionWaitSec < 0 {
+			return ErrInclusionWaitNegative
+		}
+
+		if c.confirmDepth < 0 {
+			return ErrConfirmDepthNegative
+		}
+
+		if _, ok := supportedFinalities[Finality(c.finality)]; c.finality != "" && !ok {
+			return ErrFinalityNotSupported
+		}
+
+		if c.stuckTimeoutSec < 0 {
+			return ErrStuckTimeoutNegative
+		}
+
+		if err := c.validateEndpoints(); err != nil {
+			return err
+		}
+	}
+
+	if c.mode == FindMaxTPS.String() {
+		if c.txPerSec < 1 || c.maxTxPerSec < c.txPerSec {
+			return ErrSearchRangeInvalid
+		}
+
+		if c.searchPrecision < 1 {
+			return ErrSearchPrecisionNotPositive
+		}
+
+		if c.soakSec < 1 {
+			return ErrSoakNotPositive
+		}
 	}
 
 	if c.mode == TxInfo.String() && c.txHash == "" {
 		return ErrTxHashNotProvided
 	}
 
+	if c.mode == BlockWatch.String() {
+		if c.watchWindows != "" {
+			for _, window := range splitList(c.watchWindows) {
+				if w, err := strconv.ParseInt(window, 10, 64); err != nil || w < 1 {
+					return ErrWatchWindowsInvalid
+				}
+			}
+		}
+
+		if c.watchRows < 0 {
+			return ErrWatchRowsNegative
+		}
+	}
+
+	if c.mode == Compare.String() {
+		if c.baseline == "" || c.candidate == "" {
+			return ErrCompareResultsNotDefined
+		}
+
+		if c.tpsTolerance < 0 || c.latencyTolerance < 0 || c.errorTolerance < 0 {
+			return ErrToleranceNegative
+		}
+	}
+
+	if _, ok := supportedOutputs[Output(c.output)]; c.output != "" && !ok {
+		return ErrOutputNotSupported
+	}
+
+	if c.tpsWindow < 0 {
+		return ErrTPSWindowNegative
+	}
+
+	if c.fetchWorkers < 0 {
+		return ErrFetchWorkersNegative
+	}
+
+	if c.fetchBatchSize < 0 {
+		return ErrFetchBatchSizeNegative
+	}
+
+	if c.fetchRetries < 0 {
+		return ErrFetchRetriesNegative
+	}
+
+	if c.cacheDepth < 0 {
+		return ErrCacheDepthNegative
+	}
+
+	if c.breakdownTop < 0 {
+		return ErrBreakdownTopNegative
+	}
+
+	return nil
+}
+
+func (c *rawConf) validateTimeWindow() error {
+	for _, value := range []string{c.since, c.until} {
+		if _, err := strconv.ParseInt(value, 10, 64); value != "" && err != nil {
+			if _, err = time.Parse(time.RFC3339, value); err != nil {
+				return ErrTimeNotSupported
+			}
+		}
+	}
+
+	if c.last != 0 && c.since != "" {
+		return ErrTimeWindowConflict
+	}
+
+	if c.last < 0 {
+		return ErrTimeWindowInvalid
+	}
+
+	if c.since != "" && c.until != "" && !parseTime(c.since).Before(parseTime(c.until)) {
+		return ErrTimeWindowInvalid
+	}
+
 	return nil
 }
 
+// parseTime returns the time of the RFC3339 timestamp or unix seconds, or the zero time if it is not set or invalid
+func parseTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0)
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+func (c *rawConf) validateLoadProfile() error {
+	if c.loadProfile == "" || c.loadProfile == FlatProfile.String() {
+		return nil
+	}
+
+	if _, ok := supportedLoadProfiles[LoadProfile(c.loadProfile)]; !ok {
+		return ErrLoadProfileNotSupported
+	}
+
+	if c.baseTxPerSec < 0 || c.baseTxPerSec >= c.txPerSec {
+		return ErrBaseTxPerSecNotLower
+	}
+
+	if c.profilePeriodSec < 1 {
+		return ErrProfilePeriodNotPositive
+	}
+
+	if c.loadProfile == StepProfile.String() && c.stepTxPerSec < 1 {
+		return ErrStepTxPerSecNotPositive
+	}
+
+	if c.loadProfile == SpikeProfile.String() && (c.spikeSec < 1 || c.spikeSec >= c.profilePeriodSec) {
+		return ErrSpikeNotShorterThanPeriod
+	}
+
+	return nil
+}
+
+func (c *rawConf) validateEndpoints() error {
+	if _, ok := supportedBalances[Balance(c.balance)]; c.balance != "" && !ok {
+		return ErrBalanceNotSupported
+	}
+
+	if c.endpoints == "" {
+		return nil
+	}
+
+	urls := splitList(c.endpoints)
+	for _, url := range urls {
+		if url == "" {
+			return ErrEndpointNotDefined
+		}
+	}
+
+	if c.endpointWeights == "" {
+		return nil
+	}
+
+	weights := splitList(c.endpointWeights)
+	if len(weights) != len(urls) {
+		return ErrEndpointWeightsInvalid
+	}
+
+	for _, weight := range weights {
+		if w, err := strconv.Atoi(weight); err != nil || w < 1 {
+			return ErrEndpointWeightsInvalid
+		}
+	}
+
+	return nil
+}
+
+// endpointList returns the endpoints with their weights, which default to 1
+func (c *rawConf) endpointList() []Endpoint {
+	if c.endpoints == "" {
+		return nil
+	}
+
+	var (
+		urls      = splitList(c.endpoints)
+		weights   = splitList(c.endpointWeights)
+		endpoints = make([]Endpoint, 0, len(urls))
+	)
+
+	for ind, url := range urls {
+		endpoint := Endpoint{URL: url, Weight: 1}
+
+		if ind < len(weights) {
+			if w, err := strconv.Atoi(weights[ind]); err == nil && w > 0 {
+				endpoint.Weight = w
+			}
+		}
+
+		endpoints = append(endpoints, endpoint)
+	}
+
+	return endpoints
+}
+
+// watchWindowList returns the block-watch windows, in seconds
+func (c *rawConf) watchWindowList() []int64 {
+	windows := make([]int64, 0)
+
+	for _, window := range splitList(c.watchWindows) {
+		if w, err := strconv.ParseInt(window, 10, 64); err == nil && w > 0 {
+			windows = append(windows, w)
+		}
+	}
+
+	return windows
+}
+
+// splitList splits the comma delimited list and trims its elements
+func splitList(list string) []string {
+	elems := strings.Split(strings.TrimSpace(list), ",")
+	for ind := range elems {
+		elems[ind] = strings.TrimSpace(elems[ind])
+	}
+
+	return elems
+}
+
 func (c *rawConf) processFlags() {
+	if c.workers == 0 {
+		c.workers = DefaultWorkers
+	}
+
+	if c.loadProfile == "" {
+		c.loadProfile = FlatProfile.String()
+	}
+
+	if c.balance == "" {
+		c.balance = RoundRobinBalance.String()
+	}
+
+	if c.finality == "" {
+		c.finality = DepthFinality.String()
+	}
+
+	if c.output == "" {
+		c.output = TableOutput.String()
+	}
+
+	if c.tpsWindow == 0 {
+		c.tpsWindow = DefaultTPSWindowSec
+	}
+
+	if c.fetchWorkers == 0 {
+		c.fetchWorkers = DefaultFetchWorkers
+	}
+
+	if c.breakdownTop == 0 {
+		c.breakdownTop = DefaultBreakdownTop
+	}
+
+	if c.watchRows == 0 {
+		c.watchRows = DefaultWatchRows
+	}
+
+	if c.workload == "" {
+		c.workload = EOAWorkload.String()
+	}
+
+	if c.txType == "" {
+		c.txType = LegacyTxType.String()
+	}
+
 	rawHashes := strings.Split(strings.TrimSpace(c.txHash), ",")
 	txHashes := make([]string, 0)
 	c.txHashes = append(txHashes, rawHashes...)
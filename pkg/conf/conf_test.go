@@ -2,6 +2,7 @@ package conf
 
 import (
 	"testing"
+	"time"
 )
 
 func TestFlagValidation(t *testing.T) {
@@ -49,6 +50,64 @@ func TestFlagValidation(t *testing.T) {
 		},
 	}
 
+	var timeWindowFlagsTest = []struct {
+		name  string
+		since string
+		until string
+		last  time.Duration
+		want  error
+	}{
+		{
+			name:  "Since defined",
+			since: "2024-01-01T12:00:00Z",
+			want:  nil,
+		},
+		{
+			name:  "Since and until defined",
+			since: "1704110400",
+			until: "2024-01-01T13:00:00+01:00",
+			want:  ErrTimeWindowInvalid,
+		},
+		{
+			name:  "Since before until",
+			since: "1704110400",
+			until: "2024-01-01T14:00:00+01:00",
+			want:  nil,
+		},
+		{
+			name:  "Until without since",
+			until: "2024-01-01T14:00:00Z",
+			want:  nil,
+		},
+		{
+			name:  "Last with until",
+			until: "2024-01-01T14:00:00Z",
+			last:  2 * time.Hour,
+			want:  nil,
+		},
+		{
+			name:  "Since not supported",
+			since: "yesterday",
+			want:  ErrTimeNotSupported,
+		},
+		{
+			name: "Last defined",
+			last: 2 * time.Hour,
+			want: nil,
+		},
+		{
+			name:  "Last with since",
+			since: "1704110400",
+			last:  2 * time.Hour,
+			want:  ErrTimeWindowConflict,
+		},
+		{
+			name: "Negative last",
+			last: -time.Hour,
+			want: ErrTimeWindowInvalid,
+		},
+	}
+
 	var longSenderFlagsTest = []struct {
 		name     string
 		toAddr   string
@@ -92,6 +151,460 @@ func TestFlagValidation(t *testing.T) {
 		},
 	}
 
+	var workloadFlagsTest = []struct {
+		name       string
+		workload   string
+		erc721Mint int64
+		want       error
+	}{
+		{
+			name:     "Workload not provided",
+			workload: "",
+			want:     nil,
+		},
+		{
+			name:     "ERC20 workload",
+			workload: ERC20Workload.String(),
+			want:     nil,
+		},
+		{
+			name:     "Unsupported workload",
+			workload: "erc1155",
+			want:     ErrWorkloadNotSupported,
+		},
+		{
+			name:       "ERC721 workload",
+			workload:   ERC721Workload.String(),
+			erc721Mint: 100,
+			want:       nil,
+		},
+		{
+			name:       "ERC721 workload without minted tokens",
+			workload:   ERC721Workload.String(),
+			erc721Mint: 0,
+			want:       ErrERC721MintNotPositive,
+		},
+	}
+
+	var loadProfileFlagsTest = []struct {
+		name          string
+		profile       string
+		txPerSec      int64
+		baseTxPerSec  int64
+		stepTxPerSec  int64
+		profilePeriod int64
+		spikeSec      int64
+		want          error
+	}{
+		{
+			name:    "Load profile not provided",
+			profile: "",
+			want:    nil,
+		},
+		{
+			name:         "Flat profile ignores profile flags",
+			profile:      FlatProfile.String(),
+			txPerSec:     100,
+			baseTxPerSec: 200,
+			want:         nil,
+		},
+		{
+			name:    "Unsupported load profile",
+			profile: "square",
+			want:    ErrLoadProfileNotSupported,
+		},
+		{
+			name:          "Ramp profile",
+			profile:       RampProfile.String(),
+			txPerSec:      100,
+			baseTxPerSec:  10,
+			profilePeriod: 60,
+			want:          nil,
+		},
+		{
+			name:          "Ramp profile with base tps above tps",
+			profile:       RampProfile.String(),
+			txPerSec:      100,
+			baseTxPerSec:  100,
+			profilePeriod: 60,
+			want:          ErrBaseTxPerSecNotLower,
+		},
+		{
+			name:          "Sine profile without period",
+			profile:       SineProfile.String(),
+			txPerSec:      100,
+			baseTxPerSec:  10,
+			profilePeriod: 0,
+			want:          ErrProfilePeriodNotPositive,
+		},
+		{
+			name:          "Step profile without step",
+			profile:       StepProfile.String(),
+			txPerSec:      100,
+			baseTxPerSec:  10,
+			profilePeriod: 60,
+			stepTxPerSec:  0,
+			want:          ErrStepTxPerSecNotPositive,
+		},
+		{
+			name:          "Spike longer than period",
+			profile:       SpikeProfile.String(),
+			txPerSec:      100,
+			baseTxPerSec:  10,
+			profilePeriod: 60,
+			spikeSec:      60,
+			want:          ErrSpikeNotShorterThanPeriod,
+		},
+		{
+			name:          "Spike profile",
+			profile:       SpikeProfile.String(),
+			txPerSec:      100,
+			baseTxPerSec:  10,
+			profilePeriod: 60,
+			spikeSec:      10,
+			want:          nil,
+		},
+	}
+
+	var sendFlagsTest = []struct {
+		name          string
+		workers       int
+		presign       int
+		txType        string
+		batchSize     int
+		inclusionWait int64
+		confirmDepth  int64
+		finality      string
+		stuckTimeout  int64
+		want          error
+	}{
+		{
+			name:    "Workers not provided",
+			workers: 0,
+			want:    nil,
+		},
+		{
+			name:    "Workers provided",
+			workers: 50,
+			want:    nil,
+		},
+		{
+			name:    "Negative workers",
+			workers: -1,
+			want:    ErrWorkersNegative,
+		},
+		{
+			name:    "Pre-signed transactions provided",
+			presign: 1000,
+			want:    nil,
+		},
+		{
+			name:    "Negative pre-signed transactions",
+			presign: -1,
+			want:    ErrPresignNegative,
+		},
+		{
+			name:    "Pre-signed legacy transactions",
+			presign: 1000,
+			txType:  LegacyTxType.String(),
+			want:    nil,
+		},
+		{
+			name:    "Pre-signed dynamic transactions",
+			presign: 1000,
+			txType:  DynamicFeeTxType.String(),
+			want:    ErrPresignDynamicFee,
+		},
+		{
+			name:      "Batch size provided",
+			batchSize: 100,
+			want:      nil,
+		},
+		{
+			name:      "Negative batch size",
+			batchSize: -1,
+			want:      ErrBatchSizeNegative,
+		},
+		{
+			name:          "Inclusion wait provided",
+			inclusionWait: 60,
+			want:          nil,
+		},
+		{
+			name:          "Negative inclusion wait",
+			inclusionWait: -1,
+			want:          ErrInclusionWaitNegative,
+		},
+		{
+			name:         "Confirmation depth provided",
+			confirmDepth: 12,
+			finality:     DepthFinality.String(),
+			want:         nil,
+		},
+		{
+			name:         "Negative confirmation depth",
+			confirmDepth: -1,
+			want:         ErrConfirmDepthNegative,
+		},
+		{
+			name:     "Finalized finality",
+			finality: FinalizedFinality.String(),
+			want:     nil,
+		},
+		{
+			name:     "Finality not supported",
+			finality: "latest",
+			want:     ErrFinalityNotSupported,
+		},
+		{
+			name:         "Stuck nonce timeout provided",
+			stuckTimeout: 120,
+			want:         nil,
+		},
+		{
+			name:         "Negative stuck nonce timeout",
+			stuckTimeout: -1,
+			want:         ErrStuckTimeoutNegative,
+		},
+	}
+
+	var findMaxTPSFlagsTest = []struct {
+		name        string
+		txPerSec    int64
+		maxTxPerSec int64
+		precision   int64
+		soakSec     int64
+		want        error
+	}{
+		{
+			name:        "Search flags provided",
+			txPerSec:    100,
+			maxTxPerSec: 10000,
+			precision:   10,
+			soakSec:     60,
+			want:        nil,
+		},
+		{
+			name:        "Starting tps not positive",
+			txPerSec:    0,
+			maxTxPerSec: 10000,
+			precision:   10,
+			soakSec:     60,
+			want:        ErrSearchRangeInvalid,
+		},
+		{
+			name:        "Max tps below starting tps",
+			txPerSec:    100,
+			maxTxPerSec: 50,
+			precision:   10,
+			soakSec:     60,
+			want:        ErrSearchRangeInvalid,
+		},
+		{
+			name:        "Precision not positive",
+			txPerSec:    100,
+			maxTxPerSec: 10000,
+			precision:   0,
+			soakSec:     60,
+			want:        ErrSearchPrecisionNotPositive,
+		},
+		{
+			name:        "Soak window not positive",
+			txPerSec:    100,
+			maxTxPerSec: 10000,
+			precision:   10,
+			soakSec:     0,
+			want:        ErrSoakNotPositive,
+		},
+	}
+
+	var endpointFlagsTest = []struct {
+		name      string
+		endpoints string
+		weights   string
+		balance   string
+		want      error
+	}{
+		{
+			name: "Endpoints not provided",
+			want: nil,
+		},
+		{
+			name:      "Endpoints provided",
+			endpoints: "http://node-1:8545, http://node-2:8545",
+			balance:   RoundRobinBalance.String(),
+			want:      nil,
+		},
+		{
+			name:      "Empty endpoint",
+			endpoints: "http://node-1:8545,,http://node-2:8545",
+			want:      ErrEndpointNotDefined,
+		},
+		{
+			name:      "Endpoint weights provided",
+			endpoints: "http://node-1:8545,http://node-2:8545",
+			weights:   "3,1",
+			balance:   WeightedBalance.String(),
+			want:      nil,
+		},
+		{
+			name:      "Endpoint weights count mismatch",
+			endpoints: "http://node-1:8545,http://node-2:8545",
+			weights:   "3",
+			balance:   WeightedBalance.String(),
+			want:      ErrEndpointWeightsInvalid,
+		},
+		{
+			name:      "Endpoint weight not positive",
+			endpoints: "http://node-1:8545,http://node-2:8545",
+			weights:   "3,0",
+			balance:   WeightedBalance.String(),
+			want:      ErrEndpointWeightsInvalid,
+		},
+		{
+			name:      "Balance not supported",
+			endpoints: "http://node-1:8545,http://node-2:8545",
+			balance:   "random",
+			want:      ErrBalanceNotSupported,
+		},
+	}
+
+	var outputFlagsTest = []struct {
+		name   string
+		output string
+		want   error
+	}{
+		{
+			name:   "Output not provided",
+			output: "",
+			want:   nil,
+		},
+		{
+			name:   "JSON output",
+			output: JSONOutput.String(),
+			want:   nil,
+		},
+		{
+			name:   "CSV output",
+			output: CSVOutput.String(),
+			want:   nil,
+		},
+		{
+			name:   "Output not supported",
+			output: "xml",
+			want:   ErrOutputNotSupported,
+		},
+	}
+
+	var tpsWindowFlagsTest = []struct {
+		name      string
+		tpsWindow int64
+		want      error
+	}{
+		{
+			name:      "TPS window not provided",
+			tpsWindow: 0,
+			want:      nil,
+		},
+		{
+			name:      "TPS window provided",
+			tpsWindow: 30,
+			want:      nil,
+		},
+		{
+			name:      "Negative TPS window",
+			tpsWindow: -1,
+			want:      ErrTPSWindowNegative,
+		},
+	}
+
+	var fetchFlagsTest = []struct {
+		name           string
+		fetchWorkers   int
+		fetchBatchSize int
+		fetchRetries   int
+		cacheDepth     int64
+		breakdownTop   int
+		want           error
+	}{
+		{
+			name: "Fetch flags not provided",
+			want: nil,
+		},
+		{
+			name:           "Fetch flags provided",
+			fetchWorkers:   8,
+			fetchBatchSize: 50,
+			fetchRetries:   5,
+			want:           nil,
+		},
+		{
+			name:         "Negative fetch workers",
+			fetchWorkers: -1,
+			want:         ErrFetchWorkersNegative,
+		},
+		{
+			name:           "Negative fetch batch size",
+			fetchBatchSize: -1,
+			want:           ErrFetchBatchSizeNegative,
+		},
+		{
+			name:         "Negative fetch retries",
+			fetchRetries: -1,
+			want:         ErrFetchRetriesNegative,
+		},
+		{
+			name:       "Negative cache depth",
+			cacheDepth: -1,
+			want:       ErrCacheDepthNegative,
+		},
+		{
+			name:         "Breakdown top provided",
+			breakdownTop: 5,
+			want:         nil,
+		},
+		{
+			name:         "Negative breakdown top",
+			breakdownTop: -1,
+			want:         ErrBreakdownTopNegative,
+		},
+	}
+
+	var blockWatchFlagsTest = []struct {
+		name         string
+		watchWindows string
+		watchRows    int
+		want         error
+	}{
+		{
+			name:         "Watch windows not provided",
+			watchWindows: "",
+			want:         nil,
+		},
+		{
+			name:         "Watch windows provided",
+			watchWindows: "10, 60",
+			watchRows:    50,
+			want:         nil,
+		},
+		{
+			name:         "Watch window not a number",
+			watchWindows: "10,1m",
+			want:         ErrWatchWindowsInvalid,
+		},
+		{
+			name:         "Watch window not positive",
+			watchWindows: "0",
+			want:         ErrWatchWindowsInvalid,
+		},
+		{
+			name:         "Negative watch rows",
+			watchWindows: "10",
+			watchRows:    -1,
+			want:         ErrWatchRowsNegative,
+		},
+	}
+
 	for _, tt := range jsonRpcFlagTest {
 		t.Run(tt.name, func(t *testing.T) {
 			cnf.jsonRpc = tt.input
@@ -115,6 +628,24 @@ func TestFlagValidation(t *testing.T) {
 		})
 	}
 
+	for _, tt := range timeWindowFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = BlocksFetcher.String()
+			cnf.blockRange = 0
+			cnf.blockEnd = 0
+			cnf.since = tt.since
+			cnf.until = tt.until
+			cnf.last = tt.last
+
+			tErr := cnf.validateRawFlags()
+			if tErr != tt.want {
+				t.Errorf("blocks-fetcher time window flags not passed")
+			}
+		})
+	}
+
+	cnf.since, cnf.until, cnf.last = "", "", 0
+
 	for _, tt := range longSenderFlagsTest {
 		t.Run(tt.name, func(t *testing.T) {
 			cnf.mode = LongSender.String()
@@ -130,6 +661,212 @@ func TestFlagValidation(t *testing.T) {
 		})
 	}
 
+	for _, tt := range workloadFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = LongSender.String()
+			cnf.workload = tt.workload
+			cnf.erc721Mint = tt.erc721Mint
+
+			wErr := cnf.validateRawFlags()
+			if wErr != tt.want {
+				t.Errorf("workload flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range loadProfileFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = LongSender.String()
+			cnf.workload = ""
+			cnf.loadProfile = tt.profile
+			cnf.txPerSec = tt.txPerSec
+			cnf.baseTxPerSec = tt.baseTxPerSec
+			cnf.stepTxPerSec = tt.stepTxPerSec
+			cnf.profilePeriodSec = tt.profilePeriod
+			cnf.spikeSec = tt.spikeSec
+
+			pErr := cnf.validateRawFlags()
+			if pErr != tt.want {
+				t.Errorf("load profile flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range sendFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = LongSender.String()
+			cnf.loadProfile = ""
+			cnf.workers = tt.workers
+			cnf.presign = tt.presign
+			cnf.txType = tt.txType
+			cnf.batchSize = tt.batchSize
+			cnf.inclusionWaitSec = tt.inclusionWait
+			cnf.confirmDepth = tt.confirmDepth
+			cnf.finality = tt.finality
+			cnf.stuckTimeoutSec = tt.stuckTimeout
+
+			wErr := cnf.validateRawFlags()
+			if wErr != tt.want {
+				t.Errorf("send flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range findMaxTPSFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = FindMaxTPS.String()
+			cnf.loadProfile = ""
+			cnf.workers = 0
+			cnf.presign = 0
+			cnf.txType = ""
+			cnf.batchSize = 0
+			cnf.inclusionWaitSec = 0
+			cnf.confirmDepth = 0
+			cnf.finality = ""
+			cnf.stuckTimeoutSec = 0
+			cnf.txPerSec = tt.txPerSec
+			cnf.maxTxPerSec = tt.maxTxPerSec
+			cnf.searchPrecision = tt.precision
+			cnf.soakSec = tt.soakSec
+
+			sErr := cnf.validateRawFlags()
+			if sErr != tt.want {
+				t.Errorf("find-max-tps flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range endpointFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = LongSender.String()
+			cnf.endpoints = tt.endpoints
+			cnf.endpointWeights = tt.weights
+			cnf.balance = tt.balance
+
+			eErr := cnf.validateRawFlags()
+			if eErr != tt.want {
+				t.Errorf("endpoint flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range outputFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = BlocksFetcher.String()
+			cnf.blockEnd = 100
+			cnf.output = tt.output
+
+			oErr := cnf.validateRawFlags()
+			if oErr != tt.want {
+				t.Errorf("output flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range tpsWindowFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = BlocksFetcher.String()
+			cnf.blockEnd = 100
+			cnf.output = ""
+			cnf.tpsWindow = tt.tpsWindow
+
+			wErr := cnf.validateRawFlags()
+			if wErr != tt.want {
+				t.Errorf("tps window flags test not passed")
+			}
+		})
+	}
+
+	for _, tt := range fetchFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = BlocksFetcher.String()
+			cnf.blockEnd = 100
+			cnf.tpsWindow = 0
+			cnf.fetchWorkers = tt.fetchWorkers
+			cnf.fetchBatchSize = tt.fetchBatchSize
+			cnf.fetchRetries = tt.fetchRetries
+			cnf.cacheDepth = tt.cacheDepth
+			cnf.breakdownTop = tt.breakdownTop
+
+			fErr := cnf.validateRawFlags()
+			if fErr != tt.want {
+				t.Errorf("fetch flags test not passed")
+			}
+		})
+	}
+
+	var compareFlagsTest = []struct {
+		name           string
+		jsonRpc        string
+		baseline       string
+		candidate      string
+		tpsTolerance   float64
+		errorTolerance float64
+		want           error
+	}{
+		{
+			name:      "Results provided without JSON-RPC",
+			baseline:  "baseline.json",
+			candidate: "candidate.json",
+			want:      nil,
+		},
+		{
+			name:     "Candidate not provided",
+			jsonRpc:  "https://json-rpc.example.com",
+			baseline: "baseline.json",
+			want:     ErrCompareResultsNotDefined,
+		},
+		{
+			name:         "Negative tps tolerance",
+			baseline:     "baseline.json",
+			candidate:    "candidate.json",
+			tpsTolerance: -1,
+			want:         ErrToleranceNegative,
+		},
+		{
+			name:           "Negative error tolerance",
+			baseline:       "baseline.json",
+			candidate:      "candidate.json",
+			errorTolerance: -0.5,
+			want:           ErrToleranceNegative,
+		},
+	}
+
+	cnf.fetchWorkers, cnf.fetchBatchSize, cnf.fetchRetries, cnf.cacheDepth, cnf.breakdownTop = 0, 0, 0, 0, 0
+
+	for _, tt := range blockWatchFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = BlockWatch.String()
+			cnf.tpsWindow = 0
+			cnf.watchWindows = tt.watchWindows
+			cnf.watchRows = tt.watchRows
+
+			wErr := cnf.validateRawFlags()
+			if wErr != tt.want {
+				t.Errorf("block watch flags test not passed")
+			}
+		})
+	}
+
+	jsonRpc := cnf.jsonRpc
+
+	for _, tt := range compareFlagsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cnf.mode = Compare.String()
+			cnf.jsonRpc = tt.jsonRpc
+			cnf.baseline = tt.baseline
+			cnf.candidate = tt.candidate
+			cnf.tpsTolerance = tt.tpsTolerance
+			cnf.errorTolerance = tt.errorTolerance
+
+			cErr := cnf.validateRawFlags()
+			if cErr != tt.want {
+				t.Errorf("compare flags test not passed")
+			}
+		})
+	}
+
+	cnf.jsonRpc = jsonRpc
 }
 
 func TestDefaultFlags(t *testing.T) {
@@ -184,3 +921,50 @@ func TestDefaultFlags(t *testing.T) {
 		t.Errorf("got: %t have: %t", conf.IncludeTPSReport, defaultConf.includeTpsReport)
 	}
 }
+
+func TestEndpointList(t *testing.T) {
+	cnf := rawConf{
+		endpoints:       "http://node-1:8545, http://node-2:8545,http://node-3:8545",
+		endpointWeights: "3, 2",
+	}
+
+	endpoints := cnf.endpointList()
+	want := []Endpoint{
+		{URL: "http://node-1:8545", Weight: 3},
+		{URL: "http://node-2:8545", Weight: 2},
+		// missing weights default to 1
+		{URL: "http://node-3:8545", Weight: 1},
+	}
+
+	if len(endpoints) != len(want) {
+		t.Fatalf("got: %d endpoints have: %d", len(endpoints), len(want))
+	}
+
+	for ind := range want {
+		if endpoints[ind] != want[ind] {
+			t.Errorf("got: %v have: %v", endpoints[ind], want[ind])
+		}
+	}
+
+	if (&rawConf{}).endpointList() != nil {
+		t.Errorf("endpoints must not be set without the endpoints flag")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	var times = []struct {
+		value string
+		want  time.Time
+	}{
+		{value: "", want: time.Time{}},
+		{value: "1704110400", want: time.Unix(1704110400, 0)},
+		{value: "2024-01-01T13:00:00+01:00", want: time.Unix(1704110400, 0)},
+		{value: "yesterday", want: time.Time{}},
+	}
+
+	for _, tt := range times {
+		if got := parseTime(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parse time %q got: %s want: %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package conf
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// scenarioPhasesKey is the scenario field that holds the phases, every other field is named after a flag
+	scenarioPhasesKey = "phases"
+	// phaseNameKey is the phase field that holds the name of the phase
+	phaseNameKey = "name"
+)
+
+// phaseFlags are the flags that can differ between the phases of a scenario,
+// all other flags are shared by the whole scenario
+var phaseFlags = map[string]struct{}{
+	"workload":           {},
+	"erc20-token":        {},
+	"erc20-mint":         {},
+	"erc721-mint":        {},
+	"tx-type":            {},
+	"fee-refresh":        {},
+	"create-access-list": {},
+	"tps":                {},
+	"tx-sec":             {},
+	"duration":           {},
+	"profile":            {},
+	"base-tps":           {},
+	"step-tps":           {},
+	"profile-period":     {},
+	"spike-duration":     {},
+	"workers":            {},
+	"presign":            {},
+	"batch-size":         {},
+	"inclusion-wait":     {},
+	"endpoint-weights":   {},
+	"balance":            {},
+	"mnemonic-addr":      {},
+	"to":                 {},
+	"report":             {},
+	"confirm":            {},
+	"confirm-timeout":    {},
+	"confirm-depth":      {},
+	"finality":           {},
+	"stuck-timeout":      {},
+}
+
+// rawPhase is a scenario phase with its configuration resolved from the scenario and the command line
+type rawPhase struct {
+	name string
+	conf *rawConf
+}
+
+// scenarioFields are the flag values set by a scenario or one of its phases, keyed by the flag name
+type scenarioFields map[string]string
+
+type scenario struct {
+	fields scenarioFields
+	phases []scenarioPhase
+}
+
+type scenarioPhase struct {
+	name   string
+	fields scenarioFields
+}
+
+// applyScenario loads the scenario file, sets its fields on the flags that were not explicitly set
+// on the command line and returns the resolved configuration of every scenario phase
+func (c *rawConf) applyScenario(fs *flag.FlagSet) ([]rawPhase, error) {
+	scn, err := loadScenario(c.scenario)
+	if err != nil {
+		return nil, err
+	}
+
+	explicit := map[string]struct{}{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = struct{}{}
+	})
+
+	if err = scn.fields.apply(fs, explicit); err != nil {
+		return nil, err
+	}
+
+	phases := make([]rawPhase, 0, len(scn.phases))
+
+	for _, phase := range scn.phases {
+		raw := &rawConf{}
+		phaseFs := flag.NewFlagSet(phase.name, flag.ContinueOnError)
+		raw.registerFlags(phaseFs)
+
+		// every phase starts from the resolved top level configuration
+		var copyErr error
+		fs.VisitAll(func(f *flag.Flag) {
+			if err := phaseFs.Set(f.Name, f.Value.String()); err != nil && copyErr == nil {
+				copyErr = err
+			}
+		})
+
+		if copyErr != nil {
+			return nil, fmt.Errorf("phase %s: %w", phase.name, copyErr)
+		}
+
+		if err = phase.fields.apply(phaseFs, explicit); err != nil {
+			return nil, fmt.Errorf("phase %s: %w", phase.name, err)
+		}
+
+		phases = append(phases, rawPhase{
+			name: phase.name,
+			conf: raw,
+		})
+	}
+
+	return phases, nil
+}
+
+// validatePhases validates the resolved configuration of every scenario phase
+func (c *rawConf) validatePhases(phases []rawPhase) error {
+	if len(phases) == 0 {
+		return nil
+	}
+
+	if c.mode != LongSender.String() {
+		return ErrScenarioPhasesNotSupported
+	}
+
+	for ind, phase := range phases {
+		if err := phase.conf.validateRawFlags(); err != nil {
+			return fmt.Errorf("phase %s: %w", phase.name, err)
+		}
+
+		if phase.conf.txSendTimeoutMin < 1 && ind != len(phases)-1 {
+			return fmt.Errorf("phase %s: %w", phase.name, ErrPhaseDurationNotDefined)
+		}
+	}
+
+	return nil
+}
+
+// apply sets the fields on the flag set, skipping the flags explicitly set on the command line
+func (s scenarioFields) apply(fs *flag.FlagSet, explicit map[string]struct{}) error {
+	for name, value := range s {
+		if _, ok := explicit[name]; ok {
+			continue
+		}
+
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("%w: invalid value %q for %s: %s", ErrScenarioNotLoaded, value, name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// loadScenario reads a yaml or json scenario file,
+// json documents are valid yaml, so both formats are decoded the same way
+func loadScenario(path string) (scenario, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return scenario{}, fmt.Errorf("%w: %s", ErrScenarioNotLoaded, err.Error())
+	}
+
+	doc := map[string]yaml.Node{}
+	if err = yaml.Unmarshal(raw, &doc); err != nil {
+		return scenario{}, fmt.Errorf("%w: %s", ErrScenarioNotLoaded, err.Error())
+	}
+
+	scn := scenario{
+		fields: scenarioFields{},
+		phases: make([]scenarioPhase, 0),
+	}
+
+	// the flag set is only used to check that the scenario fields are named after existing flags
+	known := flag.NewFlagSet("scenario", flag.ContinueOnError)
+	(&rawConf{}).registerFlags(known)
+
+	for name, node := range doc {
+		if name == scenarioPhasesKey {
+			continue
+		}
+
+		if known.Lookup(name) == nil || name == "scenario" {
+			return scenario{}, fmt.Errorf("%w: %s", ErrScenarioFieldNotSupported, name)
+		}
+
+		value, err := scalarValue(name, node)
+		if err != nil {
+			return scenario{}, err
+		}
+
+		scn.fields[name] = value
+	}
+
+	phasesNode, ok := doc[scenarioPhasesKey]
+	if !ok {
+		return scn, nil
+	}
+
+	rawPhases := make([]map[string]yaml.Node, 0)
+	if err = phasesNode.Decode(&rawPhases); err != nil {
+		return scenario{}, fmt.Errorf("%w: phases must be a list: %s", ErrScenarioNotLoaded, err.Error())
+	}
+
+	for ind, rawPhase := range rawPhases {
+		phase := scenarioPhase{
+			name:   fmt.Sprintf("phase-%d", ind+1),
+			fields: scenarioFields{},
+		}
+
+		for name, node := range rawPhase {
+			value, err := scalarValue(name, node)
+			if err != nil {
+				return scenario{}, err
+			}
+
+			if name == phaseNameKey {
+				phase.name = value
+				continue
+			}
+
+			if _, ok := phaseFlags[name]; !ok {
+				return scenario{}, fmt.Errorf("%w: %s can not be set per phase", ErrScenarioFieldNotSupported, name)
+			}
+
+			phase.fields[name] = value
+		}
+
+		scn.phases = append(scn.phases, phase)
+	}
+
+	return scn, nil
+}
+
+// scalarValue returns the raw text of a scalar field, so that the value is parsed by the flag it belongs to
+func scalarValue(name string, node yaml.Node) (string, error) {
+	if node.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("%w: %s must be a single value", ErrScenarioNotLoaded, name)
+	}
+
+	if node.ShortTag() == "!!null" {
+		return "", nil
+	}
+
+	return node.Value, nil
+}
@@ -0,0 +1,288 @@
+package conf
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlScenario = `
+json-rpc: http://127.0.0.1:8545
+mode: long-sender
+mnemonic: test test test test test test test test test test test junk
+to: "0x0000000000000000000000000000000000000001"
+tps: 50
+phases:
+  - name: warmup
+    duration: 1
+    tps: 10
+  - name: erc20
+    workload: erc20
+    erc20-mint: 5000000
+    tx-type: dynamic
+    duration: 5
+    mnemonic-addr: 10
+    report: true
+  - duration: 0
+`
+
+const jsonScenario = `{
+	"json-rpc": "http://127.0.0.1:8545",
+	"mode": "long-sender",
+	"pk": "0xabcd",
+	"to": "0x0000000000000000000000000000000000000001",
+	"phases": [
+		{"name": "nft", "workload": "erc721", "erc721-mint": 20, "duration": 2},
+		{"name": "eoa", "tps": 200}
+	]
+}`
+
+func writeScenario(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("could not write scenario: %s", err.Error())
+	}
+
+	return path
+}
+
+// loadTestScenario parses the arguments on a fresh flag set and applies the scenario they point to
+func loadTestScenario(t *testing.T, args ...string) (*rawConf, []rawPhase, error) {
+	t.Helper()
+
+	raw := &rawConf{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	raw.registerFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("could not parse flags: %s", err.Error())
+	}
+
+	phases, err := raw.applyScenario(fs)
+
+	return raw, phases, err
+}
+
+func TestScenarioYAML(t *testing.T) {
+	path := writeScenario(t, "scenario.yaml", yamlScenario)
+
+	raw, phases, err := loadTestScenario(t, "-scenario", path)
+	if err != nil {
+		t.Fatalf("could not load scenario: %s", err.Error())
+	}
+
+	if raw.mode != LongSender.String() || raw.jsonRpc != "http://127.0.0.1:8545" || raw.txPerSec != 50 {
+		t.Errorf("top level fields not applied: mode %s json-rpc %s tps %d", raw.mode, raw.jsonRpc, raw.txPerSec)
+	}
+
+	if err = raw.validatePhases(phases); err != nil {
+		t.Errorf("valid scenario rejected: %s", err.Error())
+	}
+
+	if len(phases) != 3 {
+		t.Fatalf("got: %d phases have: 3", len(phases))
+	}
+
+	var phasesTest = []struct {
+		name     string
+		workload string
+		txType   string
+		tps      int64
+		duration int64
+		accounts int
+		mint     int64
+		report   bool
+	}{
+		{name: "warmup", workload: "eoa", txType: "legacy", tps: 10, duration: 1, accounts: 1, mint: 1000000},
+		{name: "erc20", workload: "erc20", txType: "dynamic", tps: 50, duration: 5, accounts: 10, mint: 5000000, report: true},
+		{name: "phase-3", workload: "eoa", txType: "legacy", tps: 50, duration: 0, accounts: 1, mint: 1000000},
+	}
+
+	for ind, tt := range phasesTest {
+		t.Run(tt.name, func(t *testing.T) {
+			phase := phases[ind]
+			c := phase.conf
+
+			if phase.name != tt.name {
+				t.Errorf("got: %s have: %s", phase.name, tt.name)
+			}
+
+			// shared fields come from the top level of the scenario
+			if c.mnemonic != raw.mnemonic || c.toAddr != raw.toAddr || c.jsonRpc != raw.jsonRpc {
+				t.Errorf("shared fields not inherited by the phase")
+			}
+
+			if c.workload != tt.workload || c.txType != tt.txType {
+				t.Errorf("got: %s %s have: %s %s", c.workload, c.txType, tt.workload, tt.txType)
+			}
+
+			if c.txPerSec != tt.tps || c.txSendTimeoutMin != tt.duration {
+				t.Errorf("got: tps %d duration %d have: tps %d duration %d", c.txPerSec, c.txSendTimeoutMin, tt.tps, tt.duration)
+			}
+
+			if c.totalAccounts != tt.accounts || c.erc20Mint != tt.mint || c.includeTpsReport != tt.report {
+				t.Errorf("got: accounts %d mint %d report %t", c.totalAccounts, c.erc20Mint, c.includeTpsReport)
+			}
+		})
+	}
+}
+
+func TestScenarioJSON(t *testing.T) {
+	path := writeScenario(t, "scenario.json", jsonScenario)
+
+	raw, phases, err := loadTestScenario(t, "-scenario", path)
+	if err != nil {
+		t.Fatalf("could not load scenario: %s", err.Error())
+	}
+
+	if err = raw.validatePhases(phases); err != nil {
+		t.Errorf("valid scenario rejected: %s", err.Error())
+	}
+
+	if len(phases) != 2 {
+		t.Fatalf("got: %d phases have: 2", len(phases))
+	}
+
+	if phases[0].conf.workload != ERC721Workload.String() || phases[0].conf.erc721Mint != 20 {
+		t.Errorf("got: %s %d have: erc721 20", phases[0].conf.workload, phases[0].conf.erc721Mint)
+	}
+
+	// fields not set by the phase keep their top level value
+	if phases[1].conf.privKey != "0xabcd" || phases[1].conf.txSendTimeoutMin != 60 || phases[1].conf.txPerSec != 200 {
+		t.Errorf("got: pk %s duration %d tps %d", phases[1].conf.privKey, phases[1].conf.txSendTimeoutMin, phases[1].conf.txPerSec)
+	}
+}
+
+func TestScenarioFlagOverride(t *testing.T) {
+	path := writeScenario(t, "scenario.yaml", yamlScenario)
+
+	raw, phases, err := loadTestScenario(t, "-scenario", path, "-tps", "500", "-json-rpc", "ws://node:8546")
+	if err != nil {
+		t.Fatalf("could not load scenario: %s", err.Error())
+	}
+
+	if raw.jsonRpc != "ws://node:8546" || raw.txPerSec != 500 {
+		t.Errorf("got: json-rpc %s tps %d have: ws://node:8546 500", raw.jsonRpc, raw.txPerSec)
+	}
+
+	for _, phase := range phases {
+		if phase.conf.txPerSec != 500 {
+			t.Errorf("phase %s got: tps %d have: 500", phase.name, phase.conf.txPerSec)
+		}
+
+		if phase.conf.jsonRpc != "ws://node:8546" {
+			t.Errorf("phase %s got: json-rpc %s have: ws://node:8546", phase.name, phase.conf.jsonRpc)
+		}
+	}
+
+	// fields not overridden keep the phase value
+	if phases[0].conf.txSendTimeoutMin != 1 {
+		t.Errorf("got: %d have: 1", phases[0].conf.txSendTimeoutMin)
+	}
+}
+
+func TestScenarioValidation(t *testing.T) {
+	var scenarioTest = []struct {
+		name    string
+		content string
+		want    error
+	}{
+		{
+			name:    "Unknown field",
+			content: "json-rpc: http://127.0.0.1:8545\nrate: 100\n",
+			want:    ErrScenarioFieldNotSupported,
+		},
+		{
+			name:    "Shared field set per phase",
+			content: "json-rpc: http://127.0.0.1:8545\nphases:\n  - json-rpc: http://127.0.0.1:8546\n",
+			want:    ErrScenarioFieldNotSupported,
+		},
+		{
+			name:    "Nested scenario",
+			content: "scenario: other.yaml\n",
+			want:    ErrScenarioFieldNotSupported,
+		},
+		{
+			name:    "Invalid value",
+			content: "tps: fast\n",
+			want:    ErrScenarioNotLoaded,
+		},
+		{
+			name:    "Invalid phase value",
+			content: "phases:\n  - report: maybe\n",
+			want:    ErrScenarioNotLoaded,
+		},
+		{
+			name:    "Phases not a list",
+			content: "phases: 3\n",
+			want:    ErrScenarioNotLoaded,
+		},
+		{
+			name:    "Malformed file",
+			content: "{\"tps\": ",
+			want:    ErrScenarioNotLoaded,
+		},
+	}
+
+	for _, tt := range scenarioTest {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeScenario(t, "scenario.yaml", tt.content)
+
+			if _, _, err := loadTestScenario(t, "-scenario", path); !errors.Is(err, tt.want) {
+				t.Errorf("got: %v have: %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := loadTestScenario(t, "-scenario", filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, ErrScenarioNotLoaded) {
+		t.Errorf("got: %v have: %v", err, ErrScenarioNotLoaded)
+	}
+}
+
+func TestScenarioPhasesValidation(t *testing.T) {
+	var phasesTest = []struct {
+		name    string
+		content string
+		want    error
+	}{
+		{
+			name:    "Phases outside long-sender",
+			content: "json-rpc: http://127.0.0.1:8545\nmode: blocks-fetcher\nblock-range: 10\nphases:\n  - tps: 1\n",
+			want:    ErrScenarioPhasesNotSupported,
+		},
+		{
+			name:    "Indefinite phase before the last one",
+			content: "json-rpc: http://127.0.0.1:8545\nmode: long-sender\npk: abc\nto: \"0x01\"\nphases:\n  - duration: 0\n  - duration: 1\n",
+			want:    ErrPhaseDurationNotDefined,
+		},
+		{
+			name:    "Invalid phase workload",
+			content: "json-rpc: http://127.0.0.1:8545\nmode: long-sender\npk: abc\nto: \"0x01\"\nphases:\n  - workload: erc1155\n",
+			want:    ErrWorkloadNotSupported,
+		},
+		{
+			name:    "Phase without recipient",
+			content: "json-rpc: http://127.0.0.1:8545\nmode: long-sender\npk: abc\nto: \"0x01\"\nphases:\n  - to: ~\n",
+			want:    ErrToAddrNotProvided,
+		},
+	}
+
+	for _, tt := range phasesTest {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeScenario(t, "scenario.yaml", tt.content)
+
+			raw, phases, err := loadTestScenario(t, "-scenario", path)
+			if err != nil {
+				t.Fatalf("could not load scenario: %s", err.Error())
+			}
+
+			if err = raw.validatePhases(phases); !errors.Is(err, tt.want) {
+				t.Errorf("got: %v have: %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package contracts
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	alice = common.HexToAddress("0x00000000000000000000000000000000000a11ce")
+	bob   = common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	carol = common.HexToAddress("0x00000000000000000000000000000000000ca401")
+)
+
+type testChain struct {
+	t        *testing.T
+	cfg      *runtime.Config
+	contract common.Address
+	abi      abi.ABI
+}
+
+func newTestChain(t *testing.T, code []byte, contractABI abi.ABI) *testChain {
+	t.Helper()
+
+	// the runtime creates an in-memory state for the contract
+	cfg := &runtime.Config{Origin: alice, GasLimit: 30_000_000}
+
+	_, addr, _, err := runtime.Create(code, cfg)
+	require.NoError(t, err)
+
+	return &testChain{t: t, cfg: cfg, contract: addr, abi: contractABI}
+}
+
+func (c *testChain) call(from common.Address, method string, args ...interface{}) ([]interface{}, error) {
+	c.t.Helper()
+
+	input, err := c.abi.Pack(method, args...)
+	require.NoError(c.t, err)
+
+	c.cfg.Origin = from
+	out, _, err := runtime.Call(c.contract, input, c.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.abi.Unpack(method, out)
+}
+
+func (c *testChain) mustCall(from common.Address, method string, args ...interface{}) []interface{} {
+	c.t.Helper()
+
+	out, err := c.call(from, method, args...)
+	require.NoError(c.t, err, method)
+
+	return out
+}
+
+func TestERC20(t *testing.T) {
+	code, err := ERC20DeployCode()
+	require.NoError(t, err)
+
+	chain := newTestChain(t, code, ERC20ABI)
+
+	assert.Equal(t, "Tpser Token", chain.mustCall(alice, "name")[0])
+	assert.Equal(t, "TPS", chain.mustCall(alice, "symbol")[0])
+	assert.Equal(t, uint8(18), chain.mustCall(alice, "decimals")[0])
+
+	chain.mustCall(alice, "mint", alice, big.NewInt(1000))
+	chain.mustCall(bob, "mint", bob, big.NewInt(10))
+
+	assert.Equal(t, big.NewInt(1010), chain.mustCall(alice, "totalSupply")[0])
+	assert.Equal(t, big.NewInt(1000), chain.mustCall(alice, "balanceOf", alice)[0])
+
+	var transferTests = []struct {
+		name        string
+		from        common.Address
+		to          common.Address
+		amount      int64
+		wantFrom    int64
+		wantTo      int64
+		shouldError bool
+	}{
+		{
+			name:     "Transfer to another account",
+			from:     alice,
+			to:       bob,
+			amount:   100,
+			wantFrom: 900,
+			wantTo:   110,
+		},
+		{
+			name:     "Transfer to self",
+			from:     bob,
+			to:       bob,
+			amount:   110,
+			wantFrom: 110,
+			wantTo:   110,
+		},
+		{
+			name:        "Insufficient balance",
+			from:        bob,
+			to:          alice,
+			amount:      111,
+			wantFrom:    110,
+			wantTo:      900,
+			shouldError: true,
+		},
+	}
+
+	for _, tt := range transferTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := chain.call(tt.from, "transfer", tt.to, big.NewInt(tt.amount))
+			if tt.shouldError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, big.NewInt(tt.wantFrom), chain.mustCall(alice, "balanceOf", tt.from)[0])
+			assert.Equal(t, big.NewInt(tt.wantTo), chain.mustCall(alice, "balanceOf", tt.to)[0])
+		})
+	}
+
+	t.Run("Transfer from with allowance", func(t *testing.T) {
+		chain.mustCall(alice, "approve", carol, big.NewInt(50))
+		assert.Equal(t, big.NewInt(50), chain.mustCall(alice, "allowance", alice, carol)[0])
+
+		_, err := chain.call(carol, "transferFrom", alice, carol, big.NewInt(51))
+		assert.NotNil(t, err)
+
+		chain.mustCall(carol, "transferFrom", alice, carol, big.NewInt(20))
+		assert.Equal(t, big.NewInt(30), chain.mustCall(alice, "allowance", alice, carol)[0])
+		assert.Equal(t, big.NewInt(880), chain.mustCall(alice, "balanceOf", alice)[0])
+		assert.Equal(t, big.NewInt(20), chain.mustCall(alice, "balanceOf", carol)[0])
+	})
+
+	t.Run("Transfer emits event", func(t *testing.T) {
+		chain.mustCall(alice, "transfer", bob, big.NewInt(1))
+
+		logs := chain.cfg.State.Logs()
+		last := logs[len(logs)-1]
+
+		assert.Equal(t, ERC20ABI.Events["Transfer"].ID, last.Topics[0])
+		assert.Equal(t, common.BytesToHash(alice.Bytes()), last.Topics[1])
+		assert.Equal(t, common.BytesToHash(bob.Bytes()), last.Topics[2])
+		assert.Equal(t, common.BigToHash(big.NewInt(1)).Bytes(), last.Data)
+	})
+}
+
+func TestERC721(t *testing.T) {
+	code, err := ERC721DeployCode()
+	require.NoError(t, err)
+
+	chain := newTestChain(t, code, ERC721ABI)
+
+	// returns the onERC721Received selector
+	receiver := common.HexToAddress("0x000000000000000000000000000000000000ecec")
+	receiverCode, err := compile([]byte("PUSH 0x150b7a02\nPUSH 0xe0\nSHL\nPUSH 0\nMSTORE\nPUSH 0x20\nPUSH 0\nRETURN\n"))
+	require.NoError(t, err)
+	chain.cfg.State.SetCode(receiver, receiverCode)
+
+	// accepts every call without returning anything
+	rejecter := common.HexToAddress("0x000000000000000000000000000000000000dead")
+	chain.cfg.State.SetCode(rejecter, []byte{byte(vm.STOP)})
+
+	assert.Equal(t, "Tpser NFT", chain.mustCall(alice, "name")[0])
+	assert.Equal(t, "TNFT", chain.mustCall(alice, "symbol")[0])
+	assert.Equal(t, true, chain.mustCall(alice, "supportsInterface", [4]byte{0x80, 0xac, 0x58, 0xcd})[0])
+	assert.Equal(t, true, chain.mustCall(alice, "supportsInterface", [4]byte{0x01, 0xff, 0xc9, 0xa7})[0])
+	assert.Equal(t, false, chain.mustCall(alice, "supportsInterface", [4]byte{0xff, 0xff, 0xff, 0xff})[0])
+
+	chain.mustCall(alice, "mintBatch", alice, big.NewInt(10), big.NewInt(5))
+	chain.mustCall(bob, "mint", bob, big.NewInt(20))
+
+	assert.Equal(t, big.NewInt(6), chain.mustCall(alice, "totalSupply")[0])
+	assert.Equal(t, big.NewInt(5), chain.mustCall(alice, "balanceOf", alice)[0])
+	assert.Equal(t, alice, chain.mustCall(alice, "ownerOf", big.NewInt(14))[0])
+
+	_, err = chain.call(alice, "mint", alice, big.NewInt(12))
+	assert.NotNil(t, err, "token minted twice")
+
+	_, err = chain.call(alice, "ownerOf", big.NewInt(15))
+	assert.NotNil(t, err, "owner of non existing token")
+
+	var transferTests = []struct {
+		name        string
+		method      string
+		caller      common.Address
+		from        common.Address
+		to          common.Address
+		tokenId     int64
+		wantOwner   common.Address
+		shouldError bool
+	}{
+		{
+			name:      "Safe transfer to account",
+			method:    "safeTransferFrom",
+			caller:    alice,
+			from:      alice,
+			to:        bob,
+			tokenId:   10,
+			wantOwner: bob,
+		},
+		{
+			name:      "Safe transfer back",
+			method:    "safeTransferFrom",
+			caller:    bob,
+			from:      bob,
+			to:        alice,
+			tokenId:   10,
+			wantOwner: alice,
+		},
+		{
+			name:      "Transfer to self",
+			method:    "transferFrom",
+			caller:    bob,
+			from:      bob,
+			to:        bob,
+			tokenId:   20,
+			wantOwner: bob,
+		},
+		{
+			name:        "Transfer not owned token",
+			method:      "transferFrom",
+			caller:      bob,
+			from:        alice,
+			to:          bob,
+			tokenId:     11,
+			wantOwner:   alice,
+			shouldError: true,
+		},
+		{
+			name:        "Transfer with wrong from",
+			method:      "transferFrom",
+			caller:      alice,
+			from:        bob,
+			to:          carol,
+			tokenId:     11,
+			wantOwner:   alice,
+			shouldError: true,
+		},
+		{
+			name:      "Safe transfer to receiver contract",
+			method:    "safeTransferFrom",
+			caller:    alice,
+			from:      alice,
+			to:        receiver,
+			tokenId:   11,
+			wantOwner: receiver,
+		},
+		{
+			name:        "Safe transfer to contract without receiver",
+			method:      "safeTransferFrom",
+			caller:      alice,
+			from:        alice,
+			to:          rejecter,
+			tokenId:     12,
+			wantOwner:   alice,
+			shouldError: true,
+		},
+		{
+			name:      "Unsafe transfer to contract without receiver",
+			method:    "transferFrom",
+			caller:    alice,
+			from:      alice,
+			to:        rejecter,
+			tokenId:   12,
+			wantOwner: rejecter,
+		},
+	}
+
+	for _, tt := range transferTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := chain.call(tt.caller, tt.method, tt.from, tt.to, big.NewInt(tt.tokenId))
+			if tt.shouldError {
+				assert.NotNil(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, tt.wantOwner, chain.mustCall(alice, "ownerOf", big.NewInt(tt.tokenId))[0])
+		})
+	}
+
+	t.Run("Safe transfer with data", func(t *testing.T) {
+		chain.mustCall(alice, "safeTransferFrom0", alice, receiver, big.NewInt(13), []byte("tpser"))
+		assert.Equal(t, receiver, chain.mustCall(alice, "ownerOf", big.NewInt(13))[0])
+	})
+
+	t.Run("Balances follow transfers", func(t *testing.T) {
+		assert.Equal(t, big.NewInt(2), chain.mustCall(alice, "balanceOf", alice)[0])
+		assert.Equal(t, big.NewInt(1), chain.mustCall(alice, "balanceOf", bob)[0])
+		assert.Equal(t, big.NewInt(2), chain.mustCall(alice, "balanceOf", receiver)[0])
+		assert.Equal(t, big.NewInt(1), chain.mustCall(alice, "balanceOf", rejecter)[0])
+	})
+
+	t.Run("Approved account can transfer", func(t *testing.T) {
+		_, err := chain.call(carol, "transferFrom", alice, carol, big.NewInt(14))
+		assert.NotNil(t, err)
+
+		chain.mustCall(alice, "approve", carol, big.NewInt(14))
+		assert.Equal(t, carol, chain.mustCall(alice, "getApproved", big.NewInt(14))[0])
+
+		chain.mustCall(carol, "transferFrom", alice, carol, big.NewInt(14))
+		assert.Equal(t, carol, chain.mustCall(alice, "ownerOf", big.NewInt(14))[0])
+		assert.Equal(t, common.Address{}, chain.mustCall(alice, "getApproved", big.NewInt(14))[0])
+	})
+
+	t.Run("Operator can transfer", func(t *testing.T) {
+		chain.mustCall(bob, "setApprovalForAll", carol, true)
+		assert.Equal(t, true, chain.mustCall(alice, "isApprovedForAll", bob, carol)[0])
+
+		chain.mustCall(carol, "safeTransferFrom", bob, alice, big.NewInt(20))
+		assert.Equal(t, alice, chain.mustCall(alice, "ownerOf", big.NewInt(20))[0])
+
+		chain.mustCall(bob, "setApprovalForAll", carol, false)
+		assert.Equal(t, false, chain.mustCall(alice, "isApprovedForAll", bob, carol)[0])
+	})
+
+	t.Run("Transfer emits event", func(t *testing.T) {
+		chain.mustCall(alice, "transferFrom", alice, bob, big.NewInt(20))
+
+		logs := chain.cfg.State.Logs()
+		last := logs[len(logs)-1]
+
+		assert.Equal(t, ERC721ABI.Events["Transfer"].ID, last.Topics[0])
+		assert.Equal(t, common.BytesToHash(alice.Bytes()), last.Topics[1])
+		assert.Equal(t, common.BytesToHash(bob.Bytes()), last.Topics[2])
+		assert.Equal(t, common.BigToHash(big.NewInt(20)), last.Topics[3])
+	})
+}
+
+// the assembler compiles unknown mnemonics to STOP, so make sure every STOP in the bytecode was intended
+func TestCompiledSourcesHaveNoUnknownOpcodes(t *testing.T) {
+	var sources = []struct {
+		name   string
+		source []byte
+	}{
+		{
+			name:   "ERC20",
+			source: erc20Source,
+		},
+		{
+			name:   "ERC721",
+			source: erc721Source,
+		},
+	}
+
+	for _, tt := range sources {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := compile(tt.source)
+			require.NoError(t, err)
+
+			stops := 0
+			it := asm.NewInstructionIterator(code)
+			for it.Next() {
+				if it.Op() == vm.STOP {
+					stops++
+				}
+			}
+			require.NoError(t, it.Error())
+
+			wantStops := 0
+			for _, line := range strings.Split(string(tt.source), "\n") {
+				if strings.TrimSpace(line) == "STOP" {
+					wantStops++
+				}
+			}
+
+			assert.Equal(t, wantStops, stops)
+		})
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/ZeljkoBenovic/tpser/pkg/prom"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/blockwatch"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/compare"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/findmaxtps"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/getblocks"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/longsender"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/txinfo"
@@ -42,6 +45,15 @@ var modesFactory = map[conf.Mode]factoryFunc{
 	conf.TxInfo: func(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf, _ *prom.Prom) Common {
 		return txinfo.New(ctx, log, eth, conf)
 	},
+	conf.FindMaxTPS: func(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf, prom *prom.Prom) Common {
+		return findmaxtps.New(ctx, log, eth, conf, prom)
+	},
+	conf.BlockWatch: func(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf, _ *prom.Prom) Common {
+		return blockwatch.New(ctx, log, eth, conf)
+	},
+	conf.Compare: func(_ context.Context, log logger.Logger, _ *ethclient.Client, conf conf.Conf, _ *prom.Prom) Common {
+		return compare.New(log, conf)
+	},
 }
 
 type eth struct {
@@ -55,11 +67,19 @@ type eth struct {
 }
 
 func New(conf conf.Conf, log logger.Logger, ctx context.Context, prom *prom.Prom) (Eth, error) {
-	e, err := ethclient.Dial(conf.JsonRPC)
-	if err != nil {
-		log.Error("Could not dial json-rpc", "json-rpc", conf.JsonRPC)
-		return nil, err
+	var e *ethclient.Client
+
+	// the json-rpc endpoint is only optional for the modes that do not use it, like compare
+	if conf.JsonRPC != "" {
+		var err error
+
+		e, err = ethclient.Dial(conf.JsonRPC)
+		if err != nil {
+			log.Error("Could not dial json-rpc", "json-rpc", conf.JsonRPC)
+			return nil, err
+		}
 	}
+
 	return &eth{
 		ethClient:    e,
 		log:          log,
@@ -0,0 +1,397 @@
+package blockwatch
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockcache"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockstats"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/output"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	// pollInterval is the interval of the chain head polling, if the endpoint does not support subscriptions
+	pollInterval = time.Second
+	// headsBuffer is the number of new block headers buffered while the blocks are fetched
+	headsBuffer = 64
+	// clearScreen moves the cursor to the top of the terminal and clears it, before the table is redrawn
+	clearScreen = "\033[H\033[2J"
+)
+
+// rpcBlock holds the block fields of the watched blocks, the transactions are only hashes, which are counted
+type rpcBlock struct {
+	Hash         common.Hash    `json:"hash"`
+	ParentHash   common.Hash    `json:"parentHash"`
+	Number       hexutil.Uint64 `json:"number"`
+	GasLimit     hexutil.Uint64 `json:"gasLimit"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Time         hexutil.Uint64 `json:"timestamp"`
+	Transactions []common.Hash  `json:"transactions"`
+}
+
+// Line is a watched block with the rolling windows ending at it, written as a json line
+type Line struct {
+	types.BlockInfo
+	Windows []blockstats.Window `json:"windows"`
+}
+
+type BlockWatch struct {
+	ctx  context.Context
+	log  logger.Logger
+	eth  *ethclient.Client
+	conf conf.Conf
+
+	// blocks are the latest watched blocks, ordered by number, kept for the table and the longest window
+	blocks []types.BlockInfo
+	// next is the number of the next block to fetch
+	next uint64
+
+	// cache keeps the watched blocks once they are deep enough, it is nil if the blocks are not cached
+	cache *blockcache.Cache
+	// uncached are the watched blocks not deep enough to be cached yet
+	uncached []types.BlockInfo
+
+	w   io.Writer
+	csv *csv.Writer
+}
+
+func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) *BlockWatch {
+	return &BlockWatch{
+		ctx:    ctx,
+		log:    log.Named("blockwatch"),
+		eth:    eth,
+		conf:   conf,
+		blocks: make([]types.BlockInfo, 0),
+	}
+}
+
+// RunMode follows the chain head from the latest block, and writes every new block until the context is done
+func (b *BlockWatch) RunMode() error {
+	w, err := output.Open(b.conf.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	b.w = w
+
+	if b.conf.Output == conf.CSVOutput {
+		b.csv = csv.NewWriter(w)
+
+		if err = b.writeCSV(b.header()); err != nil {
+			return err
+		}
+	}
+
+	if b.conf.Fetch.CacheDir != "" {
+		if b.cache, err = blockcache.Open(b.ctx, b.eth, b.conf.Fetch.CacheDir, uint64(b.conf.Fetch.CacheDepth)); err != nil {
+			b.log.Warn("Could not open block cache, the watched blocks are not cached", "err", err.Error())
+		}
+	}
+
+	head, err := b.eth.BlockNumber(b.ctx)
+	if err != nil {
+		return fmt.Errorf("could not get latest block: %w", err)
+	}
+
+	b.next = head
+
+	if err = b.onHead(head); err != nil {
+		return err
+	}
+
+	return b.follow()
+}
+
+// follow passes every new chain head to onHead, from a newHeads subscription,
+// or by polling if the endpoint does not support subscriptions or the subscription is lost
+func (b *BlockWatch) follow() error {
+	heads := make(chan *ethTypes.Header, headsBuffer)
+
+	sub, err := b.eth.SubscribeNewHead(b.ctx, heads)
+	if err != nil {
+		b.log.Debug("Could not subscribe to new blocks, polling the chain head", "err", err.Error())
+		return b.poll()
+	}
+
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return nil
+		case err = <-sub.Err():
+			b.log.Warn("New blocks subscription lost, polling the chain head", "err", err)
+			return b.poll()
+		case header := <-heads:
+			// a new head at or below a watched block replaces it
+			if number := header.Number.Uint64(); number < b.next {
+				b.rewind(number)
+			}
+
+			if err = b.onHead(header.Number.Uint64()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *BlockWatch) poll() error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return nil
+		case <-ticker.C:
+			head, err := b.eth.BlockNumber(b.ctx)
+			if err != nil {
+				b.log.Debug("Could not fetch latest block number", "err", err.Error())
+				continue
+			}
+
+			// the polled head is the last watched block until a new one is built, a lower head is a reorg
+			if head+1 < b.next {
+				b.rewind(head)
+			}
+
+			if err = b.onHead(head); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// onHead fetches and writes the blocks up to the head.
+// A block not built on the last watched one is a reorg, the replaced blocks are dropped and fetched again.
+// A block that can not be fetched is fetched again with the next head
+func (b *BlockWatch) onHead(head uint64) error {
+	for b.next <= head {
+		block, err := b.fetchBlock(b.next)
+		if err != nil {
+			b.log.Debug("Could not fetch block", "number", b.next, "err", err.Error())
+			return nil
+		}
+
+		if last := len(b.blocks) - 1; last >= 0 && b.blocks[last].Hash != block.ParentHash.String() {
+			b.rewind(b.blocks[last].Number)
+			continue
+		}
+
+		info := types.BlockInfo{
+			TransactionNum: len(block.Transactions),
+			GasLimit:       uint64(block.GasLimit),
+			GasUsed:        uint64(block.GasUsed),
+			Hash:           block.Hash.String(),
+			Number:         uint64(block.Number),
+			Time:           uint64(block.Time),
+		}
+
+		b.blocks = append(b.blocks, info)
+		b.next++
+
+		b.trim()
+		b.cacheBlocks(info)
+
+		if err = b.write(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchBlock fetches the header and the transaction hashes of the block, without the transaction bodies
+func (b *BlockWatch) fetchBlock(number uint64) (*rpcBlock, error) {
+	var block *rpcBlock
+	if err := b.eth.Client().CallContext(b.ctx, &block, "eth_getBlockByNumber", hexutil.EncodeUint64(number), false); err != nil {
+		return nil, err
+	}
+
+	if block == nil {
+		return nil, ethereum.NotFound
+	}
+
+	return block, nil
+}
+
+// rewind drops the watched blocks from the number on, so that they are fetched again
+func (b *BlockWatch) rewind(number uint64) {
+	b.log.Warn("Chain reorganization, fetching the replaced blocks again", "from", number)
+
+	for len(b.blocks) != 0 && b.blocks[len(b.blocks)-1].Number >= number {
+		b.blocks = b.blocks[:len(b.blocks)-1]
+	}
+
+	for len(b.uncached) != 0 && b.uncached[len(b.uncached)-1].Number >= number {
+		b.uncached = b.uncached[:len(b.uncached)-1]
+	}
+
+	b.next = number
+}
+
+// cacheBlocks caches the watched blocks that are deep enough below the new block
+func (b *BlockWatch) cacheBlocks(block types.BlockInfo) {
+	if b.cache == nil {
+		return
+	}
+
+	b.uncached = append(b.uncached, block)
+	b.cache.SetHead(block.Number)
+
+	deep := 0
+	for deep < len(b.uncached) && b.uncached[deep].Number+uint64(b.conf.Fetch.CacheDepth) <= block.Number {
+		deep++
+	}
+
+	if deep == 0 {
+		return
+	}
+
+	b.cache.Put(b.uncached[:deep]...)
+	b.uncached = b.uncached[deep:]
+
+	if err := b.cache.Flush(); err != nil {
+		b.log.Warn("Could not write block cache", "err", err.Error())
+	}
+}
+
+// trim drops the blocks that are neither in the table nor in the longest window
+func (b *BlockWatch) trim() {
+	var (
+		longest = int64(0)
+		last    = b.blocks[len(b.blocks)-1]
+		drop    = 0
+	)
+
+	for _, window := range b.conf.WatchWindowsSec {
+		longest = max(longest, window)
+	}
+
+	for drop < len(b.blocks)-b.conf.WatchRows && last.Time-b.blocks[drop].Time > uint64(longest) {
+		drop++
+	}
+
+	b.blocks = b.blocks[drop:]
+}
+
+// windows returns the rolling windows ending at the last watched block
+func (b *BlockWatch) windows() []blockstats.Window {
+	windows := make([]blockstats.Window, 0, len(b.conf.WatchWindowsSec))
+
+	for _, window := range b.conf.WatchWindowsSec {
+		windows = append(windows, blockstats.Trailing(b.blocks, window))
+	}
+
+	return windows
+}
+
+// write writes the last watched block in the configured format,
+// a line per block for json and csv, or the redrawn table of the latest blocks
+func (b *BlockWatch) write() error {
+	line := Line{BlockInfo: b.blocks[len(b.blocks)-1], Windows: b.windows()}
+
+	switch b.conf.Output {
+	case conf.JSONOutput:
+		if err := json.NewEncoder(b.w).Encode(line); err != nil {
+			return fmt.Errorf("could not write json output: %w", err)
+		}
+
+		return nil
+	case conf.CSVOutput:
+		return b.writeCSV(line.row())
+	default:
+		b.render(line.Windows)
+		return nil
+	}
+}
+
+func (b *BlockWatch) writeCSV(row []string) error {
+	if err := b.csv.Write(row); err != nil {
+		return fmt.Errorf("could not write csv output: %w", err)
+	}
+
+	b.csv.Flush()
+
+	if err := b.csv.Error(); err != nil {
+		return fmt.Errorf("could not write csv output: %w", err)
+	}
+
+	return nil
+}
+
+// header returns the csv header, with the rate and utilization of every window
+func (b *BlockWatch) header() []string {
+	header := []string{"timestamp", "number", "hash", "txs", "gas_limit", "gas_used"}
+
+	for _, window := range b.conf.WatchWindowsSec {
+		header = append(header, fmt.Sprintf("tps_%ds", window), fmt.Sprintf("gas_utilization_pct_%ds", window))
+	}
+
+	return header
+}
+
+func (l Line) row() []string {
+	row := []string{
+		fmt.Sprintf("%d", l.Time),
+		fmt.Sprintf("%d", l.Number),
+		l.Hash,
+		fmt.Sprintf("%d", l.TransactionNum),
+		fmt.Sprintf("%d", l.GasLimit),
+		fmt.Sprintf("%d", l.GasUsed),
+	}
+
+	for _, window := range l.Windows {
+		row = append(row, fmt.Sprintf("%.2f", window.TPS), fmt.Sprintf("%.2f", window.Utilization))
+	}
+
+	return row
+}
+
+// render redraws the table of the latest blocks, and the table of the rolling windows
+func (b *BlockWatch) render(windows []blockstats.Window) {
+	_, _ = io.WriteString(b.w, clearScreen)
+
+	table := tablewriter.NewWriter(b.w)
+	table.SetHeader([]string{"TIME", "NUMBER", "TXS", "GAS_LIMIT", "GAS_USED"})
+
+	for _, block := range b.blocks[max(len(b.blocks)-b.conf.WatchRows, 0):] {
+		table.Append([]string{
+			time.Unix(int64(block.Time), 0).Format(time.DateTime),
+			fmt.Sprintf("%d", block.Number),
+			fmt.Sprintf("%d", block.TransactionNum),
+			fmt.Sprintf("%d", block.GasLimit),
+			fmt.Sprintf("%.2f%%", float64(block.GasUsed)/float64(block.GasLimit)*100),
+		})
+	}
+
+	table.Render()
+
+	rolling := tablewriter.NewWriter(b.w)
+	rolling.SetHeader([]string{"WINDOW", "TPS", "GAS_USED"})
+
+	for _, window := range windows {
+		rolling.Append([]string{
+			fmt.Sprintf("%ds", window.WindowSec),
+			fmt.Sprintf("%.2f", window.TPS),
+			fmt.Sprintf("%.2f%%", window.Utilization),
+		})
+	}
+
+	rolling.Render()
+}
@@ -0,0 +1,268 @@
+package blockwatch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockcache"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainAPI serves the blocks of a chain, built every two seconds with ten transactions each
+type chainAPI struct {
+	mux    sync.Mutex
+	blocks []*types.Header
+	heads  chan *types.Header
+	// built counts the built blocks, so that a replacing block gets a different hash
+	built byte
+}
+
+// subscriptionAPI is a chainAPI that also serves the newHeads subscription
+type subscriptionAPI struct {
+	*chainAPI
+}
+
+func newChainAPI() *chainAPI {
+	api := &chainAPI{heads: make(chan *types.Header, 16)}
+	api.build(0)
+	api.build(0)
+
+	// the chain is built before the watch starts
+	<-api.heads
+	<-api.heads
+
+	return api
+}
+
+func (a *chainAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1337))
+}
+
+func (a *chainAPI) BlockNumber() hexutil.Uint64 {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return hexutil.Uint64(len(a.blocks) - 1)
+}
+
+func (a *chainAPI) GetBlockByNumber(number hexutil.Uint64, fullTx bool) (map[string]interface{}, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if int(number) >= len(a.blocks) {
+		return nil, nil
+	}
+
+	header := a.blocks[number]
+
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	block := make(map[string]interface{})
+	if err = json.Unmarshal(encoded, &block); err != nil {
+		return nil, err
+	}
+
+	txs := make([]*types.Transaction, 0)
+	for ind := range make([]struct{}, 10) {
+		txs = append(txs, types.NewTx(&types.LegacyTx{Nonce: uint64(ind), Gas: 21000, GasPrice: common.Big1, Value: common.Big0}))
+	}
+
+	if fullTx {
+		block["transactions"] = txs
+	} else {
+		hashes := make([]common.Hash, 0, len(txs))
+		for _, tx := range txs {
+			hashes = append(hashes, tx.Hash())
+		}
+
+		block["transactions"] = hashes
+	}
+
+	block["uncles"] = []common.Hash{}
+
+	return block, nil
+}
+
+func (a *subscriptionAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for {
+			select {
+			case header := <-a.heads:
+				_ = notifier.Notify(sub.ID, header)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+// build adds a block on top of the block with the number, replacing the blocks above it, and announces it
+func (a *chainAPI) build(parent uint64) {
+	a.mux.Lock()
+	a.built++
+
+	header := &types.Header{
+		Number:     new(big.Int),
+		Difficulty: common.Big0,
+		UncleHash:  types.EmptyUncleHash,
+		TxHash:     common.Hash{1},
+		GasLimit:   1000,
+		GasUsed:    500,
+		Extra:      []byte{a.built},
+	}
+
+	if len(a.blocks) != 0 {
+		a.blocks = a.blocks[:parent+1]
+
+		header.ParentHash = a.blocks[parent].Hash()
+		header.Number.SetUint64(parent + 1)
+		header.Time = a.blocks[parent].Time + 2
+	}
+
+	a.blocks = append(a.blocks, header)
+	a.mux.Unlock()
+
+	a.heads <- header
+}
+
+func TestBlockWatch_RunMode(t *testing.T) {
+	var tests = []struct {
+		name      string
+		subscribe bool
+	}{
+		{
+			name:      "New heads subscription",
+			subscribe: true,
+		},
+		{
+			name:      "Chain head polling",
+			subscribe: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				api         = newChainAPI()
+				srv         = rpc.NewServer()
+				path        = filepath.Join(t.TempDir(), "blocks.json")
+				cacheDir    = t.TempDir()
+				ctx, cancel = context.WithCancel(context.Background())
+				done        = make(chan error)
+			)
+
+			defer cancel()
+
+			var service interface{} = api
+			if tc.subscribe {
+				service = &subscriptionAPI{api}
+			}
+
+			require.NoError(t, srv.RegisterName("eth", service))
+			defer srv.Stop()
+
+			eth := ethclient.NewClient(rpc.DialInProc(srv))
+
+			watch := New(ctx, logger.NewZapLogger(), eth, conf.Conf{
+				Output:          conf.JSONOutput,
+				OutputFile:      path,
+				WatchWindowsSec: []int64{4, 60},
+				WatchRows:       2,
+				Fetch:           conf.Fetch{CacheDir: cacheDir, CacheDepth: 1},
+			})
+
+			go func() {
+				done <- watch.RunMode()
+			}()
+
+			lines := func() []Line {
+				f, err := os.Open(path)
+				if err != nil {
+					return nil
+				}
+
+				defer f.Close()
+
+				result := make([]Line, 0)
+				for scanner := bufio.NewScanner(f); scanner.Scan(); {
+					var line Line
+					require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+					result = append(result, line)
+				}
+
+				return result
+			}
+
+			// the watch starts from the latest block
+			require.Eventually(t, func() bool { return len(lines()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+			api.build(1)
+			api.build(2)
+			require.Eventually(t, func() bool { return len(lines()) == 3 }, 5*time.Second, 10*time.Millisecond)
+
+			// a reorg replaces the third block, the replacing one is fetched with the block built on it
+			api.build(2)
+			api.build(3)
+			require.Eventually(t, func() bool { return len(lines()) == 5 }, 5*time.Second, 10*time.Millisecond)
+
+			cancel()
+			require.NoError(t, <-done)
+
+			written := lines()
+			numbers := make([]uint64, 0, len(written))
+			for _, line := range written {
+				numbers = append(numbers, line.Number)
+			}
+
+			assert.Equal(t, []uint64{1, 2, 3, 3, 4}, numbers)
+			assert.NotEqual(t, written[2].Hash, written[3].Hash)
+
+			// the four second window ending at the last block holds two blocks of ten transactions,
+			// the sixty second window holds all the blocks since the first watched one, six seconds ago
+			assert.Equal(t, 5.0, written[4].Windows[0].TPS)
+			assert.Equal(t, 5.0, written[4].Windows[1].TPS)
+			assert.Equal(t, 50.0, written[4].Windows[0].Utilization)
+
+			// the replaced block is dropped, the older blocks are kept for the longest window
+			assert.Len(t, watch.blocks, 4)
+
+			// the blocks are cached once there is a block on top of them, so the replaced one is not
+			cache, err := blockcache.Open(context.Background(), eth, cacheDir, 1)
+			require.NoError(t, err)
+			cache.SetHead(4)
+
+			for _, line := range []Line{written[0], written[1], written[3]} {
+				cached, ok := cache.Get(line.Number)
+				require.True(t, ok)
+				assert.Equal(t, line.BlockInfo, cached)
+			}
+		})
+	}
+}
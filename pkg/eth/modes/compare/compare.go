@@ -0,0 +1,167 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/output"
+	"github.com/ZeljkoBenovic/tpser/pkg/results"
+	"github.com/olekukonko/tablewriter"
+)
+
+var ErrRegression = errors.New("candidate run regressed")
+
+// deltasHeader is the header of the csv output, with a row per metric
+var deltasHeader = []string{"metric", "kind", "baseline", "candidate", "change", "absolute", "missing", "regression"}
+
+// Report holds the compared results files and the changes of their metrics
+type Report struct {
+	Baseline    string          `json:"baseline"`
+	Candidate   string          `json:"candidate"`
+	Deltas      []results.Delta `json:"deltas"`
+	Regressions int             `json:"regressions"`
+}
+
+type Compare struct {
+	log  logger.Logger
+	conf conf.Conf
+}
+
+func New(log logger.Logger, conf conf.Conf) *Compare {
+	return &Compare{
+		log:  log.Named("compare"),
+		conf: conf,
+	}
+}
+
+// RunMode compares the candidate results to the baseline, writes the changes of their metrics,
+// and returns ErrRegression if any metric regressed beyond its tolerance
+func (c *Compare) RunMode() error {
+	baseline, err := results.Read(c.conf.Comparison.Baseline)
+	if err != nil {
+		return err
+	}
+
+	candidate, err := results.Read(c.conf.Comparison.Candidate)
+	if err != nil {
+		return err
+	}
+
+	if baseline.Mode != candidate.Mode {
+		c.log.Warn("Comparing the results of different modes", "baseline", baseline.Mode, "candidate", candidate.Mode)
+	}
+
+	deltas := results.Compare(baseline, candidate, c.conf.Comparison)
+
+	report := Report{
+		Baseline:    c.conf.Comparison.Baseline,
+		Candidate:   c.conf.Comparison.Candidate,
+		Deltas:      deltas,
+		Regressions: results.Regressions(deltas),
+	}
+
+	if err = c.output(report); err != nil {
+		return err
+	}
+
+	if report.Regressions != 0 {
+		return fmt.Errorf("%w: %d of %d metrics", ErrRegression, report.Regressions, len(deltas))
+	}
+
+	c.log.Info("No regressions found", "metrics", len(deltas))
+
+	return nil
+}
+
+// output writes the report in the configured format, to the output file or stdout
+func (c *Compare) output(report Report) error {
+	w, err := output.Open(c.conf.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	switch c.conf.Output {
+	case conf.JSONOutput:
+		return output.JSON(w, report)
+	case conf.CSVOutput:
+		return output.CSV(w, deltasHeader, report.rows())
+	default:
+		report.render(w)
+		return nil
+	}
+}
+
+// rows returns the csv rows of the metrics
+func (r Report) rows() [][]string {
+	rows := make([][]string, 0, len(r.Deltas))
+
+	for _, delta := range r.Deltas {
+		rows = append(rows, []string{
+			delta.Metric,
+			string(delta.Kind),
+			fmt.Sprintf("%.4f", delta.Baseline),
+			fmt.Sprintf("%.4f", delta.Candidate),
+			fmt.Sprintf("%.4f", delta.Change),
+			fmt.Sprintf("%t", delta.Absolute),
+			fmt.Sprintf("%t", delta.Missing),
+			fmt.Sprintf("%t", delta.Regression),
+		})
+	}
+
+	return rows
+}
+
+// render writes the metrics table, with the number of regressions in the footer
+func (r Report) render(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"METRIC", "BASELINE", "CANDIDATE", "CHANGE", "RESULT"})
+
+	for _, delta := range r.Deltas {
+		candidate := fmt.Sprintf("%.2f", delta.Candidate)
+		if delta.Missing {
+			candidate = "missing"
+		}
+
+		table.Append([]string{
+			delta.Metric,
+			fmt.Sprintf("%.2f", delta.Baseline),
+			candidate,
+			change(delta),
+			result(delta),
+		})
+	}
+
+	table.SetFooter([]string{"", "", "", "REGRESSIONS", fmt.Sprintf("%d", r.Regressions)})
+	table.Render()
+}
+
+// change formats the change of the metric, in percentage points for an error rate,
+// and without a unit if it is the absolute change from a zero baseline
+func change(delta results.Delta) string {
+	switch {
+	case delta.Missing:
+		return "-"
+	case delta.Kind == results.ErrorRateKind:
+		return fmt.Sprintf("%+.2f pp", delta.Change)
+	case delta.Absolute:
+		return fmt.Sprintf("%+.2f", delta.Change)
+	}
+
+	return fmt.Sprintf("%+.2f%%", delta.Change)
+}
+
+func result(delta results.Delta) string {
+	switch {
+	case delta.Regression:
+		return "REGRESSION"
+	case delta.Kind == results.InfoKind:
+		return "-"
+	default:
+		return "ok"
+	}
+}
@@ -0,0 +1,94 @@
+package compare
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockstats"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/results"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeResults writes the results of a run with the tps to a file in the directory
+func writeResults(t *testing.T, dir, name string, tps float64) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, results.Write(path, results.Results{
+		Mode:   conf.BlocksFetcher,
+		Blocks: blockstats.Stats{Blocks: 10, TPS: tps, PeakTPS: 2 * tps, GasPerSec: 21000 * tps},
+	}))
+
+	return path
+}
+
+func TestCompare_RunMode(t *testing.T) {
+	var tests = []struct {
+		name        string
+		candidate   float64
+		regressions int
+		err         error
+	}{
+		{
+			name:      "No regressions",
+			candidate: 98,
+		},
+		{
+			name:        "Throughput regressed",
+			candidate:   80,
+			regressions: 3,
+			err:         ErrRegression,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				dir  = t.TempDir()
+				path = filepath.Join(dir, "compare.json")
+			)
+
+			cmp := New(logger.NewZapLogger(), conf.Conf{
+				Output:     conf.JSONOutput,
+				OutputFile: path,
+				Comparison: conf.Comparison{
+					Baseline:        writeResults(t, dir, "baseline.json", 100),
+					Candidate:       writeResults(t, dir, "candidate.json", tc.candidate),
+					TPSTolerancePct: 5,
+				},
+			})
+
+			err := cmp.RunMode()
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			raw, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			var report Report
+			require.NoError(t, json.Unmarshal(raw, &report))
+
+			assert.Equal(t, tc.regressions, report.Regressions)
+			assert.NotEmpty(t, report.Deltas)
+		})
+	}
+}
+
+func TestCompare_RunModeMissingResults(t *testing.T) {
+	cmp := New(logger.NewZapLogger(), conf.Conf{
+		Comparison: conf.Comparison{
+			Baseline:  filepath.Join(t.TempDir(), "missing.json"),
+			Candidate: filepath.Join(t.TempDir(), "missing.json"),
+		},
+	})
+
+	assert.Error(t, cmp.RunMode())
+}
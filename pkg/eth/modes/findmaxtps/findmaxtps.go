@@ -0,0 +1,223 @@
+package findmaxtps
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/balancer"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsender"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/workload"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/prom"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/olekukonko/tablewriter"
+)
+
+// FindMaxTPS searches for the highest rate the chain sustains, by sending each tried rate for the soak window
+type FindMaxTPS struct {
+	ctx  context.Context
+	log  logger.Logger
+	eth  *ethclient.Client
+	conf conf.Conf
+	prom *prom.Prom
+
+	balancer *balancer.Balancer
+	// senders send the transactions to the balancer endpoints with the same index
+	senders  []*txsender.TxSender
+	workload workload.Workload
+	signers  []*txsigner.TxSigner
+	// nonces holds the next nonce of every signer, only the goroutine scheduling the sends touches them
+	nonces []uint64
+
+	trials []trialResult
+}
+
+func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf, prom *prom.Prom) *FindMaxTPS {
+	return &FindMaxTPS{
+		ctx:    ctx,
+		log:    log.Named("findmaxtps"),
+		eth:    eth,
+		conf:   conf,
+		prom:   prom,
+		trials: make([]trialResult, 0),
+	}
+}
+
+func (f *FindMaxTPS) RunMode() error {
+	f.prom.SetTxSendInterval(1)
+
+	if err := f.initSigners(); err != nil {
+		return err
+	}
+
+	bal, err := balancer.New(f.ctx, f.conf, f.eth)
+	if err != nil {
+		return err
+	}
+
+	defer bal.Close()
+
+	f.balancer = bal
+
+	for _, endpoint := range bal.Endpoints() {
+		f.senders = append(f.senders, txsender.New(f.ctx, f.log, endpoint.Client))
+	}
+
+	f.log.Info("Searching for the maximum sustainable TPS",
+		"start_tps", f.conf.TxPerSec,
+		"max_tps", f.conf.Search.MaxTxPerSec,
+		"soak_sec", f.conf.Search.SoakSec,
+		"accounts", len(f.signers),
+		"workload", f.conf.Workload,
+		"workers", f.conf.Workers,
+		"endpoints", len(f.senders),
+	)
+
+	srch := newSearch(f.conf.TxPerSec, f.conf.Search.MaxTxPerSec, f.conf.Search.Precision)
+
+	for rate, ok := srch.Next(); ok; rate, ok = srch.Next() {
+		if err := f.refreshNonces(); err != nil {
+			return err
+		}
+
+		result, err := f.trial(rate)
+		if err != nil {
+			return err
+		}
+
+		// an interrupted trial says nothing about the rate
+		if f.ctx.Err() != nil {
+			f.log.Info("Search interrupted", "tps", rate)
+			break
+		}
+
+		result.evaluate(f.conf.Search)
+		f.trials = append(f.trials, result)
+		srch.Record(rate, result.passed())
+
+		f.log.Info("Rate tried",
+			"tps", rate,
+			"achieved_tps", fmt.Sprintf("%.2f", result.achieved),
+			"errors", result.errors,
+			"p90_latency", result.latencyP90.String(),
+			"passed", result.passed(),
+			"reason", result.failure,
+		)
+
+		if !result.passed() {
+			f.cooldown()
+		}
+	}
+
+	f.outputResult(srch.Result())
+
+	return nil
+}
+
+func (f *FindMaxTPS) initSigners() error {
+	accounts := 1
+	if f.conf.Mnemonic != "" {
+		accounts = f.conf.TotalAccounts
+	}
+
+	for ind := range make([]struct{}, accounts) {
+		signer := txsigner.New(f.ctx, f.log, f.eth, f.conf)
+
+		if err := signer.SetPrivateKey(txsigner.WithNumberOfAccounts(ind)); err != nil {
+			return fmt.Errorf("could not set private key: %w", err)
+		}
+
+		if err := signer.SetToAddress(f.conf.ToAddress); err != nil {
+			return fmt.Errorf("could not set to address: %w", err)
+		}
+
+		f.signers = append(f.signers, signer)
+	}
+
+	wl, err := workload.New(f.ctx, f.log, f.eth, f.conf)
+	if err != nil {
+		return err
+	}
+
+	if err = wl.Setup(f.signers); err != nil {
+		return fmt.Errorf("could not set up workload: %w", err)
+	}
+
+	f.workload = wl
+	f.nonces = make([]uint64, len(f.signers))
+
+	return nil
+}
+
+// refreshNonces fetches the pending nonces, so that a trial never waits on a gap left by the previous one
+func (f *FindMaxTPS) refreshNonces() error {
+	for ind, signer := range f.signers {
+		nonce, err := signer.GetFreshNonce()
+		if err != nil {
+			return fmt.Errorf("could not fetch nonce: %w", err)
+		}
+
+		f.nonces[ind] = nonce
+	}
+
+	return nil
+}
+
+// cooldown gives the chain time to clear the backlog of a failed rate
+func (f *FindMaxTPS) cooldown() {
+	if f.conf.Search.CooldownSec < 1 {
+		return
+	}
+
+	f.log.Info("Backing off", "cooldown_sec", f.conf.Search.CooldownSec)
+
+	select {
+	case <-time.After(time.Duration(f.conf.Search.CooldownSec) * time.Second):
+	case <-f.ctx.Done():
+	}
+}
+
+func (f *FindMaxTPS) outputResult(maxRate int64) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"TPS", "SENT", "ACHIEVED_TPS", "ERRORS", "P90_LATENCY", "PENDING_GROWTH", "RESULT"})
+
+	for _, trial := range f.trials {
+		result := "PASS"
+		if !trial.passed() {
+			result = "FAIL: " + trial.failure
+		}
+
+		latency := trial.latencyP90.String()
+		if trial.latencyP90 == notIncluded {
+			latency = "not included"
+		}
+
+		pendingGrowth := "n/a"
+		if trial.pendingKnown {
+			pendingGrowth = fmt.Sprintf("%d", trial.pendingGrowth)
+		}
+
+		table.Append([]string{
+			fmt.Sprintf("%d", trial.rate),
+			fmt.Sprintf("%d", trial.sent),
+			fmt.Sprintf("%.2f", trial.achieved),
+			fmt.Sprintf("%d", trial.errors),
+			latency,
+			pendingGrowth,
+			result,
+		})
+	}
+
+	table.SetFooter([]string{"", "", "", "", "", "MAX SUSTAINABLE TPS", fmt.Sprintf("%d", maxRate)})
+	table.SetFooterColor(tablewriter.Colors{}, tablewriter.Colors{}, tablewriter.Colors{}, tablewriter.Colors{},
+		tablewriter.Colors{}, tablewriter.Colors{tablewriter.Bold}, tablewriter.Colors{tablewriter.BgGreenColor},
+	)
+
+	table.Render()
+
+	f.log.Info("Maximum sustainable TPS found", "tps", maxRate, "soak_sec", f.conf.Search.SoakSec)
+}
@@ -0,0 +1,53 @@
+package findmaxtps
+
+// search finds the highest passing rate, doubling the rate until it fails
+// and then bisecting between the highest passing and the lowest failing rate
+type search struct {
+	maxRate   int64
+	precision int64
+
+	// passed is the highest passing rate, zero until a rate passes
+	passed int64
+	// failed is the lowest failing rate, zero until a rate fails
+	failed int64
+	next   int64
+}
+
+func newSearch(startRate, maxRate, precision int64) *search {
+	return &search{
+		maxRate:   maxRate,
+		precision: precision,
+		next:      startRate,
+	}
+}
+
+// Next returns the next rate to try, false when the search is over
+func (s *search) Next() (int64, bool) {
+	return s.next, s.next != 0
+}
+
+// Record records the outcome of the last rate and picks the next one
+func (s *search) Record(rate int64, pass bool) {
+	if pass {
+		s.passed = max(s.passed, rate)
+	} else if s.failed == 0 || rate < s.failed {
+		s.failed = rate
+	}
+
+	switch {
+	case s.failed == 0 && s.passed >= s.maxRate:
+		// the chain sustains the highest allowed rate
+		s.next = 0
+	case s.failed == 0:
+		s.next = min(s.passed*2, s.maxRate)
+	case s.failed-s.passed <= s.precision:
+		s.next = 0
+	default:
+		s.next = s.passed + (s.failed-s.passed)/2
+	}
+}
+
+// Result returns the highest rate that passed
+func (s *search) Result() int64 {
+	return s.passed
+}
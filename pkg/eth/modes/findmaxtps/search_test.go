@@ -0,0 +1,76 @@
+package findmaxtps
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		start     int64
+		maxRate   int64
+		precision int64
+		// sustained is the highest rate the simulated chain sustains
+		sustained int64
+		want      int64
+		tried     []int64
+	}{
+		{
+			name:      "Converges between doubling steps",
+			start:     100,
+			maxRate:   10000,
+			precision: 10,
+			sustained: 730,
+			want:      725,
+			tried:     []int64{100, 200, 400, 800, 600, 700, 750, 725, 737, 731},
+		},
+		{
+			name:      "Sustains the highest allowed rate",
+			start:     100,
+			maxRate:   300,
+			precision: 10,
+			sustained: 1000,
+			want:      300,
+			tried:     []int64{100, 200, 300},
+		},
+		{
+			name:      "Starting rate fails",
+			start:     100,
+			maxRate:   1000,
+			precision: 20,
+			sustained: 30,
+			want:      25,
+			tried:     []int64{100, 50, 25, 37},
+		},
+		{
+			name:      "Nothing is sustained",
+			start:     10,
+			maxRate:   100,
+			precision: 5,
+			sustained: 0,
+			want:      0,
+			tried:     []int64{10, 5},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSearch(tc.start, tc.maxRate, tc.precision)
+			tried := make([]int64, 0)
+
+			for rate, ok := s.Next(); ok; rate, ok = s.Next() {
+				tried = append(tried, rate)
+				s.Record(rate, rate <= tc.sustained)
+
+				if len(tried) > 100 {
+					t.Fatal("search did not converge")
+				}
+			}
+
+			assert.Equal(t, tc.tried, tried)
+			assert.Equal(t, tc.want, s.Result())
+		})
+	}
+}
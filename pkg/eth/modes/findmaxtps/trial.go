@@ -0,0 +1,287 @@
+package findmaxtps
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/inclusion"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/loadprofile"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/presign"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/scheduler"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/workload"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// minAchievedRatio is the lowest share of the tried rate the sender must achieve for the rate to pass
+	minAchievedRatio = 0.95
+	// notIncluded is the latency of a trial whose transactions were not included in time
+	notIncluded = time.Duration(math.MaxInt64)
+)
+
+type trialResult struct {
+	rate     int64
+	sent     int64
+	errors   int64
+	achieved float64
+
+	latencyP90 time.Duration
+
+	pendingKnown  bool
+	pendingGrowth int64
+
+	// failure is the reason the rate failed, empty if the rate passed
+	failure string
+}
+
+func (r *trialResult) passed() bool {
+	return r.failure == ""
+}
+
+// evaluate checks the trial measurements against the thresholds of a sustainable rate
+func (r *trialResult) evaluate(thresholds conf.Search) {
+	var (
+		attempts  = r.sent + r.errors
+		errorRate float64
+	)
+
+	if attempts != 0 {
+		errorRate = float64(r.errors) / float64(attempts) * 100
+	}
+
+	switch {
+	case errorRate > thresholds.MaxErrorRate:
+		r.failure = fmt.Sprintf("error rate %.2f%% above %.2f%%", errorRate, thresholds.MaxErrorRate)
+	case r.achieved < float64(r.rate)*minAchievedRatio:
+		r.failure = fmt.Sprintf("achieved %.2f tps", r.achieved)
+	case r.latencyP90 == notIncluded:
+		r.failure = fmt.Sprintf("transactions not included within %ds", thresholds.MaxLatencySec)
+	case r.latencyP90 > time.Duration(thresholds.MaxLatencySec)*time.Second:
+		r.failure = fmt.Sprintf("p90 latency above %ds", thresholds.MaxLatencySec)
+	case r.pendingKnown && float64(r.pendingGrowth) > float64(r.sent)*thresholds.MaxPendingGrowth/100:
+		r.failure = fmt.Sprintf("pending pool grew by %d", r.pendingGrowth)
+	}
+}
+
+// trial sends the rate for the soak window and measures how the chain kept up with it.
+// The sends are scheduled open loop on the workers, so a slow send delays the others by its lag and not its duration
+func (f *FindMaxTPS) trial(rate int64) (trialResult, error) {
+	var (
+		result = trialResult{rate: rate}
+		soak   = time.Duration(f.conf.Search.SoakSec) * time.Second
+		// fetchPendingNonce is set for the signers whose transaction failed
+		fetchPendingNonce = make([]atomic.Bool, len(f.signers))
+	)
+
+	f.prom.SetTxNumberPerInterval(float64(rate))
+
+	// the pre-signed transactions of every signer start at its current nonce
+	firstNonces := slices.Clone(f.nonces)
+
+	pool, presigned, err := f.presign(rate)
+	if err != nil {
+		return result, err
+	}
+
+	if pool != nil {
+		defer func() {
+			f.release(pool, firstNonces, presigned)
+
+			if err := pool.Close(); err != nil {
+				f.log.Error("Could not close pre-signed pool", "err", err.Error())
+			}
+		}()
+	}
+
+	// the tracker is single use, so every trial has its own, which does not outlive the soak to need evicting
+	tracker := inclusion.New(f.ctx, f.log, f.eth, f.prom, 0)
+	if err = tracker.Start(); err != nil {
+		return result, fmt.Errorf("could not start inclusion tracking: %w", err)
+	}
+
+	pendingBefore, poolErr := f.pendingTxs()
+
+	ctx, cancel := context.WithTimeout(f.ctx, soak)
+	defer cancel()
+
+	sched := scheduler.New(ctx, f.conf.Workers)
+
+	f.log.Info("Trying rate", "tps", rate, "soak_sec", f.conf.Search.SoakSec)
+
+	sched.Start()
+
+	for intervalStart := time.Now(); ctx.Err() == nil; intervalStart = intervalStart.Add(time.Second) {
+		for ind, intended := range scheduler.Spread(intervalStart, time.Second, rate) {
+			// the transactions are interleaved between the signers
+			signerInd := ind % len(f.signers)
+
+			if fetchPendingNonce[signerInd].CompareAndSwap(true, false) {
+				if nonce, err := f.signers[signerInd].GetFreshNonce(); err == nil {
+					f.nonces[signerInd] = nonce
+				} else {
+					f.log.Error("Could not fetch new nonce", "err", err.Error())
+					fetchPendingNonce[signerInd].Store(true)
+				}
+			}
+
+			tx, err := f.nextTx(pool, signerInd)
+			if err != nil {
+				sched.Stop()
+				tracker.Stop(0)
+
+				return result, err
+			}
+
+			f.nonces[signerInd]++
+
+			endpoint := f.balancer.Next(signerInd)
+
+			scheduled := sched.Schedule(intended, 1, func(lag time.Duration) int {
+				if err := f.send(tracker, tx, signerInd, endpoint, lag); err != nil {
+					fetchPendingNonce[signerInd].Store(true)
+					return 1
+				}
+
+				return 0
+			})
+			if !scheduled {
+				// the next trial starts from the pending nonces, so the gap left by the unsent transaction is filled
+				workload.SendFailed(f.workload, signerInd, tx.Data())
+
+				break
+			}
+		}
+	}
+
+	schedule := sched.Stop()
+
+	result.sent = schedule.Completed
+	result.errors = schedule.Failed
+	result.achieved = schedule.AchievedRate
+
+	if poolErr == nil {
+		if pendingAfter, err := f.pendingTxs(); err == nil {
+			result.pendingKnown = true
+			result.pendingGrowth = int64(pendingAfter) - int64(pendingBefore)
+		}
+	} else {
+		f.log.Debug("Pending pool growth not measured", "err", poolErr.Error())
+	}
+
+	result.latencyP90 = inclusionP90(tracker.Stop(time.Duration(f.conf.Search.MaxLatencySec) * time.Second))
+
+	f.log.Debug("Trial schedule", "tps", rate, "p99_lag", schedule.Lag.P99.String(), "max_lag", schedule.Lag.Max.String())
+
+	return result, nil
+}
+
+// presign signs the transactions of every signer for the trial of the rate, up to the configured number,
+// and returns the number signed for every signer. It returns a nil pool if pre-signing is disabled
+func (f *FindMaxTPS) presign(rate int64) (presign.Pool, uint64, error) {
+	if f.conf.Presign == 0 {
+		return nil, 0, nil
+	}
+
+	pool, err := presign.New(f.conf, len(f.signers))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// no more transactions are signed than a signer sends in the trial
+	perAccount := min(int64(f.conf.Presign), loadprofile.SignerShare(rate*f.conf.Search.SoakSec, len(f.signers), 0))
+
+	if err = presign.Fill(f.ctx, f.log, pool, f.signers, f.nonces, int(perAccount), f.workload.NextCall); err != nil {
+		_ = pool.Close()
+		return nil, 0, fmt.Errorf("could not pre-sign transactions: %w", err)
+	}
+
+	return pool, uint64(perAccount), nil
+}
+
+// nextTx takes the transaction with the next nonce of the signer from the pre-signed pool,
+// and signs it if the pool does not hold it
+func (f *FindMaxTPS) nextTx(pool presign.Pool, signerInd int) (*types.Transaction, error) {
+	if pool != nil {
+		tx, err := pool.Take(signerInd, f.nonces[signerInd])
+		if err != nil {
+			f.log.Error("Could not take pre-signed transaction", "err", err.Error(), "from", f.signers[signerInd].GetFromAddress())
+		}
+
+		if tx != nil {
+			return tx, nil
+		}
+	}
+
+	return f.signers[signerInd].GetNextSignedCall(f.nonces[signerInd], f.workload.NextCall(signerInd))
+}
+
+// release hands the calls of the pre-signed transactions the trial did not send back to the workload,
+// the count transactions of every signer were signed from its first nonce
+func (f *FindMaxTPS) release(pool presign.Pool, firstNonces []uint64, count uint64) {
+	for ind := range f.signers {
+		for nonce := f.nonces[ind]; nonce < firstNonces[ind]+count; nonce++ {
+			tx, err := pool.Take(ind, nonce)
+			if err != nil {
+				break
+			}
+
+			if tx != nil {
+				workload.SendFailed(f.workload, ind, tx.Data())
+			}
+		}
+	}
+}
+
+func (f *FindMaxTPS) send(tracker *inclusion.Tracker, tx *types.Transaction, signerInd, endpoint int, lag time.Duration) error {
+	sendStart := time.Now()
+	tracker.Track(tx.Hash(), sendStart)
+
+	_, err := f.senders[endpoint].SendSignedTransaction(tx)
+	duration := time.Since(sendStart)
+
+	if err != nil {
+		f.prom.IncreaseTxErrorCount()
+		f.balancer.Record(endpoint, duration, 1, 1)
+		tracker.Forget(tx.Hash())
+		workload.SendFailed(f.workload, signerInd, tx.Data())
+
+		f.log.Debug("Transaction send error", "err", err.Error(), "from", f.signers[signerInd].GetFromAddress(), "nonce", tx.Nonce())
+
+		return err
+	}
+
+	f.prom.ObserveTxRequestDuration(float64(duration.Milliseconds()))
+	f.prom.ObserveTxScheduleLag(float64(lag.Milliseconds()))
+	f.balancer.Record(endpoint, duration, 1, 0)
+
+	return nil
+}
+
+// inclusionP90 returns the p90 inclusion latency of the included transactions,
+// or notIncluded if more than a tenth of the tracked transactions were not included in time
+func inclusionP90(report inclusion.Report) time.Duration {
+	if report.NotIncluded*10 > report.NotIncluded+report.Latency.Count {
+		return notIncluded
+	}
+
+	return report.Latency.P90
+}
+
+// pendingTxs returns the number of pending transactions in the pool of the node, if the node exposes it
+func (f *FindMaxTPS) pendingTxs() (uint64, error) {
+	var status struct {
+		Pending hexutil.Uint64 `json:"pending"`
+	}
+
+	if err := f.eth.Client().CallContext(f.ctx, &status, "txpool_status"); err != nil {
+		return 0, fmt.Errorf("could not get txpool status: %w", err)
+	}
+
+	return uint64(status.Pending), nil
+}
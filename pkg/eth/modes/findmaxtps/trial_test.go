@@ -0,0 +1,111 @@
+package findmaxtps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/inclusion"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/latency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrialResult_evaluate(t *testing.T) {
+	var thresholds = conf.Search{
+		MaxLatencySec:    10,
+		MaxErrorRate:     1,
+		MaxPendingGrowth: 10,
+	}
+
+	var testCases = []struct {
+		name   string
+		result trialResult
+		passed bool
+	}{
+		{
+			name:   "Sustained rate",
+			result: trialResult{rate: 100, sent: 6000, achieved: 99.5, latencyP90: 4 * time.Second, pendingKnown: true, pendingGrowth: 200},
+			passed: true,
+		},
+		{
+			name:   "Too many send errors",
+			result: trialResult{rate: 100, sent: 5900, errors: 100, achieved: 98, latencyP90: time.Second},
+			passed: false,
+		},
+		{
+			name:   "Rate not achieved",
+			result: trialResult{rate: 100, sent: 5000, achieved: 83, latencyP90: time.Second},
+			passed: false,
+		},
+		{
+			name:   "Inclusion latency too high",
+			result: trialResult{rate: 100, sent: 6000, achieved: 100, latencyP90: 11 * time.Second},
+			passed: false,
+		},
+		{
+			name:   "Transactions not included",
+			result: trialResult{rate: 100, sent: 6000, achieved: 100, latencyP90: notIncluded},
+			passed: false,
+		},
+		{
+			name:   "Pending pool growing",
+			result: trialResult{rate: 100, sent: 6000, achieved: 100, latencyP90: time.Second, pendingKnown: true, pendingGrowth: 601},
+			passed: false,
+		},
+		{
+			name:   "Pending pool not exposed",
+			result: trialResult{rate: 100, sent: 6000, achieved: 100, latencyP90: time.Second, pendingGrowth: 6000},
+			passed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.result.evaluate(thresholds)
+
+			assert.Equal(t, tc.passed, tc.result.passed(), tc.result.failure)
+		})
+	}
+}
+
+func TestInclusionP90(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		included    int64
+		notIncluded int64
+		expected    time.Duration
+	}{
+		{
+			name:     "All included",
+			included: 100,
+			expected: 4 * time.Second,
+		},
+		{
+			name:        "A tenth not included",
+			included:    90,
+			notIncluded: 10,
+			expected:    4 * time.Second,
+		},
+		{
+			name:        "More than a tenth not included",
+			included:    89,
+			notIncluded: 11,
+			expected:    notIncluded,
+		},
+		{
+			name:     "Nothing sent",
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := inclusion.Report{NotIncluded: tc.notIncluded}
+			if tc.included != 0 {
+				report.Latency = latency.Summary{Count: tc.included, P90: 4 * time.Second}
+			}
+
+			assert.Equal(t, tc.expected, inclusionP90(report))
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package getblocks
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txbreakdown"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/sync/errgroup"
+)
+
+// rpcReceipt holds the receipt fields of the breakdown, the node returns the sender and the recipient with them
+type rpcReceipt struct {
+	TxHash          common.Hash     `json:"transactionHash"`
+	From            common.Address  `json:"from"`
+	To              *common.Address `json:"to"`
+	ContractAddress *common.Address `json:"contractAddress"`
+	Type            hexutil.Uint64  `json:"type"`
+	GasUsed         hexutil.Uint64  `json:"gasUsed"`
+}
+
+// fetchBreakdown fetches the receipts of the last fetched blocks and returns their breakdown,
+// with the recipients checked for contract code
+func (g *GetBlocks) fetchBreakdown() (*txbreakdown.Breakdown, error) {
+	receipts, err := g.FetchReceipts(g.blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown := txbreakdown.Calculate(receipts, g.conf.BreakdownTop)
+
+	if err = breakdown.Label(func(address string) (bool, error) {
+		code, err := g.eth.CodeAt(g.ctx, common.HexToAddress(address), nil)
+		return len(code) != 0, err
+	}); err != nil {
+		return nil, err
+	}
+
+	return &breakdown, nil
+}
+
+// FetchReceipts fetches the receipts of the blocks, by the block hashes so that they match the blocks
+// even if they were replaced since. The blocks are fetched by a bounded number of workers, and a failed block
+// is retried with an exponential backoff. The cached receipts are read from the block cache opened by FetchBlocks,
+// and the fetched ones are cached
+func (g *GetBlocks) FetchReceipts(blocks []types.BlockInfo) ([]types.Receipt, error) {
+	var (
+		cache    = g.cache
+		receipts = make([][]types.Receipt, len(blocks))
+	)
+
+	eg, ctx := errgroup.WithContext(g.ctx)
+	eg.SetLimit(g.conf.Fetch.Workers)
+
+	for ind, block := range blocks {
+		if block.TransactionNum == 0 {
+			continue
+		}
+
+		if cache != nil {
+			if cached, ok := cache.Receipts(block); ok {
+				receipts[ind] = cached
+				continue
+			}
+		}
+
+		// every block fills its own receipts, so they are ordered without locking
+		ind, block := ind, block
+
+		eg.Go(func() error {
+			err := g.withRetries(ctx, "receipts", block.Number, block.Number, func() error {
+				var err error
+				receipts[ind], err = g.fetchReceipts(ctx, block)
+
+				return err
+			})
+			if err != nil {
+				return err
+			}
+
+			if cache != nil {
+				if err = cache.PutReceipts(block, receipts[ind]); err != nil {
+					g.log.Warn("Could not write block cache", "err", err.Error())
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		if err := cache.Flush(); err != nil {
+			g.log.Warn("Could not write block cache", "err", err.Error())
+		}
+	}
+
+	all := make([]types.Receipt, 0)
+	for _, blockReceipts := range receipts {
+		all = append(all, blockReceipts...)
+	}
+
+	return all, nil
+}
+
+// fetchReceipts returns the receipts of the block with eth_getBlockReceipts, or if the node does not serve it,
+// the receipts of every transaction of the block
+func (g *GetBlocks) fetchReceipts(ctx context.Context, block types.BlockInfo) ([]types.Receipt, error) {
+	var receipts []*rpcReceipt
+	if err := g.receipts.ByHash(ctx, common.HexToHash(block.Hash), nil, &receipts); err != nil {
+		return nil, fmt.Errorf("block %d: %w", block.Number, err)
+	}
+
+	return receiptsOf(block, receipts)
+}
+
+// receiptsOf converts the receipts of the block, a missing receipt means the block is not served yet
+func receiptsOf(block types.BlockInfo, receipts []*rpcReceipt) ([]types.Receipt, error) {
+	if len(receipts) != block.TransactionNum {
+		return nil, fmt.Errorf("block %d has %d receipts of %d transactions: %w", block.Number, len(receipts), block.TransactionNum, ethereum.NotFound)
+	}
+
+	result := make([]types.Receipt, 0, len(receipts))
+
+	for _, receipt := range receipts {
+		if receipt == nil {
+			return nil, fmt.Errorf("block %d: %w", block.Number, ethereum.NotFound)
+		}
+
+		converted := types.Receipt{
+			TxHash:  receipt.TxHash.String(),
+			From:    receipt.From.String(),
+			Type:    uint8(receipt.Type),
+			GasUsed: uint64(receipt.GasUsed),
+		}
+
+		if receipt.To != nil {
+			converted.To = receipt.To.String()
+		}
+
+		if receipt.ContractAddress != nil && *receipt.ContractAddress != (common.Address{}) {
+			converted.ContractAddress = receipt.ContractAddress.String()
+		}
+
+		result = append(result, converted)
+	}
+
+	return result, nil
+}
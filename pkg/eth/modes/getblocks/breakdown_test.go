@@ -0,0 +1,182 @@
+package getblocks
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txbreakdown"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	sender   = common.Address{1}
+	contract = common.Address{2}
+	account  = common.Address{3}
+	created  = common.Address{4}
+)
+
+// txReceiptsAPI serves the receipts of two blocks by transaction, the first block has a contract call
+// and a transfer, the second one creates a contract
+type txReceiptsAPI struct {
+	receipts map[common.Hash][]*rpcReceipt
+	// receiptCalls counts the fetched receipts
+	receiptCalls atomic.Int64
+}
+
+// blockReceiptsAPI is a txReceiptsAPI that also serves the receipts by block
+type blockReceiptsAPI struct {
+	*txReceiptsAPI
+}
+
+func newTxReceiptsAPI() *txReceiptsAPI {
+	return &txReceiptsAPI{receipts: map[common.Hash][]*rpcReceipt{
+		{1}: {
+			{TxHash: common.Hash{11}, From: sender, To: &contract, Type: 2, GasUsed: 60000},
+			{TxHash: common.Hash{12}, From: sender, To: &account, Type: 0, GasUsed: 21000},
+		},
+		{2}: {
+			{TxHash: common.Hash{21}, From: sender, ContractAddress: &created, Type: 2, GasUsed: 119000},
+		},
+	}}
+}
+
+func (a *txReceiptsAPI) GetBlockByHash(hash common.Hash, _ bool) map[string][]common.Hash {
+	receipts, ok := a.receipts[hash]
+	if !ok {
+		return nil
+	}
+
+	txs := make([]common.Hash, 0, len(receipts))
+	for _, receipt := range receipts {
+		txs = append(txs, receipt.TxHash)
+	}
+
+	return map[string][]common.Hash{"transactions": txs}
+}
+
+func (a *txReceiptsAPI) GetTransactionReceipt(hash common.Hash) *rpcReceipt {
+	a.receiptCalls.Add(1)
+
+	for _, receipts := range a.receipts {
+		for _, receipt := range receipts {
+			if receipt.TxHash == hash {
+				return receipt
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *txReceiptsAPI) GetCode(address common.Address, _ string) hexutil.Bytes {
+	if address == contract || address == created {
+		return hexutil.Bytes{0x60}
+	}
+
+	return hexutil.Bytes{}
+}
+
+func (a *blockReceiptsAPI) GetBlockReceipts(hash common.Hash) []*rpcReceipt {
+	a.receiptCalls.Add(int64(len(a.receipts[hash])))
+
+	return a.receipts[hash]
+}
+
+func TestGetBlocks_FetchBreakdown(t *testing.T) {
+	var tests = []struct {
+		name          string
+		blockReceipts bool
+		batchSize     int
+	}{
+		{
+			name:          "Receipts by block",
+			blockReceipts: true,
+		},
+		{
+			name:      "Batched receipts by transaction",
+			batchSize: 16,
+		},
+		{
+			name:      "Single receipts by transaction",
+			batchSize: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				api = newTxReceiptsAPI()
+				srv = rpc.NewServer()
+			)
+
+			var service interface{} = api
+			if tc.blockReceipts {
+				service = &blockReceiptsAPI{api}
+			}
+
+			require.NoError(t, srv.RegisterName("eth", service))
+			defer srv.Stop()
+
+			g := New(context.Background(), logger.NewZapLogger(), ethclient.NewClient(rpc.DialInProc(srv)), conf.Conf{
+				Fetch:        conf.Fetch{BatchSize: tc.batchSize},
+				Breakdown:    true,
+				BreakdownTop: 2,
+			})
+
+			// the empty block is not fetched
+			g.blocks = []types.BlockInfo{
+				{Number: 1, Hash: common.Hash{1}.Hex(), TransactionNum: 2},
+				{Number: 2, Hash: common.Hash{2}.Hex(), TransactionNum: 1},
+				{Number: 3, Hash: common.Hash{3}.Hex()},
+			}
+
+			breakdown, err := g.fetchBreakdown()
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(3), api.receiptCalls.Load())
+			assert.Equal(t, !tc.blockReceipts, g.receipts.Unsupported())
+
+			assert.Equal(t, 3, breakdown.Transactions)
+			assert.Equal(t, []txbreakdown.Entry{
+				{Name: sender.Hex(), Txs: 3, GasUsed: 200000, Share: 100},
+			}, breakdown.Senders)
+
+			// the contracts are told from the accounts by their code
+			assert.Equal(t, []txbreakdown.Entry{
+				{Name: created.Hex(), Contract: true, Txs: 1, GasUsed: 119000, Share: 59.5},
+				{Name: contract.Hex(), Contract: true, Txs: 1, GasUsed: 60000, Share: 30},
+			}, breakdown.Gas)
+
+			require.Len(t, breakdown.Types, 2)
+			assert.Equal(t, "dynamic", breakdown.Types[0].Name)
+			assert.InDelta(t, 66.67, breakdown.Types[0].Share, 0.01)
+			assert.Equal(t, "legacy", breakdown.Types[1].Name)
+			assert.InDelta(t, 33.33, breakdown.Types[1].Share, 0.01)
+		})
+	}
+}
+
+func TestGetBlocks_FetchReceiptsMissing(t *testing.T) {
+	var (
+		api = newTxReceiptsAPI()
+		srv = rpc.NewServer()
+	)
+
+	require.NoError(t, srv.RegisterName("eth", &blockReceiptsAPI{api}))
+	defer srv.Stop()
+
+	g := New(context.Background(), logger.NewZapLogger(), ethclient.NewClient(rpc.DialInProc(srv)), conf.Conf{})
+
+	// the node does not serve the block yet
+	_, err := g.FetchReceipts([]types.BlockInfo{{Number: 5, Hash: common.Hash{5}.Hex(), TransactionNum: 1}})
+	assert.Error(t, err)
+}
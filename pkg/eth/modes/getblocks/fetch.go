@@ -0,0 +1,223 @@
+package getblocks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockcache"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/briandowns/spinner"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// retryBackoff is the wait before the first retry of a failed block request, doubled with every retry
+	retryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff is the longest wait between the retries of a failed block request
+	maxRetryBackoff = 10 * time.Second
+)
+
+// rpcHeader holds the block fields of the report, the transactions are only hashes, which are counted
+type rpcHeader struct {
+	Hash         common.Hash    `json:"hash"`
+	Number       hexutil.Uint64 `json:"number"`
+	GasLimit     hexutil.Uint64 `json:"gasLimit"`
+	GasUsed      hexutil.Uint64 `json:"gasUsed"`
+	Time         hexutil.Uint64 `json:"timestamp"`
+	Transactions []common.Hash  `json:"transactions"`
+}
+
+// FetchBlocks fetches the blocks from the start to the end block, and returns them ordered by number.
+// Only the block headers and the transaction hashes are fetched, in batches of blocks requested by a bounded
+// number of workers, and a failed batch is retried with an exponential backoff.
+// The cached blocks are read from the block cache, and the fetched ones are cached
+func (g *GetBlocks) FetchBlocks(startBlock, endBlock int64) ([]types.BlockInfo, error) {
+	g.blocks = make([]types.BlockInfo, max(endBlock-startBlock+1, 0))
+
+	// the spinner is kept out of stdout, which can hold a machine-readable report
+	s := spinner.New(spinner.CharSets[35], 500*time.Millisecond, spinner.WithWriter(os.Stderr))
+	s.Start()
+
+	defer s.Stop()
+
+	// the cache is kept open for the receipts of the fetched blocks
+	g.cache = g.openCache()
+
+	var (
+		cache     = g.cache
+		cached    = make([]bool, len(g.blocks))
+		batchSize = int64(max(g.conf.Fetch.BatchSize, 1))
+	)
+
+	if cache != nil {
+		for ind := range g.blocks {
+			g.blocks[ind], cached[ind] = cache.Get(uint64(startBlock) + uint64(ind))
+		}
+	}
+
+	eg, ctx := errgroup.WithContext(g.ctx)
+	eg.SetLimit(g.conf.Fetch.Workers)
+
+	// every batch fetches consecutive blocks missing from the cache
+	for from := startBlock; from <= endBlock; from++ {
+		if cached[from-startBlock] {
+			continue
+		}
+
+		to := from
+		for to < endBlock && to-from+1 < batchSize && !cached[to+1-startBlock] {
+			to++
+		}
+
+		var (
+			// every batch fills its own blocks, so they are ordered without locking
+			blocks = g.blocks[from-startBlock : to-startBlock+1]
+			first  = uint64(from)
+		)
+
+		eg.Go(func() error {
+			return g.withRetries(ctx, "blocks", first, first+uint64(len(blocks))-1, func() error {
+				return g.fetchBlocks(ctx, first, blocks)
+			})
+		})
+
+		from = to
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		g.cacheBlocks(cache, cached)
+	}
+
+	return g.blocks, nil
+}
+
+// openCache opens the block cache, if it is configured, at the latest block.
+// The blocks are fetched without it if it can not be opened
+func (g *GetBlocks) openCache() *blockcache.Cache {
+	if g.conf.Fetch.CacheDir == "" {
+		return nil
+	}
+
+	cache, err := blockcache.Open(g.ctx, g.eth, g.conf.Fetch.CacheDir, uint64(g.conf.Fetch.CacheDepth))
+	if err != nil {
+		g.log.Warn("Could not open block cache, fetching all the blocks", "err", err.Error())
+		return nil
+	}
+
+	latestBlock, err := g.eth.BlockNumber(g.ctx)
+	if err != nil {
+		g.log.Warn("Could not get latest block, fetching all the blocks", "err", err.Error())
+		return nil
+	}
+
+	cache.SetHead(latestBlock)
+
+	return cache
+}
+
+// cacheBlocks caches the fetched blocks, the ones read from the cache are left as they are
+func (g *GetBlocks) cacheBlocks(cache *blockcache.Cache, cached []bool) {
+	hits := 0
+
+	for ind, block := range g.blocks {
+		if cached[ind] {
+			hits++
+			continue
+		}
+
+		cache.Put(block)
+	}
+
+	if err := cache.Flush(); err != nil {
+		g.log.Warn("Could not write block cache", "err", err.Error())
+	}
+
+	g.log.Debug("Blocks read from cache", "cached", hits, "fetched", len(g.blocks)-hits)
+}
+
+// withRetries fetches the blocks, or their receipts, from the number to the number,
+// retrying with an exponential backoff
+func (g *GetBlocks) withRetries(ctx context.Context, what string, from, to uint64, fetch func() error) error {
+	backoff := retryBackoff
+
+	for retry := 0; ; retry++ {
+		err := fetch()
+		if err == nil {
+			return nil
+		}
+
+		if retry == g.conf.Fetch.Retries || ctx.Err() != nil {
+			g.log.Error(fmt.Sprintf("Could not fetch %s", what), "from", from, "to", to, "err", err.Error())
+			return fmt.Errorf("could not fetch %s %d - %d: %w", what, from, to, err)
+		}
+
+		g.log.Debug(fmt.Sprintf("Could not fetch %s, retrying", what), "from", from, "to", to, "backoff", backoff, "err", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// fetchBlocks fetches the header and the transaction hashes of the blocks from the number on,
+// in a single batch request if batching is enabled
+func (g *GetBlocks) fetchBlocks(ctx context.Context, from uint64, blocks []types.BlockInfo) error {
+	var (
+		headers = make([]*rpcHeader, len(blocks))
+		elems   = make([]rpc.BatchElem, 0, len(blocks))
+	)
+
+	for ind := range blocks {
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{hexutil.EncodeUint64(from + uint64(ind)), false},
+			Result: &headers[ind],
+		})
+	}
+
+	if g.conf.Fetch.BatchSize < 2 {
+		for ind := range elems {
+			elems[ind].Error = g.eth.Client().CallContext(ctx, elems[ind].Result, elems[ind].Method, elems[ind].Args...)
+		}
+	} else if err := g.eth.Client().BatchCallContext(ctx, elems); err != nil {
+		return err
+	}
+
+	for _, elem := range elems {
+		if elem.Error != nil {
+			return elem.Error
+		}
+	}
+
+	for ind, header := range headers {
+		// the node can report a latest block before it serves it
+		if header == nil {
+			return fmt.Errorf("block %d: %w", from+uint64(ind), ethereum.NotFound)
+		}
+
+		blocks[ind] = types.BlockInfo{
+			TransactionNum: len(header.Transactions),
+			GasLimit:       uint64(header.GasLimit),
+			GasUsed:        uint64(header.GasUsed),
+			Hash:           header.Hash.String(),
+			Number:         uint64(header.Number),
+			Time:           uint64(header.Time),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package getblocks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blocksAPI is a headersAPI that also serves the transaction hashes, a block has as many transactions as its number
+type blocksAPI struct {
+	*headersAPI
+
+	mux sync.Mutex
+	// failures are the numbers of times a block fails, before it is served
+	failures map[uint64]int
+
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+}
+
+func (a *blocksAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1337))
+}
+
+func (a *blocksAPI) GetBlockByNumber(number hexutil.Uint64, fullTx bool) (map[string]interface{}, error) {
+	inFlight := a.inFlight.Add(1)
+	defer a.inFlight.Add(-1)
+
+	for current := a.maxInFlight.Load(); inFlight > current && !a.maxInFlight.CompareAndSwap(current, inFlight); {
+		current = a.maxInFlight.Load()
+	}
+
+	time.Sleep(time.Millisecond)
+
+	a.mux.Lock()
+	if a.failures[uint64(number)] > 0 {
+		a.failures[uint64(number)]--
+		a.mux.Unlock()
+
+		return nil, errors.New("too many requests")
+	}
+	a.mux.Unlock()
+
+	header := a.headersAPI.GetBlockByNumber(number, fullTx)
+	if header == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var block map[string]interface{}
+	if err = json.Unmarshal(raw, &block); err != nil {
+		return nil, err
+	}
+
+	txs := make([]common.Hash, number)
+	for ind := range txs {
+		txs[ind] = common.BigToHash(big.NewInt(int64(number)<<16 + int64(ind)))
+	}
+
+	block["transactions"] = txs
+
+	return block, nil
+}
+
+func TestGetBlocks_FetchBlocks(t *testing.T) {
+	var tests = []struct {
+		name     string
+		fetch    conf.Fetch
+		failures map[uint64]int
+		err      bool
+	}{
+		{
+			name:  "Batched requests",
+			fetch: conf.Fetch{Workers: 2, BatchSize: 16},
+		},
+		{
+			name:  "Single requests",
+			fetch: conf.Fetch{Workers: 4, BatchSize: 1},
+		},
+		{
+			name:     "Failed requests retried",
+			fetch:    conf.Fetch{Workers: 2, BatchSize: 16, Retries: 2},
+			failures: map[uint64]int{40: 2},
+		},
+		{
+			name:     "Retries exhausted",
+			fetch:    conf.Fetch{Workers: 2, BatchSize: 16, Retries: 1},
+			failures: map[uint64]int{40: 2},
+			err:      true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				times = make([]uint64, 100)
+				api   = &blocksAPI{headersAPI: &headersAPI{times: times}, failures: tc.failures}
+				srv   = rpc.NewServer()
+			)
+
+			for ind := range times {
+				times[ind] = 1000 + uint64(ind)*2
+			}
+
+			require.NoError(t, srv.RegisterName("eth", api))
+			defer srv.Stop()
+
+			g := New(context.Background(), logger.NewZapLogger(), ethclient.NewClient(rpc.DialInProc(srv)), conf.Conf{Fetch: tc.fetch})
+
+			blocks, err := g.FetchBlocks(10, 89)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, blocks, 80)
+
+			for ind, block := range blocks {
+				assert.Equal(t, uint64(10+ind), block.Number)
+				assert.Equal(t, 10+ind, block.TransactionNum)
+				assert.Equal(t, 1020+uint64(ind)*2, block.Time)
+				assert.NotEmpty(t, block.Hash)
+			}
+
+			// a batch is served by a single request, so the workers bound the concurrent requests
+			assert.LessOrEqual(t, api.maxInFlight.Load(), int64(tc.fetch.Workers))
+		})
+	}
+}
+
+func TestGetBlocks_FetchBlocksCached(t *testing.T) {
+	var (
+		times = make([]uint64, 100)
+		api   = &blocksAPI{headersAPI: &headersAPI{times: times}}
+		srv   = rpc.NewServer()
+		cnf   = conf.Conf{Fetch: conf.Fetch{Workers: 2, BatchSize: 16, CacheDir: t.TempDir(), CacheDepth: 20}}
+	)
+
+	for ind := range times {
+		times[ind] = 1000 + uint64(ind)*2
+	}
+
+	require.NoError(t, srv.RegisterName("eth", api))
+	defer srv.Stop()
+
+	g := New(context.Background(), logger.NewZapLogger(), ethclient.NewClient(rpc.DialInProc(srv)), cnf)
+
+	fetched, err := g.FetchBlocks(10, 89)
+	require.NoError(t, err)
+
+	// the genesis block of the cache, and the fetched blocks
+	assert.Equal(t, int64(81), api.headerCalls.Load())
+
+	api.headerCalls.Store(0)
+
+	cached, err := g.FetchBlocks(0, 89)
+	require.NoError(t, err)
+	require.Len(t, cached, 90)
+	assert.Equal(t, fetched, cached[10:])
+
+	// the blocks up to 79 are deep enough to be cached, the genesis block, the blocks before the first fetch
+	// and the last ten are fetched again
+	assert.Equal(t, int64(1+10+10), api.headerCalls.Load())
+}
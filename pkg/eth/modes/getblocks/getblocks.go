@@ -3,162 +3,220 @@ package getblocks
 import (
 	"context"
 	"fmt"
-	"math/big"
-	"os"
-	"runtime"
-	"sync"
+	"io"
 	"time"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockcache"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockreceipts"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockstats"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txbreakdown"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ZeljkoBenovic/tpser/pkg/htmlreport"
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
-	"github.com/briandowns/spinner"
+	"github.com/ZeljkoBenovic/tpser/pkg/output"
+	"github.com/ZeljkoBenovic/tpser/pkg/results"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/olekukonko/tablewriter"
-	"golang.org/x/sync/errgroup"
 )
 
+// blocksHeader is the header of the csv output, with a row per block
+var blocksHeader = []string{"timestamp", "number", "hash", "txs", "gas_limit", "gas_used"}
+
+// Report holds the fetched blocks, ordered by number, their statistics, and their breakdown if it is enabled
+type Report struct {
+	Blocks []types.BlockInfo `json:"blocks"`
+	blockstats.Stats
+	Breakdown *txbreakdown.Breakdown `json:"breakdown,omitempty"`
+}
+
 type GetBlocks struct {
 	ctx  context.Context
 	log  logger.Logger
 	eth  *ethclient.Client
 	conf conf.Conf
 
-	errGr  errgroup.Group
 	blocks []types.BlockInfo
-
-	mux sync.Mutex
+	// breakdown is the breakdown of the last fetched blocks, nil if it is not enabled
+	breakdown *txbreakdown.Breakdown
+	// cache is the block cache opened by the last FetchBlocks, nil if it is not configured
+	cache *blockcache.Cache
+	// receipts fetches the receipts of the blocks of the breakdown
+	receipts *blockreceipts.Fetcher
 }
 
-func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) *GetBlocks {
-	eg := errgroup.Group{}
-	eg.SetLimit(runtime.NumCPU() * 50)
+func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, cnf conf.Conf) *GetBlocks {
+	if cnf.Fetch.Workers < 1 {
+		cnf.Fetch.Workers = conf.DefaultFetchWorkers
+	}
+
+	log = log.Named("getblocks")
 
 	return &GetBlocks{
-		ctx:    ctx,
-		log:    log.Named("getblocks"),
-		eth:    eth,
-		conf:   conf,
-		errGr:  errgroup.Group{},
-		blocks: make([]types.BlockInfo, 0),
-		mux:    sync.Mutex{},
+		ctx:      ctx,
+		log:      log,
+		eth:      eth,
+		conf:     cnf,
+		blocks:   make([]types.BlockInfo, 0),
+		receipts: blockreceipts.New(log, eth.Client(), cnf.Fetch.BatchSize),
 	}
 }
 
 func (g *GetBlocks) RunMode() error {
-	startBlock := g.conf.Blocks.Start
-	endBlock := g.conf.Blocks.End
+	started := time.Now()
 
-	if g.conf.Blocks.Range != 0 {
-		latestBlock, err := g.eth.BlockNumber(g.ctx)
-		if err != nil {
-			return fmt.Errorf("could not get latest block: %w", err)
+	startBlock, endBlock, err := g.blockNumbers()
+	if err != nil {
+		return err
+	}
+
+	if err = g.GetBlocksByNumbers(startBlock, endBlock); err != nil {
+		return err
+	}
+
+	run := htmlreport.Run{
+		Mode:     g.conf.Mode,
+		Started:  started,
+		Finished: time.Now(),
+		Settings: htmlreport.Settings(g.conf),
+		Blocks:   g.blocks,
+	}
+
+	if g.conf.HTMLReport != "" {
+		if err = htmlreport.Write(g.conf.HTMLReport, run); err != nil {
+			return err
 		}
+	}
 
-		endBlock = int64(latestBlock)
-		startBlock = endBlock - g.conf.Blocks.Range
+	if g.conf.ResultsFile != "" {
+		return results.Write(g.conf.ResultsFile, results.New(run, g.conf.TPSWindowSec))
 	}
 
-	return g.GetBlocksByNumbers(startBlock, endBlock)
+	return nil
 }
 
-func (g *GetBlocks) GetBlocksByNumbers(startBlock, endBlock int64) error {
-	s := spinner.New(spinner.CharSets[35], 500*time.Millisecond)
-	s.Start()
+// blockNumbers returns the start and the end block, resolved from the time window or the range if they are set
+func (g *GetBlocks) blockNumbers() (int64, int64, error) {
+	blocks := g.conf.Blocks
 
-	for i := startBlock; i <= endBlock; i++ {
-		i := i
-		g.errGr.Go(func() error {
-			return g.getBlockByNumberInfo(i)
-		})
+	timeWindow := !blocks.Since.IsZero() || !blocks.Until.IsZero() || blocks.Last != 0
+
+	if !timeWindow && blocks.Range == 0 {
+		return blocks.Start, blocks.End, nil
+	}
+
+	latestBlock, err := g.eth.BlockNumber(g.ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get latest block: %w", err)
+	}
+
+	if !timeWindow {
+		return max(int64(latestBlock)-blocks.Range, 0), int64(latestBlock), nil
 	}
 
-	if err := g.errGr.Wait(); err != nil {
+	return g.resolveTimeWindow(latestBlock)
+}
+
+// GetBlocksByNumbers fetches the blocks, and their breakdown if it is enabled, and writes their report
+func (g *GetBlocks) GetBlocksByNumbers(startBlock, endBlock int64) error {
+	if _, err := g.FetchBlocks(startBlock, endBlock); err != nil {
 		return err
 	}
-	s.Stop()
 
-	g.sortBlocks()
-	g.outputStats()
+	if g.conf.Breakdown {
+		breakdown, err := g.fetchBreakdown()
+		if err != nil {
+			return err
+		}
+
+		g.breakdown = breakdown
+	}
 
-	return nil
+	return g.outputStats()
 }
 
-func (g *GetBlocks) getBlockByNumberInfo(blockNumber int64) error {
-	block, err := g.eth.BlockByNumber(g.ctx, big.NewInt(blockNumber))
+// Blocks returns the last fetched blocks, ordered by number
+func (g *GetBlocks) Blocks() []types.BlockInfo {
+	return g.blocks
+}
+
+// outputStats writes the report in the configured format, to the output file or stdout
+func (g *GetBlocks) outputStats() error {
+	report := g.report()
+
+	w, err := output.Open(g.conf.OutputFile)
 	if err != nil {
-		g.log.Error("Could not fetch block", "number", blockNumber, "err", err.Error())
 		return err
 	}
 
-	g.mux.Lock()
-	g.blocks = append(g.blocks, types.BlockInfo{
-		TransactionNum: block.Transactions().Len(),
-		GasLimit:       block.GasLimit(),
-		GasUsed:        block.GasUsed(),
-		Hash:           block.Hash().String(),
-		Number:         block.NumberU64(),
-		Time:           block.Time(),
-	})
-	g.mux.Unlock()
+	defer w.Close()
 
-	return nil
+	switch g.conf.Output {
+	case conf.JSONOutput:
+		return output.JSON(w, report)
+	case conf.CSVOutput:
+		return output.CSV(w, blocksHeader, report.rows())
+	default:
+		report.render(w)
+		return nil
+	}
 }
 
-func (g *GetBlocks) outputStats() {
-	totalTxs := uint64(0)
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"TIME", "NUMBER", "TXS", "GAS_LIMIT", "GAS_USED"})
+func (g *GetBlocks) report() Report {
+	return Report{
+		Blocks:    g.blocks,
+		Stats:     blockstats.Calculate(g.blocks, g.conf.TPSWindowSec),
+		Breakdown: g.breakdown,
+	}
+}
 
-	for _, block := range g.blocks {
-		blTime := time.Unix(int64(block.Time), 0)
+// rows returns the csv rows of the blocks
+func (r Report) rows() [][]string {
+	rows := make([][]string, 0, len(r.Blocks))
 
-		table.Append([]string{
-			blTime.Format(time.DateTime),
+	for _, block := range r.Blocks {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", block.Time),
 			fmt.Sprintf("%d", block.Number),
+			block.Hash,
 			fmt.Sprintf("%d", block.TransactionNum),
 			fmt.Sprintf("%d", block.GasLimit),
-			fmt.Sprintf("%.2f%%", float64(block.GasUsed)/float64(block.GasLimit)*100),
+			fmt.Sprintf("%d", block.GasUsed),
 		})
-
-		totalTxs += uint64(block.TransactionNum)
 	}
 
-	g.calculateTPS(totalTxs, g.blocks, table)
-
-	table.Render()
+	return rows
 }
 
-// simple bubble sort O(n^2)
-func (g *GetBlocks) sortBlocks() {
-	var done = false
-
-	for !done {
-		done = true
+// render writes the blocks table, with the TPS in the footer, the statistics table, and the breakdown tables
+func (r Report) render(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"TIME", "NUMBER", "TXS", "GAS_LIMIT", "GAS_USED"})
 
-		for i := 0; i < len(g.blocks)-1; i++ {
-			if g.blocks[i].Number > g.blocks[i+1].Number {
-				first := &g.blocks[i]
-				second := &g.blocks[i+1]
-				*first, *second = g.blocks[i+1], g.blocks[i]
+	for _, block := range r.Blocks {
+		blTime := time.Unix(int64(block.Time), 0)
 
-				done = false
-			}
-		}
+		table.Append([]string{
+			blTime.Format(time.DateTime),
+			fmt.Sprintf("%d", block.Number),
+			fmt.Sprintf("%d", block.TransactionNum),
+			fmt.Sprintf("%d", block.GasLimit),
+			fmt.Sprintf("%.2f%%", float64(block.GasUsed)/float64(block.GasLimit)*100),
+		})
 	}
-}
-
-func (g *GetBlocks) calculateTPS(totalTxs uint64, blocks []types.BlockInfo, table *tablewriter.Table) {
-	timeStart := time.Unix(int64(blocks[0].Time), 0)
-	timeFinish := time.Unix(int64(blocks[len(blocks)-1].Time), 0)
-	totalTimeToComplete := timeFinish.Sub(timeStart)
 
-	tps := float64(totalTxs) / totalTimeToComplete.Seconds()
-
-	table.SetFooter([]string{fmt.Sprintf("DURATION: %.2f s", totalTimeToComplete.Seconds()), "TOTAL TX", fmt.Sprintf("%d", totalTxs), "TPS", fmt.Sprintf("%.2f", tps)})
+	table.SetFooter([]string{fmt.Sprintf("DURATION: %.2f s", r.DurationSec), "TOTAL TX", fmt.Sprintf("%d", r.TotalTxs), "TPS", fmt.Sprintf("%.2f", r.TPS)})
 
 	table.SetFooterColor(tablewriter.Colors{},
 		tablewriter.Colors{tablewriter.Bold}, tablewriter.Colors{tablewriter.BgBlueColor},
 		tablewriter.Colors{tablewriter.Bold}, tablewriter.Colors{tablewriter.BgGreenColor},
 	)
+
+	table.Render()
+
+	r.Stats.Render(w)
+
+	if r.Breakdown != nil {
+		r.Breakdown.Render(w)
+	}
 }
@@ -0,0 +1,95 @@
+package getblocks
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var ErrNoBlocksInTimeWindow = errors.New("no blocks built within the time window")
+
+// resolveTimeWindow returns the first and the last block built within the configured time window,
+// found by a binary search over the block timestamps up to the latest block.
+// The window starts at the first block if since is not set, and the last duration ends at until,
+// or at the latest block if until is not set
+func (g *GetBlocks) resolveTimeWindow(latestBlock uint64) (int64, int64, error) {
+	since, until := g.conf.Blocks.Since, g.conf.Blocks.Until
+
+	if g.conf.Blocks.Last != 0 {
+		if until.IsZero() {
+			latestTime, err := g.blockTime(latestBlock)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			until = time.Unix(int64(latestTime), 0)
+		}
+
+		since = until.Add(-g.conf.Blocks.Last)
+	}
+
+	startBlock, err := g.searchBlock(uint64(max(since.Unix(), 0)), latestBlock)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the block after the last one within the window, the first one built after it
+	afterBlock := latestBlock + 1
+	start, end := "first block", "latest block"
+
+	if !since.IsZero() {
+		start = since.Format(time.RFC3339)
+	}
+
+	if !until.IsZero() {
+		if afterBlock, err = g.searchBlock(uint64(max(until.Unix(), 0))+1, latestBlock); err != nil {
+			return 0, 0, err
+		}
+
+		end = until.Format(time.RFC3339)
+	}
+
+	window := fmt.Sprintf("%s - %s", start, end)
+
+	if startBlock >= afterBlock {
+		return 0, 0, fmt.Errorf("%w: %s", ErrNoBlocksInTimeWindow, window)
+	}
+
+	g.log.Info("Time window resolved to blocks", "window", window, "start", startBlock, "end", afterBlock-1)
+
+	return int64(startBlock), int64(afterBlock - 1), nil
+}
+
+// searchBlock returns the first block built at or after the timestamp, or the block after the latest one
+// if all the blocks were built before it
+func (g *GetBlocks) searchBlock(timestamp uint64, latestBlock uint64) (uint64, error) {
+	low, high := uint64(0), latestBlock+1
+
+	for low < high {
+		mid := low + (high-low)/2
+
+		blockTime, err := g.blockTime(mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if blockTime >= timestamp {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return low, nil
+}
+
+// blockTime returns the timestamp of the block, only its header is fetched
+func (g *GetBlocks) blockTime(number uint64) (uint64, error) {
+	header, err := g.eth.HeaderByNumber(g.ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return 0, fmt.Errorf("could not fetch block header %d: %w", number, err)
+	}
+
+	return header.Time, nil
+}
@@ -0,0 +1,142 @@
+package getblocks
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headersAPI serves the headers of blocks built at the timestamps
+type headersAPI struct {
+	times []uint64
+	// headerCalls counts the fetched headers
+	headerCalls atomic.Int64
+}
+
+func (a *headersAPI) BlockNumber() hexutil.Uint64 {
+	return hexutil.Uint64(len(a.times) - 1)
+}
+
+func (a *headersAPI) GetBlockByNumber(number hexutil.Uint64, _ bool) *types.Header {
+	a.headerCalls.Add(1)
+
+	if int(number) >= len(a.times) {
+		return nil
+	}
+
+	return &types.Header{Number: new(big.Int).SetUint64(uint64(number)), Difficulty: common.Big0, Time: a.times[number]}
+}
+
+func TestGetBlocks_BlockNumbers(t *testing.T) {
+	var tests = []struct {
+		name   string
+		blocks conf.Blocks
+		start  int64
+		end    int64
+		err    error
+	}{
+		{
+			name:   "Block numbers",
+			blocks: conf.Blocks{Start: 1, End: 4},
+			start:  1,
+			end:    4,
+		},
+		{
+			name:   "Block range",
+			blocks: conf.Blocks{Start: 1, Range: 2},
+			start:  3,
+			end:    5,
+		},
+		{
+			name:   "Since and until at block timestamps",
+			blocks: conf.Blocks{Since: time.Unix(102, 0), Until: time.Unix(110, 0)},
+			start:  1,
+			end:    3,
+		},
+		{
+			name:   "Since and until between block timestamps",
+			blocks: conf.Blocks{Since: time.Unix(101, 0), Until: time.Unix(111, 0)},
+			start:  1,
+			end:    3,
+		},
+		{
+			name:   "Since until the latest block",
+			blocks: conf.Blocks{Since: time.Unix(103, 0)},
+			start:  3,
+			end:    5,
+		},
+		{
+			name:   "Since before the first block",
+			blocks: conf.Blocks{Since: time.Unix(50, 0), Until: time.Unix(100, 0)},
+			start:  0,
+			end:    0,
+		},
+		{
+			name:   "Until from the first block",
+			blocks: conf.Blocks{Until: time.Unix(105, 0)},
+			start:  0,
+			end:    2,
+		},
+		{
+			name:   "Last before until",
+			blocks: conf.Blocks{Until: time.Unix(111, 0), Last: 9 * time.Second},
+			start:  1,
+			end:    3,
+		},
+		{
+			name:   "Last before the latest block",
+			blocks: conf.Blocks{Last: 8 * time.Second},
+			start:  4,
+			end:    5,
+		},
+		{
+			name:   "Since after the latest block",
+			blocks: conf.Blocks{Since: time.Unix(121, 0)},
+			err:    ErrNoBlocksInTimeWindow,
+		},
+		{
+			name:   "No blocks between since and until",
+			blocks: conf.Blocks{Since: time.Unix(103, 0), Until: time.Unix(109, 0)},
+			err:    ErrNoBlocksInTimeWindow,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				api = &headersAPI{times: []uint64{100, 102, 102, 110, 112, 120}}
+				srv = rpc.NewServer()
+			)
+
+			require.NoError(t, srv.RegisterName("eth", api))
+			defer srv.Stop()
+
+			g := New(context.Background(), logger.NewZapLogger(), ethclient.NewClient(rpc.DialInProc(srv)), conf.Conf{Blocks: tc.blocks})
+
+			start, end, err := g.blockNumbers()
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.start, start)
+			assert.Equal(t, tc.end, end)
+
+			// the binary search fetches a few headers, not every block
+			assert.LessOrEqual(t, api.headerCalls.Load(), int64(8))
+		})
+	}
+}
@@ -3,24 +3,35 @@ package longsender
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/ZeljkoBenovic/tpser/pkg/prom"
-	"golang.org/x/sync/errgroup"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/modes/getblocks"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/balancer"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/inclusion"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/loadprofile"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/presign"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/scheduler"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txreceipts"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsender"
 	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/workload"
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 var ErrPrivKeyOrMnemonicNotProvided = errors.New("longsender requires mnemonic or private key")
 
 type longsender struct {
+	// parent is the context the send context was derived from, scenario phases are run with it
+	parent context.Context
 	ctx    context.Context
 	cancel context.CancelFunc
 	log    logger.Logger
@@ -28,13 +39,19 @@ type longsender struct {
 	conf   conf.Conf
 
 	signer    *txsigner.TxSigner
-	sender    *txsender.TxSender
 	getblocks *getblocks.GetBlocks
 	receipts  *txreceipts.TxReceipts
+	inclusion *inclusion.Tracker
+	workload  workload.Workload
+	profile   loadprofile.Profile
+	balancer  *balancer.Balancer
+	// senders send the transactions to the balancer endpoints with the same index
+	senders []*txsender.TxSender
 
 	wg        sync.WaitGroup
-	nonce     *atomic.Uint64
 	noncesMap safeNonce
+	// poolDrained marks the signers that used up their pre-signed transactions
+	poolDrained map[int]bool
 
 	prom *prom.Prom
 }
@@ -49,23 +66,33 @@ func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf con
 
 	return &longsender{
 		wg:     sync.WaitGroup{},
+		parent: ctx,
 		ctx:    newCtx,
 		cancel: cancel,
 		log:    log,
 		eth:    eth,
 		conf:   conf,
-		nonce:  &atomic.Uint64{},
 		noncesMap: safeNonce{
 			nonces: map[int]*atomic.Uint64{},
 		},
-		signer:    txsigner.New(ctx, log, eth, conf),
-		sender:    txsender.New(ctx, log, eth),
-		getblocks: getblocks.New(ctx, log, eth, conf),
-		receipts:  txreceipts.New(ctx, log, eth, conf),
-		prom:      prom,
+		poolDrained: map[int]bool{},
+		signer:      txsigner.New(ctx, log, eth, conf),
+		getblocks:   getblocks.New(ctx, log, eth, conf),
+		receipts:    txreceipts.New(ctx, log, eth, conf, prom),
+		inclusion:   inclusion.New(ctx, log, eth, prom, time.Duration(conf.InclusionWaitSec)*time.Second),
+		prom:        prom,
 	}
 }
 
+// pendingTx is a signed transaction waiting for its intended send time
+type pendingTx struct {
+	tx        *types.Transaction
+	signer    *txsigner.TxSigner
+	signerInd int
+	intended  time.Time
+	endpoint  int
+}
+
 type safeNonce struct {
 	sync.RWMutex
 	nonces map[int]*atomic.Uint64
@@ -93,9 +120,40 @@ func (s *safeNonce) Store(index int, value uint64) {
 }
 
 func (l *longsender) RunMode() error {
+	if len(l.conf.Phases) != 0 {
+		return l.runPhases()
+	}
+
 	l.prom.SetTxSendInterval(float64(l.conf.TxSendInterval))
 	l.prom.SetTxNumberPerInterval(float64(l.conf.TxPerSec))
 
+	wl, err := workload.New(l.ctx, l.log, l.eth, l.conf)
+	if err != nil {
+		return err
+	}
+
+	l.workload = wl
+
+	profile, err := loadprofile.New(l.conf)
+	if err != nil {
+		return err
+	}
+
+	l.profile = profile
+
+	bal, err := balancer.New(l.ctx, l.conf, l.eth)
+	if err != nil {
+		return err
+	}
+
+	defer bal.Close()
+
+	l.balancer = bal
+
+	for _, endpoint := range bal.Endpoints() {
+		l.senders = append(l.senders, txsender.New(l.parent, l.log, endpoint.Client))
+	}
+
 	if l.conf.Mnemonic != "" {
 		return l.sendTxFromMnemonics()
 	} else if l.conf.PrivateKey != "" {
@@ -105,253 +163,570 @@ func (l *longsender) RunMode() error {
 	}
 }
 
+// runPhases runs the scenario phases one after another, each with its own configuration
+func (l *longsender) runPhases() error {
+	defer l.cancel()
+
+	for ind, phase := range l.conf.Phases {
+		l.log.Info("Starting scenario phase",
+			"phase", phase.Name,
+			"number", ind+1,
+			"total", len(l.conf.Phases),
+			"scenario", l.conf.Scenario,
+		)
+
+		phaseConf := phase.Conf
+		if phaseConf.HTMLReport != "" {
+			phaseConf.HTMLReport = phaseReportPath(phaseConf.HTMLReport, phase.Name)
+		}
+
+		if phaseConf.ResultsFile != "" {
+			phaseConf.ResultsFile = phaseReportPath(phaseConf.ResultsFile, phase.Name)
+		}
+
+		if err := New(l.parent, l.log, l.eth, phaseConf, l.prom).RunMode(); err != nil {
+			return fmt.Errorf("scenario phase %s failed: %w", phase.Name, err)
+		}
+
+		if l.parent.Err() != nil {
+			l.log.Info("Scenario interrupted", "phase", phase.Name)
+			return nil
+		}
+	}
+
+	l.log.Info("All scenario phases completed", "scenario", l.conf.Scenario)
+
+	return nil
+}
+
 func (l *longsender) sendTxFromMnemonics() error {
-	l.log.Info("Sending transactions using mnemonics", "tps", l.conf.TxPerSec, "duration_min", l.conf.TxSendTimeoutMin)
+	l.log.Info("Sending transactions using mnemonics",
+		"tps", l.conf.TxPerSec,
+		"duration_min", l.conf.TxSendTimeoutMin,
+		"workload", l.conf.Workload,
+		"profile", l.conf.LoadProfile,
+		"workers", l.conf.Workers,
+		"batch_size", l.conf.BatchSize,
+		"endpoints", len(l.senders),
+		"balance", l.conf.Balance,
+	)
+
+	signers, err := l.initMnemonicAccounts()
+	if err != nil {
+		return err
+	}
+
+	return l.sendTransactions(signers)
+}
+
+func (l *longsender) sendTxWithPrivateKey() error {
+	l.log.Info("Sending transactions using private key",
+		"tps", l.conf.TxPerSec,
+		"duration_min", l.conf.TxSendTimeoutMin,
+		"workload", l.conf.Workload,
+		"profile", l.conf.LoadProfile,
+		"workers", l.conf.Workers,
+		"batch_size", l.conf.BatchSize,
+		"endpoints", len(l.senders),
+		"balance", l.conf.Balance,
+	)
+
+	if err := l.initSender(); err != nil {
+		return err
+	}
+
+	return l.sendTransactions([]*txsigner.TxSigner{l.signer})
+}
+
+// sendTransactions sends the transactions of every interval at evenly spread intended times,
+// independently of how long the previous transactions took to send.
+// The transactions are distributed between the endpoints, and with batching enabled, every endpoint collects
+// its own batches, each sent at the intended time of its last transaction
+func (l *longsender) sendTransactions(signers []*txsigner.TxSigner) error {
 	var (
-		firstBlock        uint64
-		lastBlock         uint64
-		err               error
-		fetchPendingNonce bool
-		// split number of transactions evenly
-		txNum = make([]struct{}, l.conf.TxPerSec/int64(l.conf.TotalAccounts))
-		tick  = time.Tick(time.Second * time.Duration(l.conf.TxSendInterval))
+		firstBlock uint64
+		lastBlock  uint64
+		err        error
+		interval   = time.Second * time.Duration(l.conf.TxSendInterval)
+		batchSize  = max(l.conf.BatchSize, 1)
+		sched      = scheduler.New(l.ctx, l.conf.Workers)
+		// fetchPendingNonce is set for the signers whose transaction failed
+		fetchPendingNonce = make([]atomic.Bool, len(signers))
+		// rates are only recorded for the html report, as runs can be indefinite
+		rates *rateRecorder
 	)
 
-	if l.conf.IncludeTPSReport {
+	if l.conf.HTMLReport != "" {
+		rates = &rateRecorder{interval: interval}
+	}
+
+	pool, err := l.presign(signers)
+	if err != nil {
+		return err
+	}
+
+	if pool != nil {
+		defer func() {
+			if err := pool.Close(); err != nil {
+				l.log.Error("Could not close pre-signed pool", "err", err.Error())
+			}
+		}()
+	}
+
+	if l.conf.IncludeTPSReport || l.writesReports() {
 		firstBlock, err = l.eth.BlockNumber(l.ctx)
 		if err != nil {
 			return err
 		}
 	}
 
-	signers, err := l.initMnemonicAccounts()
-	if err != nil {
-		return err
+	if l.conf.WaitForConfirm {
+		signerInds := make(map[common.Address]int, len(signers))
+		for ind, signer := range signers {
+			signerInds[signer.GetFrom()] = ind
+		}
+
+		// a stuck nonce is usually a gap left by a dropped transaction, which the pending nonce of the account fills
+		l.receipts.OnStuckNonce(func(from common.Address, _ uint64) {
+			if ind, ok := signerInds[from]; ok {
+				fetchPendingNonce[ind].Store(true)
+			}
+		})
+
+		// the confirmation already searches every new block, so the inclusion is fed its blocks instead of polling them
+		l.receipts.OnInclusion(l.inclusion)
+
+		if err = l.receipts.Start(); err != nil {
+			return fmt.Errorf("could not start confirmation tracking: %w", err)
+		}
+
+		// the background confirmation is stopped on every return, ConfirmTransactions stops it as well
+		defer l.receipts.Stop()
+	} else if err = l.inclusion.Start(); err != nil {
+		return fmt.Errorf("could not start inclusion tracking: %w", err)
 	}
 
-	for {
-		select {
-		case <-tick:
-			// each signer should send its own batch
-			for ind, signer := range signers {
-				if fetchPendingNonce {
-					newNonce, err := signer.GetFreshNonce()
-					if err != nil {
-						l.log.Error("Could not fetch new nonce", "err", err.Error())
-						l.prom.IncreaseTxErrorCount()
-						continue
-					}
-
-					l.noncesMap.Store(ind, newNonce)
-					fetchPendingNonce = false
-					l.log.Debug("New nonce fetched", "nonce", l.noncesMap.Load(ind))
-				}
+	sched.Start()
+	start := time.Now()
+
+	for intervalStart := start; l.ctx.Err() == nil; intervalStart = intervalStart.Add(interval) {
+		// an interval without transactions must still take its time
+		if wait := time.Until(intervalStart); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-l.ctx.Done():
+				continue
+			}
+		}
 
-				for range txNum {
-					ind := ind
-					signer := signer
+		rate := l.nextRate(intervalStart.Sub(start))
+		batches := make([][]pendingTx, len(l.senders))
 
-					tx, err := signer.GetNextSignedTx(l.noncesMap.Load(ind))
-					if err != nil {
-						return err
-					}
+		for ind, intended := range scheduler.Spread(intervalStart, interval, rate) {
+			// the transactions are interleaved between the signers
+			signerInd := ind % len(signers)
+			signer := signers[signerInd]
 
-					errGr, _ := errgroup.WithContext(l.ctx)
+			if fetchPendingNonce[signerInd].CompareAndSwap(true, false) {
+				newNonce, err := signer.GetFreshNonce()
+				if err != nil {
+					l.log.Error("Could not fetch new nonce", "err", err.Error())
+					l.prom.IncreaseTxErrorCount()
+					fetchPendingNonce[signerInd].Store(true)
 
-					errGr.Go(func() error {
-						currentNonce := l.noncesMap.Load(ind)
-						sendStart := time.Now()
+					continue
+				}
 
-						hash, txErr := l.sender.SendSignedTransaction(tx)
-						if txErr != nil {
-							l.log.Error("Transaction send error",
-								"err", txErr,
-								"hash", tx.Hash(),
-								"from", signer.GetFromAddress(),
-								"nonce", currentNonce,
-							)
-							return txErr
-						}
+				l.noncesMap.Store(signerInd, newNonce)
+				l.log.Debug("New nonce fetched", "nonce", newNonce, "from", signer.GetFromAddress())
+			}
 
-						l.prom.ObserveTxRequestDuration(float64(time.Since(sendStart).Milliseconds()))
+			nonce := l.noncesMap.Load(signerInd)
 
-						l.receipts.StoreTxHash(hash)
+			// transactions are signed ahead of their intended time, so that signing does not delay the send
+			tx, err := l.nextTx(pool, signer, signerInd, nonce)
+			if err != nil {
+				sched.Stop()
+				l.inclusion.Stop(0)
 
-						l.log.Info("Transaction sent",
-							"hash", hash.String(),
-							"from", signer.GetFromAddress(),
-							"nonce", currentNonce,
-						)
+				return err
+			}
 
-						return nil
-					})
+			l.noncesMap.Increment(signerInd)
 
-					if txErr := errGr.Wait(); txErr != nil {
-						fetchPendingNonce = true
-						l.prom.IncreaseTxErrorCount()
-						continue
-					}
+			endpoint := l.balancer.Next(signerInd)
+			batches[endpoint] = append(batches[endpoint], pendingTx{
+				tx:        tx,
+				signer:    signer,
+				signerInd: signerInd,
+				intended:  intended,
+				endpoint:  endpoint,
+			})
 
-					l.noncesMap.Increment(ind)
-				}
+			if len(batches[endpoint]) < batchSize {
+				continue
 			}
-		case <-l.ctx.Done():
-			if l.conf.IncludeTPSReport {
-				lastBlock, err = l.eth.BlockNumber(l.ctx)
-				if err != nil {
-					return err
-				}
 
-				l.log.Info("Transaction send timeout reached, generating report")
+			if !l.schedule(sched, batches[endpoint], fetchPendingNonce) {
+				batches = nil
+				break
+			}
 
-				return l.getblocks.GetBlocksByNumbers(int64(firstBlock), int64(lastBlock))
-			} else if l.conf.WaitForConfirm {
-				l.log.Info("Waiting for transactions verification...")
+			batches[endpoint] = nil
+		}
 
-				l.receipts.ConfirmTransactions()
-			} else {
-				l.log.Info("Transaction send timeout reached, stopping send", "timeout_min", l.conf.TxSendTimeoutMin)
-				return nil
+		// batches do not span intervals, so the last batches of the interval are sent even if they are not full
+		for _, batch := range batches {
+			if len(batch) != 0 {
+				l.schedule(sched, batch, fetchPendingNonce)
 			}
+		}
 
+		report := sched.Report()
+		l.prom.SetTxAchievedRate(report.AchievedRate)
+		rates.record(intervalStart.Sub(start), rate, report.Completed)
+	}
+
+	res := runResults{
+		started:    start,
+		firstBlock: firstBlock,
+		rates:      rates,
+		schedule:   sched.Stop(),
+		inclusion:  l.inclusion.Stop(time.Duration(l.conf.InclusionWaitSec) * time.Second),
+	}
+
+	rates.close(res.schedule.Completed)
+
+	l.outputSchedule(res.schedule)
+	l.outputEndpoints()
+	l.outputInclusion(res.inclusion)
+
+	if l.conf.IncludeTPSReport || l.writesReports() {
+		// the send context is done, so the report uses the context of the whole run
+		lastBlock, err = l.eth.BlockNumber(l.parent)
+		if err != nil {
+			return err
+		}
+
+		res.lastBlock = lastBlock
+	}
+
+	if l.conf.IncludeTPSReport {
+		l.log.Info("Transaction send timeout reached, generating report")
+
+		if err = l.getblocks.GetBlocksByNumbers(int64(firstBlock), int64(lastBlock)); err != nil {
+			return err
 		}
 	}
+
+	if l.conf.WaitForConfirm {
+		l.log.Info("Waiting for transactions verification...")
+
+		confirmation := l.receipts.ConfirmTransactions()
+		res.confirmation = &confirmation
+	} else if !l.conf.IncludeTPSReport {
+		l.log.Info("Transaction send timeout reached, stopping send", "timeout_min", l.conf.TxSendTimeoutMin)
+	}
+
+	if !l.writesReports() {
+		return nil
+	}
+
+	return l.writeReports(res)
 }
 
-func (l *longsender) initMnemonicAccounts() ([]*txsigner.TxSigner, error) {
-	var (
-		signers  = make([]*txsigner.TxSigner, 0)
-		nonces   = map[int]*atomic.Uint64{}
-		numOfAcc = make([]struct{}, l.conf.TotalAccounts)
-	)
+// presign signs the configured number of transactions for every signer before the sending starts,
+// it returns a nil pool if pre-signing is disabled
+func (l *longsender) presign(signers []*txsigner.TxSigner) (presign.Pool, error) {
+	if l.conf.Presign == 0 {
+		return nil, nil
+	}
 
-	for range numOfAcc {
-		signers = append(signers, txsigner.New(l.ctx, l.log, l.eth, l.conf))
+	pool, err := presign.New(l.conf, len(signers))
+	if err != nil {
+		return nil, err
 	}
 
-	for ind, signer := range signers {
-		if err := signer.SetPrivateKey(txsigner.WithNumberOfAccounts(ind)); err != nil {
-			l.log.Error("Could not set private key", "err", err.Error())
-			return nil, err
-		}
+	nonces := make([]uint64, len(signers))
+	for ind := range signers {
+		nonces[ind] = l.noncesMap.Load(ind)
+	}
 
-		if err := signer.SetToAddress(l.conf.ToAddress); err != nil {
-			l.log.Error("Could not set to address", "err", err.Error())
-			return nil, err
+	l.log.Info("Pre-signing transactions", "per_account", l.conf.Presign, "accounts", len(signers), "dir", l.conf.PresignDir)
+
+	if err = presign.Fill(l.ctx, l.log, pool, signers, nonces, l.conf.Presign, l.workload.NextCall); err != nil {
+		_ = pool.Close()
+		return nil, fmt.Errorf("could not pre-sign transactions: %w", err)
+	}
+
+	return pool, nil
+}
+
+// nextTx takes the transaction with the nonce from the pre-signed pool, and signs it if the pool does not hold it
+func (l *longsender) nextTx(pool presign.Pool, signer *txsigner.TxSigner, signerInd int, nonce uint64) (*types.Transaction, error) {
+	if pool != nil {
+		tx, err := pool.Take(signerInd, nonce)
+		if err != nil {
+			l.log.Error("Could not take pre-signed transaction", "err", err.Error(), "from", signer.GetFromAddress())
 		}
 
-		nonces[ind] = &atomic.Uint64{}
-		nonces[ind].Store(signer.GetNonce())
+		if tx != nil {
+			return tx, nil
+		}
 
-		l.noncesMap.nonces = nonces
+		if !l.poolDrained[signerInd] {
+			l.poolDrained[signerInd] = true
+			l.log.Info("Pre-signed transactions used up, signing inline", "from", signer.GetFromAddress(), "nonce", nonce)
+		}
 	}
 
-	return signers, nil
+	return signer.GetNextSignedCall(nonce, l.workload.NextCall(signerInd))
 }
 
-func (l *longsender) sendTxWithPrivateKey() error {
+// schedule schedules the send of the transactions, in a single batch request if there is more than one,
+// and marks the signers of the failed transactions for a nonce refresh
+func (l *longsender) schedule(sched *scheduler.Scheduler, txs []pendingTx, fetchPendingNonce []atomic.Bool) bool {
+	return sched.Schedule(txs[len(txs)-1].intended, len(txs), func(lag time.Duration) int {
+		if len(txs) == 1 {
+			if err := l.sendTx(txs[0], lag); err != nil {
+				fetchPendingNonce[txs[0].signerInd].Store(true)
+				return 1
+			}
+
+			return 0
+		}
+
+		failed := 0
+
+		for ind, err := range l.sendBatch(txs, lag) {
+			if err != nil {
+				failed++
+				fetchPendingNonce[txs[ind].signerInd].Store(true)
+			}
+		}
+
+		return failed
+	})
+}
+
+// sendBatch sends the transactions in a single JSON-RPC batch request and returns the error of every transaction
+func (l *longsender) sendBatch(txs []pendingTx, lag time.Duration) []error {
 	var (
-		firstBlock        uint64
-		lastBlock         uint64
-		err               error
-		fetchPendingNonce bool
+		signedTxs = make([]*types.Transaction, 0, len(txs))
+		errs      = make([]error, len(txs))
+		failed    int
+		endpoint  = txs[0].endpoint
+		sendStart = time.Now()
 	)
-	l.log.Info("Sending transactions using private key", "tps", l.conf.TxPerSec, "duration_min", l.conf.TxSendTimeoutMin)
 
-	if err := l.initSender(); err != nil {
-		return err
+	for _, p := range txs {
+		signedTxs = append(signedTxs, p.tx)
+		l.inclusion.Track(p.tx.Hash(), sendStart)
 	}
 
-	txNum := make([]struct{}, l.conf.TxPerSec)
-	l.nonce.Store(l.signer.GetNonce())
-	tick := time.Tick(time.Second * time.Duration(l.conf.TxSendInterval))
+	results := l.senders[endpoint].SendSignedTransactions(signedTxs)
+	duration := time.Since(sendStart)
+
+	l.prom.ObserveTxRequestDuration(float64(duration.Milliseconds()))
+	l.prom.ObserveTxScheduleLag(float64(lag.Milliseconds()))
+	l.prom.ObserveEndpointRequestDuration(l.endpointName(endpoint), float64(duration.Milliseconds()))
+
+	for ind, res := range results {
+		if res.Err != nil {
+			l.log.Error("Transaction send error",
+				"err", res.Err,
+				"hash", res.Hash,
+				"from", txs[ind].signer.GetFromAddress(),
+				"nonce", res.Nonce,
+				"endpoint", l.endpointName(endpoint),
+			)
+			l.prom.IncreaseTxErrorCount()
+			l.prom.IncreaseEndpointErrorCount(l.endpointName(endpoint))
+			l.inclusion.Forget(res.Hash)
+			workload.SendFailed(l.workload, txs[ind].signerInd, txs[ind].tx.Data())
+
+			errs[ind] = res.Err
+			failed++
+
+			continue
+		}
 
-	if l.conf.IncludeTPSReport {
-		firstBlock, err = l.eth.BlockNumber(l.ctx)
-		if err != nil {
-			return err
+		// the hashes are only evicted by the confirmation, so they are not stored without it
+		if l.conf.WaitForConfirm {
+			l.receipts.StoreTxHash(res.Hash, txs[ind].signer.GetFrom(), res.Nonce, sendStart)
 		}
+
+		l.log.Info("Transaction sent",
+			"hash", res.Hash.String(),
+			"from", txs[ind].signer.GetFromAddress(),
+			"nonce", res.Nonce,
+			"lag", lag.String(),
+		)
 	}
 
-	for {
-		select {
-		case <-tick:
-			if fetchPendingNonce {
-				newNonce, err := l.signer.GetFreshNonce()
-				if err != nil {
-					l.log.Error("Could not fetch new nonce", "err", err.Error())
-					l.prom.IncreaseTxErrorCount()
-					continue
-				}
+	l.balancer.Record(endpoint, duration, len(txs), failed)
 
-				l.nonce.Store(newNonce)
-				fetchPendingNonce = false
-				l.log.Debug("New nonce fetched", "nonce", l.nonce.Load())
-			}
+	l.log.Debug("Transactions batch sent",
+		"size", len(txs),
+		"failed", failed,
+		"lag", lag.String(),
+		"endpoint", l.endpointName(endpoint),
+	)
 
-			for range txNum {
-				tx, err := l.signer.GetNextSignedTx(l.nonce.Load())
-				if err != nil {
-					return err
-				}
+	return errs
+}
 
-				errGr, _ := errgroup.WithContext(l.ctx)
+func (l *longsender) sendTx(p pendingTx, lag time.Duration) error {
+	sendStart := time.Now()
+	l.inclusion.Track(p.tx.Hash(), sendStart)
 
-				errGr.Go(func() error {
-					sendStart := time.Now()
+	hash, err := l.senders[p.endpoint].SendSignedTransaction(p.tx)
+	duration := time.Since(sendStart)
 
-					hash, txErr := l.sender.SendSignedTransaction(tx)
-					if txErr != nil {
-						return txErr
-					}
+	l.prom.ObserveEndpointRequestDuration(l.endpointName(p.endpoint), float64(duration.Milliseconds()))
 
-					l.prom.ObserveTxRequestDuration(float64(time.Since(sendStart).Milliseconds()))
-					l.receipts.StoreTxHash(hash)
+	if err != nil {
+		l.log.Error("Transaction send error",
+			"err", err,
+			"hash", p.tx.Hash(),
+			"from", p.signer.GetFromAddress(),
+			"nonce", p.tx.Nonce(),
+			"endpoint", l.endpointName(p.endpoint),
+		)
+		l.prom.IncreaseTxErrorCount()
+		l.prom.IncreaseEndpointErrorCount(l.endpointName(p.endpoint))
+		l.balancer.Record(p.endpoint, duration, 1, 1)
+		l.inclusion.Forget(p.tx.Hash())
+		workload.SendFailed(l.workload, p.signerInd, p.tx.Data())
 
-					l.log.Info("Transaction sent",
-						"hash", hash.String(),
-						"from", l.signer.GetFromAddress(),
-						"nonce", l.nonce.Load(),
-					)
+		return err
+	}
 
-					return nil
-				})
+	l.prom.ObserveTxRequestDuration(float64(duration.Milliseconds()))
+	l.prom.ObserveTxScheduleLag(float64(lag.Milliseconds()))
+	l.balancer.Record(p.endpoint, duration, 1, 0)
 
-				if txErr := errGr.Wait(); txErr != nil {
-					l.log.Error("Transaction send error",
-						"err", txErr,
-						"from", l.signer.GetFromAddress(),
-						"nonce", l.nonce.Load(),
-						"hash", tx.Hash(),
-					)
-					fetchPendingNonce = true
-					l.prom.IncreaseTxErrorCount()
-					continue
-				}
+	if l.conf.WaitForConfirm {
+		l.receipts.StoreTxHash(hash, p.signer.GetFrom(), p.tx.Nonce(), sendStart)
+	}
 
-				l.nonce.Add(1)
-			}
-		case <-l.ctx.Done():
-			if l.conf.IncludeTPSReport {
-				lastBlock, err = l.eth.BlockNumber(l.ctx)
-				if err != nil {
-					return err
-				}
+	l.log.Info("Transaction sent",
+		"hash", hash.String(),
+		"from", p.signer.GetFromAddress(),
+		"nonce", p.tx.Nonce(),
+		"lag", lag.String(),
+	)
 
-				l.log.Info("Transaction send timeout reached, generating report")
+	return nil
+}
 
-				return l.getblocks.GetBlocksByNumbers(int64(firstBlock), int64(lastBlock))
-			} else if l.conf.WaitForConfirm {
-				l.log.Info("Waiting for transactions verification...")
+func (l *longsender) endpointName(endpoint int) string {
+	return l.balancer.Endpoints()[endpoint].Name
+}
 
-				l.receipts.ConfirmTransactions()
-				return nil
-			} else {
-				l.log.Info("Transaction send timeout reached, stopping send", "timeout_min", l.conf.TxSendTimeoutMin)
-				return nil
-			}
+// outputInclusion reports the inclusion latencies of the sent transactions, with their histogram
+func (l *longsender) outputInclusion(report inclusion.Report) {
+	l.log.Info("Inclusion latency report",
+		"included", report.Latency.Count,
+		"not_included", report.NotIncluded,
+		"mean", report.Latency.Mean.String(),
+		"p50", report.Latency.P50.String(),
+		"p90", report.Latency.P90.String(),
+		"p99", report.Latency.P99.String(),
+		"max", report.Latency.Max.String(),
+	)
+
+	report.Latency.Render(os.Stdout, "INCLUSION_LATENCY")
+}
+
+// outputEndpoints reports how the transactions were distributed between the endpoints
+func (l *longsender) outputEndpoints() {
+	if len(l.senders) < 2 {
+		return
+	}
+
+	for _, report := range l.balancer.Report() {
+		l.log.Info("Endpoint report",
+			"endpoint", report.Name,
+			"requests", report.Requests,
+			"sent", report.Sent,
+			"failed", report.Failed,
+			"latency_mean", report.LatencyMean.String(),
+			"latency_max", report.LatencyMax.String(),
+		)
+	}
+}
+
+// outputSchedule reports how closely the send followed the schedule
+func (l *longsender) outputSchedule(report scheduler.Report) {
+	l.prom.SetTxAchievedRate(report.AchievedRate)
+
+	l.log.Info("Send schedule report",
+		"scheduled", report.Scheduled,
+		"sent", report.Completed,
+		"failed", report.Failed,
+		"duration", report.Duration.Round(time.Millisecond).String(),
+		"achieved_tps", fmt.Sprintf("%.2f", report.AchievedRate),
+		"lag_mean", report.Lag.Mean.String(),
+		"lag_p50", report.Lag.P50.String(),
+		"lag_p99", report.Lag.P99.String(),
+		"lag_max", report.Lag.Max.String(),
+	)
+}
+
+// nextRate returns the number of transactions the load profile sends in the interval starting after the elapsed time
+func (l *longsender) nextRate(elapsed time.Duration) int64 {
+	rate := l.profile.Rate(elapsed)
+	l.prom.SetTxNumberPerInterval(float64(rate))
+
+	l.log.Debug("Sending transactions", "rate", rate, "profile", l.conf.LoadProfile)
+
+	return rate
+}
+
+func (l *longsender) initMnemonicAccounts() ([]*txsigner.TxSigner, error) {
+	var (
+		signers  = make([]*txsigner.TxSigner, 0)
+		nonces   = map[int]*atomic.Uint64{}
+		numOfAcc = make([]struct{}, l.conf.TotalAccounts)
+	)
+
+	for range numOfAcc {
+		signers = append(signers, txsigner.New(l.ctx, l.log, l.eth, l.conf))
+	}
+
+	for ind, signer := range signers {
+		if err := signer.SetPrivateKey(txsigner.WithNumberOfAccounts(ind)); err != nil {
+			l.log.Error("Could not set private key", "err", err.Error())
+			return nil, err
+		}
+
+		if err := signer.SetToAddress(l.conf.ToAddress); err != nil {
+			l.log.Error("Could not set to address", "err", err.Error())
+			return nil, err
+		}
+	}
+
+	if err := l.workload.Setup(signers); err != nil {
+		l.log.Error("Could not set up workload", "workload", l.conf.Workload, "err", err.Error())
+		return nil, err
+	}
+
+	// workload setup could have sent transactions, so the nonces are fetched afterward
+	for ind, signer := range signers {
+		nonce, err := signer.GetFreshNonce()
+		if err != nil {
+			l.log.Error("Could not fetch nonce", "err", err.Error())
+			return nil, err
 		}
+
+		nonces[ind] = &atomic.Uint64{}
+		nonces[ind].Store(nonce)
 	}
+
+	l.noncesMap.nonces = nonces
+
+	return signers, nil
 }
 
 func (l *longsender) initSender() error {
@@ -363,5 +738,19 @@ func (l *longsender) initSender() error {
 		return err
 	}
 
+	if err := l.workload.Setup([]*txsigner.TxSigner{l.signer}); err != nil {
+		l.log.Error("Could not set up workload", "workload", l.conf.Workload, "err", err.Error())
+		return err
+	}
+
+	// workload setup could have sent transactions, so the nonce is fetched afterward
+	nonce, err := l.signer.GetFreshNonce()
+	if err != nil {
+		return err
+	}
+
+	l.noncesMap.nonces[0] = &atomic.Uint64{}
+	l.noncesMap.Store(0, nonce)
+
 	return nil
 }
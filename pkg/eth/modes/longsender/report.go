@@ -0,0 +1,150 @@
+package longsender
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/inclusion"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/scheduler"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txreceipts"
+	"github.com/ZeljkoBenovic/tpser/pkg/htmlreport"
+	"github.com/ZeljkoBenovic/tpser/pkg/results"
+)
+
+// rateRecorder records the intended and the achieved send rate of every interval, for the html report.
+// A nil recorder records nothing
+type rateRecorder struct {
+	interval time.Duration
+	rates    []htmlreport.Rate
+	// completed is the number of sent transactions when the last interval was recorded
+	completed int64
+}
+
+// record closes the previous interval with the transactions sent since it was recorded, and opens the next one.
+// The transactions of an interval are sent after it is scheduled, so they are completed by the next one
+func (r *rateRecorder) record(elapsed time.Duration, rate int64, completed int64) {
+	if r == nil {
+		return
+	}
+
+	r.close(completed)
+
+	r.rates = append(r.rates, htmlreport.Rate{
+		Elapsed:  elapsed,
+		Intended: float64(rate) / r.interval.Seconds(),
+	})
+}
+
+// close sets the achieved rate of the last interval
+func (r *rateRecorder) close(completed int64) {
+	if r == nil {
+		return
+	}
+
+	if len(r.rates) != 0 {
+		r.rates[len(r.rates)-1].Achieved = float64(completed-r.completed) / r.interval.Seconds()
+	}
+
+	r.completed = completed
+}
+
+// recorded returns the recorded rates, nil if the rates were not recorded
+func (r *rateRecorder) recorded() []htmlreport.Rate {
+	if r == nil {
+		return nil
+	}
+
+	return r.rates
+}
+
+// runResults are the results of a send, shown in the html report and saved to the results file
+type runResults struct {
+	started    time.Time
+	firstBlock uint64
+	lastBlock  uint64
+	rates      *rateRecorder
+	schedule   scheduler.Report
+	inclusion  inclusion.Report
+	// confirmation is nil if the transactions were not confirmed
+	confirmation *txreceipts.Report
+}
+
+// writesReports reports if the html report or the results file of the send are written
+func (l *longsender) writesReports() bool {
+	return l.conf.HTMLReport != "" || l.conf.ResultsFile != ""
+}
+
+// writeReports writes the html report and the results file of the send, if they are configured,
+// with the blocks built while sending
+func (l *longsender) writeReports(res runResults) error {
+	blocks := l.getblocks.Blocks()
+
+	// the blocks are already fetched for the TPS report
+	if !l.conf.IncludeTPSReport {
+		var err error
+
+		blocks, err = l.getblocks.FetchBlocks(int64(res.firstBlock), int64(res.lastBlock))
+		if err != nil {
+			return fmt.Errorf("could not fetch blocks for report: %w", err)
+		}
+	}
+
+	run := htmlreport.Run{
+		Mode:     l.conf.Mode,
+		Started:  res.started,
+		Finished: time.Now(),
+		Settings: htmlreport.Settings(l.conf),
+		Blocks:   blocks,
+		Rates:    res.rates.recorded(),
+		Sent:     res.schedule.Scheduled,
+		Errors: []htmlreport.Count{
+			{Name: "failed sends", Value: res.schedule.Failed},
+			{Name: "not included", Value: res.inclusion.NotIncluded},
+		},
+		Latencies: []htmlreport.Latency{
+			htmlreport.NewLatency("schedule lag", res.schedule.Lag),
+			htmlreport.NewLatency("inclusion", res.inclusion.Latency),
+		},
+	}
+
+	if len(l.senders) > 1 {
+		for _, endpoint := range l.balancer.Report() {
+			run.Errors = append(run.Errors, htmlreport.Count{Name: "failed sends " + endpoint.Name, Value: endpoint.Failed})
+		}
+	}
+
+	if res.confirmation != nil {
+		for _, status := range []txreceipts.Status{txreceipts.Reverted, txreceipts.Dropped, txreceipts.TimedOut} {
+			run.Errors = append(run.Errors, htmlreport.Count{Name: string(status), Value: res.confirmation.Total.Statuses[status]})
+		}
+
+		run.Latencies = append(run.Latencies, htmlreport.NewLatency("time to finality", res.confirmation.Finality))
+	}
+
+	if l.conf.HTMLReport != "" {
+		if err := htmlreport.Write(l.conf.HTMLReport, run); err != nil {
+			return err
+		}
+
+		l.log.Info("HTML report written", "file", l.conf.HTMLReport)
+	}
+
+	if l.conf.ResultsFile != "" {
+		if err := results.Write(l.conf.ResultsFile, results.New(run, l.conf.TPSWindowSec)); err != nil {
+			return err
+		}
+
+		l.log.Info("Results written", "file", l.conf.ResultsFile)
+	}
+
+	return nil
+}
+
+// phaseReportPath returns the report path of a scenario phase, with the phase name before the extension
+func phaseReportPath(path, phase string) string {
+	ext := filepath.Ext(path)
+
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), phase, ext)
+}
@@ -3,19 +3,40 @@ package txinfo
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/big"
-	"os"
 	"strings"
 	"sync"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/output"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/olekukonko/tablewriter"
 )
 
+// txHeader is the header of the csv output, with a row per transaction, the amounts are in wei
+var txHeader = []string{"hash", "to", "gas_limit", "gas_price", "cost", "value"}
+
+// Report holds the fetched transactions and their total cost, the amounts are in wei
+type Report struct {
+	Transactions []Transaction `json:"transactions"`
+	TotalTxs     int           `json:"total_txs"`
+	TotalCost    *big.Int      `json:"total_cost"`
+}
+
+// Transaction holds the cost details of a transaction, the amounts are in wei
+type Transaction struct {
+	Hash     string   `json:"hash"`
+	To       string   `json:"to"`
+	GasLimit uint64   `json:"gas_limit"`
+	GasPrice *big.Int `json:"gas_price"`
+	Cost     *big.Int `json:"cost"`
+	Value    *big.Int `json:"value"`
+}
+
 type TxInfo struct {
 	ctx  context.Context
 	log  logger.Logger
@@ -60,18 +81,89 @@ func (t *TxInfo) RunMode() error {
 	}
 
 	t.wg.Wait()
-	t.displayOutput()
-	return nil
+
+	return t.writeOutput()
+}
+
+// writeOutput writes the transactions in the configured format, to the output file or stdout,
+// the machine-readable formats always hold the amounts in wei
+func (t *TxInfo) writeOutput() error {
+	w, err := output.Open(t.conf.OutputFile)
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	switch t.conf.Output {
+	case conf.JSONOutput:
+		return output.JSON(w, t.report())
+	case conf.CSVOutput:
+		return output.CSV(w, txHeader, t.report().rows())
+	default:
+		t.displayOutput(w)
+		return nil
+	}
+}
+
+func (t *TxInfo) report() Report {
+	report := Report{
+		Transactions: make([]Transaction, 0, len(t.txHashes)),
+		TotalTxs:     len(t.txHashes),
+		TotalCost:    big.NewInt(0),
+	}
+
+	for _, tx := range t.txHashes {
+		report.Transactions = append(report.Transactions, Transaction{
+			Hash:     tx.Hash().Hex(),
+			To:       toAddress(tx),
+			GasLimit: tx.Gas(),
+			GasPrice: tx.GasPrice(),
+			Cost:     tx.Cost(),
+			Value:    tx.Value(),
+		})
+
+		report.TotalCost.Add(report.TotalCost, tx.Cost())
+	}
+
+	return report
+}
+
+// rows returns the csv rows of the transactions
+func (r Report) rows() [][]string {
+	rows := make([][]string, 0, len(r.Transactions))
+
+	for _, tx := range r.Transactions {
+		rows = append(rows, []string{
+			tx.Hash,
+			tx.To,
+			fmt.Sprintf("%d", tx.GasLimit),
+			tx.GasPrice.String(),
+			tx.Cost.String(),
+			tx.Value.String(),
+		})
+	}
+
+	return rows
+}
+
+// toAddress returns the recipient of the transaction, or an empty string for a contract creation
+func toAddress(tx *types.Transaction) string {
+	if tx.To() == nil {
+		return ""
+	}
+
+	return tx.To().Hex()
 }
 
-func (t *TxInfo) displayOutput() {
+func (t *TxInfo) displayOutput(w io.Writer) {
 	var (
 		base, pow    = big.NewInt(10), big.NewInt(18)
 		totalCostWei = big.NewInt(0)
 		oneEthInWei  = base.Exp(base, pow, nil)
 		wei          = big.NewFloat(float64(oneEthInWei.Int64()))
-		table        = tablewriter.NewWriter(os.Stdout)
-		totalsTable  = tablewriter.NewWriter(os.Stdout)
+		table        = tablewriter.NewWriter(w)
+		totalsTable  = tablewriter.NewWriter(w)
 	)
 
 	switch t.conf.TxCostInEth {
@@ -89,7 +181,7 @@ func (t *TxInfo) displayOutput() {
 
 			table.Append([]string{
 				tx.Hash().Hex(),
-				tx.To().Hex(),
+				toAddress(tx),
 				fmt.Sprintf("%d", tx.Gas()),
 				tx.GasPrice().String(),
 				ethCost.Text('f', 18),
@@ -112,7 +204,7 @@ func (t *TxInfo) displayOutput() {
 
 			table.Append([]string{
 				tx.Hash().Hex(),
-				tx.To().Hex(),
+				toAddress(tx),
 				fmt.Sprintf("%d", tx.Gas()),
 				tx.GasPrice().String(),
 				tx.Cost().String(),
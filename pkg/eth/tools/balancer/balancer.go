@@ -0,0 +1,181 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var ErrBalanceNotSupported = errors.New("balance strategy not supported")
+
+// Balancer distributes the transactions between the endpoints and keeps the send statistics of every endpoint
+type Balancer struct {
+	strategy  Strategy
+	endpoints []*Endpoint
+	// dialed is set if the endpoint clients were dialed by the balancer, which then must close them
+	dialed bool
+}
+
+// Endpoint is a node the transactions are sent to
+type Endpoint struct {
+	// Name identifies the endpoint in logs and metrics, without the credentials the URL could hold
+	Name   string
+	URL    string
+	Client *ethclient.Client
+
+	mux   sync.Mutex
+	stats Report
+}
+
+// Report holds the send statistics of an endpoint
+type Report struct {
+	Name     string
+	Requests int64
+	Sent     int64
+	Failed   int64
+
+	LatencyMean time.Duration
+	LatencyMax  time.Duration
+
+	latencySum time.Duration
+}
+
+// New dials the configured endpoints, or uses the provided client if no endpoints are configured
+func New(ctx context.Context, cnf conf.Conf, eth *ethclient.Client) (*Balancer, error) {
+	endpoints := cnf.Endpoints
+	if len(endpoints) == 0 {
+		endpoints = []conf.Endpoint{{URL: cnf.JsonRPC, Weight: 1}}
+	}
+
+	strategyConstructor, ok := strategiesFactory[cnf.Balance]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrBalanceNotSupported, cnf.Balance)
+	}
+
+	b := &Balancer{
+		strategy:  strategyConstructor(endpoints),
+		endpoints: make([]*Endpoint, 0, len(endpoints)),
+		dialed:    len(cnf.Endpoints) != 0,
+	}
+
+	names := endpointNames(endpoints)
+
+	for ind, endpoint := range endpoints {
+		client := eth
+
+		if b.dialed {
+			var err error
+
+			client, err = ethclient.DialContext(ctx, endpoint.URL)
+			if err != nil {
+				b.Close()
+				return nil, fmt.Errorf("could not dial endpoint %s: %w", names[ind], err)
+			}
+		}
+
+		b.endpoints = append(b.endpoints, &Endpoint{
+			Name:   names[ind],
+			URL:    endpoint.URL,
+			Client: client,
+		})
+	}
+
+	return b, nil
+}
+
+// Endpoints returns all the endpoints, in the configured order
+func (b *Balancer) Endpoints() []*Endpoint {
+	return b.endpoints
+}
+
+// Next returns the index of the endpoint the next transaction of the signer is sent to
+func (b *Balancer) Next(signer int) int {
+	return b.strategy.Next(signer)
+}
+
+// Record adds a request to the endpoint, with the number of transactions sent in it and the number of failed ones
+func (b *Balancer) Record(endpoint int, latency time.Duration, txs, failed int) {
+	e := b.endpoints[endpoint]
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.stats.Requests++
+	e.stats.Sent += int64(txs - failed)
+	e.stats.Failed += int64(failed)
+	e.stats.latencySum += latency
+	e.stats.LatencyMax = max(e.stats.LatencyMax, latency)
+}
+
+// Report returns the send statistics of every endpoint
+func (b *Balancer) Report() []Report {
+	reports := make([]Report, 0, len(b.endpoints))
+
+	for _, e := range b.endpoints {
+		e.mux.Lock()
+
+		report := e.stats
+		report.Name = e.Name
+
+		if report.Requests != 0 {
+			report.LatencyMean = report.latencySum / time.Duration(report.Requests)
+		}
+
+		e.mux.Unlock()
+
+		reports = append(reports, report)
+	}
+
+	return reports
+}
+
+// Close closes the clients dialed by the balancer
+func (b *Balancer) Close() {
+	if !b.dialed {
+		return
+	}
+
+	for _, e := range b.endpoints {
+		e.Client.Close()
+	}
+}
+
+// endpointNames returns the names of the endpoints, which are their hosts. The endpoints sharing a host,
+// like the nodes of a provider behind different paths, are told apart by their position
+func endpointNames(endpoints []conf.Endpoint) []string {
+	var (
+		names = make([]string, 0, len(endpoints))
+		hosts = make(map[string]int, len(endpoints))
+	)
+
+	for _, endpoint := range endpoints {
+		name := endpointName(endpoint.URL)
+
+		names = append(names, name)
+		hosts[name]++
+	}
+
+	for ind, name := range names {
+		if hosts[name] > 1 {
+			names[ind] = fmt.Sprintf("%s#%d", name, ind+1)
+		}
+	}
+
+	return names
+}
+
+// endpointName returns the host of the endpoint, so that the API keys in the URL path or user info are not exposed
+func endpointName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	return u.Host
+}
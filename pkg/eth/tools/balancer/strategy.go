@@ -0,0 +1,94 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+)
+
+// Strategy picks the endpoint of every transaction
+type Strategy interface {
+	// Next returns the index of the endpoint the next transaction of the signer is sent to
+	Next(signer int) int
+}
+
+type factoryFunc func(endpoints []conf.Endpoint) Strategy
+
+var strategiesFactory = map[conf.Balance]factoryFunc{
+	conf.RoundRobinBalance: func(endpoints []conf.Endpoint) Strategy {
+		return &roundRobin{endpoints: uint64(len(endpoints))}
+	},
+	conf.WeightedBalance: func(endpoints []conf.Endpoint) Strategy {
+		return newWeighted(endpoints)
+	},
+	conf.StickyBalance: func(endpoints []conf.Endpoint) Strategy {
+		return sticky{endpoints: len(endpoints)}
+	},
+}
+
+// roundRobin sends the transactions to the endpoints in turn
+type roundRobin struct {
+	endpoints uint64
+	next      atomic.Uint64
+}
+
+func (r *roundRobin) Next(_ int) int {
+	return int((r.next.Add(1) - 1) % r.endpoints)
+}
+
+// weighted sends each endpoint a share of the transactions proportional to its weight,
+// interleaving the endpoints instead of sending consecutive transactions to the same one
+type weighted struct {
+	mux     sync.Mutex
+	weights []int
+	current []int
+	total   int
+}
+
+func newWeighted(endpoints []conf.Endpoint) *weighted {
+	w := &weighted{
+		weights: make([]int, 0, len(endpoints)),
+		current: make([]int, len(endpoints)),
+	}
+
+	for _, endpoint := range endpoints {
+		weight := max(endpoint.Weight, 1)
+
+		w.weights = append(w.weights, weight)
+		w.total += weight
+	}
+
+	return w
+}
+
+// Next uses the smooth weighted round-robin, every endpoint gains its weight
+// and the one with the highest gain is picked and pays back the total weight
+func (w *weighted) Next(_ int) int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	best := 0
+
+	for ind, weight := range w.weights {
+		w.current[ind] += weight
+
+		if w.current[ind] > w.current[best] {
+			best = ind
+		}
+	}
+
+	w.current[best] -= w.total
+
+	return best
+}
+
+// sticky always sends the transactions of a signer to the same endpoint,
+// so that the nonces of an account never arrive at a node out of order
+type sticky struct {
+	endpoints int
+}
+
+func (s sticky) Next(signer int) int {
+	return signer % s.endpoints
+}
@@ -0,0 +1,237 @@
+package blockcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// segmentSize is the number of blocks kept in a single cache file
+const segmentSize = 1000
+
+var ErrGenesisNotFound = errors.New("genesis block not found")
+
+// Cache keeps the fetched blocks on disk, and optionally their receipts, so that they are not fetched again.
+// Every chain has its own directory, named after its chain ID and genesis hash, so that a chain restarted
+// from a new genesis does not reuse the blocks of the old one.
+//
+// Only the blocks at least depth blocks below the chain head are cached, as the recent ones can still be
+// replaced by a reorg. The cached blocks that are no longer deep enough, because the chain head went back,
+// are dropped.
+type Cache struct {
+	mux   sync.Mutex
+	dir   string
+	depth uint64
+	head  uint64
+
+	segments map[uint64]*segment
+}
+
+// segment holds the blocks of a cache file, keyed by their numbers
+type segment struct {
+	Blocks map[uint64]types.BlockInfo `json:"blocks"`
+	dirty  bool
+}
+
+// Open opens the cache of the chain the client is connected to, in the directory
+func Open(ctx context.Context, eth *ethclient.Client, dir string, depth uint64) (*Cache, error) {
+	chainID, err := eth.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch chain id: %w", err)
+	}
+
+	var genesis *struct {
+		Hash common.Hash `json:"hash"`
+	}
+
+	if err = eth.Client().CallContext(ctx, &genesis, "eth_getBlockByNumber", "0x0", false); err != nil {
+		return nil, fmt.Errorf("could not fetch genesis block: %w", err)
+	}
+
+	if genesis == nil {
+		return nil, ErrGenesisNotFound
+	}
+
+	chainDir := filepath.Join(dir, fmt.Sprintf("%s-%s", chainID, genesis.Hash.Hex()[2:10]))
+	if err = os.MkdirAll(filepath.Join(chainDir, "receipts"), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create block cache directory: %w", err)
+	}
+
+	return &Cache{
+		dir:      chainDir,
+		depth:    depth,
+		segments: make(map[uint64]*segment),
+	}, nil
+}
+
+// SetHead sets the latest block of the chain, the blocks less than depth blocks below it are not cached
+func (c *Cache) SetHead(head uint64) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.head = head
+
+	for _, seg := range c.segments {
+		seg.invalidate(c.head, c.depth)
+	}
+}
+
+// Get returns the cached block with the number
+func (c *Cache) Get(number uint64) (types.BlockInfo, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if !c.deep(number) {
+		return types.BlockInfo{}, false
+	}
+
+	block, ok := c.segment(number).Blocks[number]
+
+	return block, ok
+}
+
+// Put caches the blocks deep enough below the chain head, the others are left out
+func (c *Cache) Put(blocks ...types.BlockInfo) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, block := range blocks {
+		if !c.deep(block.Number) {
+			continue
+		}
+
+		seg := c.segment(block.Number)
+		seg.Blocks[block.Number] = block
+		seg.dirty = true
+	}
+}
+
+// Receipts returns the cached receipts of the block
+func (c *Cache) Receipts(block types.BlockInfo) ([]types.Receipt, bool) {
+	if _, ok := c.Get(block.Number); !ok {
+		return nil, false
+	}
+
+	raw, err := os.ReadFile(c.receiptsPath(block))
+	if err != nil {
+		return nil, false
+	}
+
+	receipts := make([]types.Receipt, 0)
+	if err = json.Unmarshal(raw, &receipts); err != nil {
+		return nil, false
+	}
+
+	return receipts, true
+}
+
+// PutReceipts caches the receipts of the block, if the block is deep enough below the chain head
+func (c *Cache) PutReceipts(block types.BlockInfo, receipts []types.Receipt) error {
+	c.Put(block)
+
+	if _, ok := c.Get(block.Number); !ok {
+		return nil
+	}
+
+	raw, err := json.Marshal(receipts)
+	if err != nil {
+		return fmt.Errorf("could not encode receipts: %w", err)
+	}
+
+	return writeFile(c.receiptsPath(block), raw)
+}
+
+// Flush writes the changed cache files
+func (c *Cache) Flush() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for start, seg := range c.segments {
+		if !seg.dirty {
+			continue
+		}
+
+		raw, err := json.Marshal(seg)
+		if err != nil {
+			return fmt.Errorf("could not encode block cache: %w", err)
+		}
+
+		if err = writeFile(c.segmentPath(start), raw); err != nil {
+			return err
+		}
+
+		seg.dirty = false
+	}
+
+	return nil
+}
+
+// deep reports if the block is deep enough below the chain head to be cached
+func (c *Cache) deep(number uint64) bool {
+	return number+c.depth <= c.head
+}
+
+// segment returns the loaded segment of the block, the caller holds the lock.
+// A file that can not be read is treated as empty, and is replaced on the next flush
+func (c *Cache) segment(number uint64) *segment {
+	start := number - number%segmentSize
+
+	if seg, ok := c.segments[start]; ok {
+		return seg
+	}
+
+	seg := &segment{Blocks: make(map[uint64]types.BlockInfo)}
+
+	if raw, err := os.ReadFile(c.segmentPath(start)); err == nil {
+		if err = json.Unmarshal(raw, seg); err != nil || seg.Blocks == nil {
+			seg.Blocks = make(map[uint64]types.BlockInfo)
+		}
+	}
+
+	seg.invalidate(c.head, c.depth)
+	c.segments[start] = seg
+
+	return seg
+}
+
+// invalidate drops the blocks that are no longer deep enough below the chain head
+func (s *segment) invalidate(head, depth uint64) {
+	for number := range s.Blocks {
+		if number+depth > head {
+			delete(s.Blocks, number)
+			s.dirty = true
+		}
+	}
+}
+
+func (c *Cache) segmentPath(start uint64) string {
+	return filepath.Join(c.dir, fmt.Sprintf("blocks-%d.json", start))
+}
+
+// receiptsPath is keyed by the block hash, so that the receipts of a replaced block are not reused
+func (c *Cache) receiptsPath(block types.BlockInfo) string {
+	return filepath.Join(c.dir, "receipts", fmt.Sprintf("%d-%s.json", block.Number, block.Hash))
+}
+
+// writeFile replaces the file at once, so that an interrupted write does not leave it incomplete
+func writeFile(path string, raw []byte) error {
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return fmt.Errorf("could not write block cache: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not write block cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package blockcache
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// genesisAPI serves the chain ID and the genesis block hash
+type genesisAPI struct {
+	genesis common.Hash
+}
+
+func (a *genesisAPI) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1337))
+}
+
+func (a *genesisAPI) GetBlockByNumber(_ string, _ bool) map[string]interface{} {
+	return map[string]interface{}{"hash": a.genesis}
+}
+
+func openCache(t *testing.T, dir string, genesis common.Hash) *Cache {
+	t.Helper()
+
+	srv := rpc.NewServer()
+	require.NoError(t, srv.RegisterName("eth", &genesisAPI{genesis: genesis}))
+	t.Cleanup(srv.Stop)
+
+	cache, err := Open(context.Background(), ethclient.NewClient(rpc.DialInProc(srv)), dir, 10)
+	require.NoError(t, err)
+
+	return cache
+}
+
+func block(number uint64) types.BlockInfo {
+	return types.BlockInfo{
+		TransactionNum: int(number),
+		GasLimit:       1000,
+		GasUsed:        500,
+		Hash:           common.BigToHash(new(big.Int).SetUint64(number)).Hex(),
+		Number:         number,
+		Time:           1000 + number,
+	}
+}
+
+func TestCache_PutGet(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		cache = openCache(t, dir, common.Hash{1})
+	)
+
+	cache.SetHead(1005)
+
+	// the blocks span two cache files, the last ones are too recent to be cached
+	for number := uint64(990); number <= 1005; number++ {
+		cache.Put(block(number))
+	}
+
+	require.NoError(t, cache.Flush())
+
+	reopened := openCache(t, dir, common.Hash{1})
+	reopened.SetHead(1005)
+
+	for number := uint64(990); number <= 1005; number++ {
+		cached, ok := reopened.Get(number)
+
+		if number > 995 {
+			assert.False(t, ok, "block %d is not deep enough", number)
+			continue
+		}
+
+		assert.True(t, ok, "block %d is cached", number)
+		assert.Equal(t, block(number), cached)
+	}
+
+	// a chain with a new genesis does not reuse the blocks
+	restarted := openCache(t, dir, common.Hash{2})
+	restarted.SetHead(1005)
+
+	_, ok := restarted.Get(990)
+	assert.False(t, ok)
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		cache = openCache(t, dir, common.Hash{1})
+	)
+
+	cache.SetHead(100)
+	cache.Put(block(80), block(85), block(90))
+	require.NoError(t, cache.Flush())
+
+	// the chain head went back, the blocks above the new depth could be replaced
+	cache.SetHead(95)
+
+	_, ok := cache.Get(90)
+	assert.False(t, ok)
+
+	_, ok = cache.Get(85)
+	assert.True(t, ok)
+
+	require.NoError(t, cache.Flush())
+
+	// the dropped block is not read from the file either, once the chain is past it again
+	reopened := openCache(t, dir, common.Hash{1})
+	reopened.SetHead(100)
+
+	_, ok = reopened.Get(90)
+	assert.False(t, ok)
+
+	_, ok = reopened.Get(80)
+	assert.True(t, ok)
+}
+
+func TestCache_Receipts(t *testing.T) {
+	cache := openCache(t, t.TempDir(), common.Hash{1})
+	cache.SetHead(100)
+
+	receipts := []types.Receipt{
+		{
+			TxHash:  common.Hash{3}.Hex(),
+			From:    common.Address{5}.Hex(),
+			To:      common.Address{6}.Hex(),
+			Type:    2,
+			GasUsed: 21000,
+		},
+	}
+
+	require.NoError(t, cache.PutReceipts(block(50), receipts))
+	require.NoError(t, cache.PutReceipts(block(95), receipts))
+
+	cached, ok := cache.Receipts(block(50))
+	require.True(t, ok)
+	require.Len(t, cached, 1)
+	assert.Equal(t, receipts, cached)
+
+	// a replaced block does not get the receipts of the cached one
+	replaced := block(50)
+	replaced.Hash = common.Hash{4}.Hex()
+
+	_, ok = cache.Receipts(replaced)
+	assert.False(t, ok)
+
+	_, ok = cache.Receipts(block(95))
+	assert.False(t, ok, "the receipts of a recent block are not cached")
+}
@@ -0,0 +1,148 @@
+package blockreceipts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// methodNotFoundCode is the JSON-RPC error code of a method the node does not serve
+const methodNotFoundCode = -32601
+
+// Filter returns the transactions of a block whose receipts are fetched, if the node does not serve
+// eth_getBlockReceipts. A nil Filter fetches the receipts of all the transactions
+type Filter func(hashes []common.Hash) []common.Hash
+
+// Fetcher fetches the receipts of blocks with eth_getBlockReceipts, or if the node does not serve it,
+// the receipts of the transactions of the blocks, in batches of batchSize
+type Fetcher struct {
+	log       logger.Logger
+	client    *rpc.Client
+	batchSize int
+
+	// unsupported is set once the node does not serve eth_getBlockReceipts
+	unsupported atomic.Bool
+}
+
+// blockTxs holds the transaction hashes of a block
+type blockTxs struct {
+	Transactions []common.Hash `json:"transactions"`
+}
+
+// New returns a Fetcher of the receipts, the transaction receipts are fetched one request at a time
+// if batchSize is lower than 2
+func New(log logger.Logger, client *rpc.Client, batchSize int) *Fetcher {
+	return &Fetcher{
+		log:       log,
+		client:    client,
+		batchSize: max(batchSize, 1),
+	}
+}
+
+// Unsupported returns true once the node did not serve eth_getBlockReceipts
+func (f *Fetcher) Unsupported() bool {
+	return f.unsupported.Load()
+}
+
+// ByNumber decodes the receipts of the block with the number into the result, a pointer to a slice
+func (f *Fetcher) ByNumber(ctx context.Context, number uint64, filter Filter, result interface{}) error {
+	return f.fetch(ctx, hexutil.EncodeUint64(number), "eth_getBlockByNumber", filter, result)
+}
+
+// ByHash decodes the receipts of the block with the hash into the result, a pointer to a slice
+func (f *Fetcher) ByHash(ctx context.Context, hash common.Hash, filter Filter, result interface{}) error {
+	return f.fetch(ctx, hash, "eth_getBlockByHash", filter, result)
+}
+
+// fetch fetches the receipts of the block, by its number or hash argument and the method that returns the block.
+// A block or a receipt the node does not serve yet is ethereum.NotFound
+func (f *Fetcher) fetch(ctx context.Context, block interface{}, blockMethod string, filter Filter, result interface{}) error {
+	if !f.unsupported.Load() {
+		var receipts json.RawMessage
+		err := f.client.CallContext(ctx, &receipts, "eth_getBlockReceipts", block)
+		if err == nil {
+			return decode(receipts, result)
+		}
+
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) || rpcErr.ErrorCode() != methodNotFoundCode {
+			return err
+		}
+
+		f.log.Debug("eth_getBlockReceipts not supported, fetching the receipts of the transactions")
+		f.unsupported.Store(true)
+	}
+
+	var blk *blockTxs
+	if err := f.client.CallContext(ctx, &blk, blockMethod, block, false); err != nil {
+		return err
+	}
+
+	// the node can report a head before it serves the block
+	if blk == nil {
+		return ethereum.NotFound
+	}
+
+	hashes := blk.Transactions
+	if filter != nil {
+		hashes = filter(hashes)
+	}
+
+	var (
+		receipts = make([]json.RawMessage, len(hashes))
+		elems    = make([]rpc.BatchElem, 0, len(hashes))
+	)
+
+	for ind, hash := range hashes {
+		elems = append(elems, rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipts[ind]})
+	}
+
+	for start := 0; start < len(elems); start += f.batchSize {
+		batch := elems[start:min(start+f.batchSize, len(elems))]
+
+		if f.batchSize < 2 {
+			for ind := range batch {
+				batch[ind].Error = f.client.CallContext(ctx, batch[ind].Result, batch[ind].Method, batch[ind].Args...)
+			}
+		} else if err := f.client.BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+	}
+
+	for ind, elem := range elems {
+		if elem.Error != nil {
+			return elem.Error
+		}
+
+		if isNull(receipts[ind]) {
+			return ethereum.NotFound
+		}
+	}
+
+	raw, err := json.Marshal(receipts)
+	if err != nil {
+		return err
+	}
+
+	return decode(raw, result)
+}
+
+// decode decodes the receipts into the result, null receipts mean the block is not served yet
+func decode(raw json.RawMessage, result interface{}) error {
+	if isNull(raw) {
+		return ethereum.NotFound
+	}
+
+	return json.Unmarshal(raw, result)
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
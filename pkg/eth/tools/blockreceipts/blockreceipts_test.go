@@ -0,0 +1,130 @@
+package blockreceipts
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receipt holds the receipt fields of the tests
+type receipt struct {
+	TxHash common.Hash `json:"transactionHash"`
+}
+
+// txAPI serves block 1 with three transactions, and their receipts by transaction
+type txAPI struct {
+	// receiptCalls counts the receipts fetched by transaction hash
+	receiptCalls atomic.Int64
+}
+
+// blockAPI is a txAPI that also serves eth_getBlockReceipts
+type blockAPI struct {
+	*txAPI
+}
+
+var txs = []common.Hash{{1}, {2}, {3}}
+
+func (a *txAPI) GetBlockByNumber(number hexutil.Uint64, _ bool) map[string][]common.Hash {
+	if number != 1 {
+		return nil
+	}
+
+	return map[string][]common.Hash{"transactions": txs}
+}
+
+func (a *txAPI) GetTransactionReceipt(hash common.Hash) *receipt {
+	a.receiptCalls.Add(1)
+
+	return &receipt{TxHash: hash}
+}
+
+func (a *blockAPI) GetBlockReceipts(number hexutil.Uint64) []*receipt {
+	if number != 1 {
+		return nil
+	}
+
+	receipts := make([]*receipt, 0, len(txs))
+	for _, hash := range txs {
+		receipts = append(receipts, &receipt{TxHash: hash})
+	}
+
+	return receipts
+}
+
+func TestFetcher_ByNumber(t *testing.T) {
+	var tests = []struct {
+		name          string
+		blockReceipts bool
+		batchSize     int
+		filter        Filter
+		expected      []common.Hash
+	}{
+		{
+			name:          "Block receipts",
+			blockReceipts: true,
+			expected:      txs,
+		},
+		{
+			name:      "Batched receipts by transaction",
+			batchSize: 2,
+			expected:  txs,
+		},
+		{
+			name:      "Filtered receipts by transaction",
+			batchSize: 1,
+			filter: func(hashes []common.Hash) []common.Hash {
+				return hashes[1:2]
+			},
+			expected: txs[1:2],
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				api         = &txAPI{}
+				srv         = rpc.NewServer()
+				service any = api
+			)
+
+			if tc.blockReceipts {
+				service = &blockAPI{api}
+			}
+
+			require.NoError(t, srv.RegisterName("eth", service))
+			t.Cleanup(srv.Stop)
+
+			f := New(logger.NewZapLogger(), rpc.DialInProc(srv), tc.batchSize)
+
+			var receipts []receipt
+			require.NoError(t, f.ByNumber(context.Background(), 1, tc.filter, &receipts))
+
+			hashes := make([]common.Hash, 0, len(receipts))
+			for _, r := range receipts {
+				hashes = append(hashes, r.TxHash)
+			}
+
+			assert.Equal(t, tc.expected, hashes)
+			assert.Equal(t, !tc.blockReceipts, f.Unsupported())
+
+			if tc.blockReceipts {
+				assert.Zero(t, api.receiptCalls.Load())
+			} else {
+				assert.Equal(t, int64(len(tc.expected)), api.receiptCalls.Load())
+			}
+
+			// the node does not serve the block yet
+			err := f.ByNumber(context.Background(), 2, tc.filter, &receipts)
+			assert.True(t, errors.Is(err, ethereum.NotFound))
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package blockstats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/latency"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Stats are the statistics of a range of blocks.
+// The rates are over the time between the first and the last block, and are zero if the blocks do not span any time
+type Stats struct {
+	Blocks      int     `json:"blocks"`
+	EmptyBlocks int     `json:"empty_blocks"`
+	TotalTxs    uint64  `json:"total_txs"`
+	DurationSec float64 `json:"duration_sec"`
+	TPS         float64 `json:"tps"`
+	// PeakTPS is the highest rate of a single block, over the time since its parent
+	PeakTPS    float64    `json:"peak_tps"`
+	RollingTPS RollingTPS `json:"rolling_tps"`
+	GasPerSec  float64    `json:"gas_per_sec"`
+	MedianTxs  float64    `json:"median_txs"`
+	// BlockTime is the spread of the times between consecutive blocks, in seconds
+	BlockTime Spread `json:"block_time_sec"`
+	// Utilization are the percentiles of the block gas limit used by the blocks, in percent
+	Utilization Percentiles `json:"gas_utilization_pct"`
+}
+
+// RollingTPS is the lowest and the highest rate over a window of seconds sliding over the blocks
+type RollingTPS struct {
+	WindowSec int64   `json:"window_sec"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+}
+
+type Spread struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Calculate returns the statistics of the blocks ordered by number, with the rolling TPS over windows of windowSec.
+// The rolling TPS is left out if the window is not positive, or longer than the blocks span
+func Calculate(blocks []types.BlockInfo, windowSec int64) Stats {
+	stats := Stats{Blocks: len(blocks)}
+
+	if len(blocks) == 0 {
+		return stats
+	}
+
+	var (
+		gasUsed     uint64
+		txs         = make([]float64, 0, len(blocks))
+		utilization = make([]float64, 0, len(blocks))
+		intervals   = make([]float64, 0, len(blocks)-1)
+	)
+
+	for ind, block := range blocks {
+		stats.TotalTxs += uint64(block.TransactionNum)
+		gasUsed += block.GasUsed
+
+		if block.TransactionNum == 0 {
+			stats.EmptyBlocks++
+		}
+
+		txs = append(txs, float64(block.TransactionNum))
+		utilization = append(utilization, blockUtilization(block))
+
+		if ind == 0 {
+			continue
+		}
+
+		interval := float64(block.Time) - float64(blocks[ind-1].Time)
+		intervals = append(intervals, interval)
+
+		// block timestamps have a resolution of a second, so blocks with the same timestamp count as a second apart
+		stats.PeakTPS = max(stats.PeakTPS, float64(block.TransactionNum)/max(interval, 1))
+	}
+
+	stats.DurationSec = float64(blocks[len(blocks)-1].Time) - float64(blocks[0].Time)
+	if stats.DurationSec > 0 {
+		stats.TPS = float64(stats.TotalTxs) / stats.DurationSec
+		stats.GasPerSec = float64(gasUsed) / stats.DurationSec
+	} else {
+		stats.DurationSec = 0
+	}
+
+	slices.Sort(txs)
+	stats.MedianTxs = median(txs)
+
+	slices.Sort(utilization)
+	stats.Utilization = Percentiles{
+		P50: latency.Percentile(utilization, 50),
+		P90: latency.Percentile(utilization, 90),
+		P99: latency.Percentile(utilization, 99),
+	}
+
+	stats.BlockTime = spread(intervals)
+	stats.RollingTPS = rollingTPS(blocks, windowSec)
+
+	return stats
+}
+
+// rollingTPS slides the window over the blocks, ending it at the timestamp of every block.
+// A block is in the window if its timestamp is within it, as its transactions were collected since its parent
+func rollingTPS(blocks []types.BlockInfo, windowSec int64) RollingTPS {
+	rolling := RollingTPS{WindowSec: windowSec}
+
+	if windowSec < 1 {
+		return rolling
+	}
+
+	var (
+		txs   uint64
+		first = 0
+		found = false
+	)
+
+	for _, block := range blocks {
+		txs += uint64(block.TransactionNum)
+
+		// the window starts after the timestamp of the block, so the blocks at or before it drop out
+		for block.Time-blocks[first].Time >= uint64(windowSec) {
+			txs -= uint64(blocks[first].TransactionNum)
+			first++
+		}
+
+		// the window is only complete once it is covered by the blocks
+		if block.Time-blocks[0].Time < uint64(windowSec) {
+			continue
+		}
+
+		tps := float64(txs) / float64(windowSec)
+
+		if !found {
+			rolling.Min, rolling.Max, found = tps, tps, true
+			continue
+		}
+
+		rolling.Min = min(rolling.Min, tps)
+		rolling.Max = max(rolling.Max, tps)
+	}
+
+	return rolling
+}
+
+// Window is the rate and the mean gas utilization of the blocks over a window of seconds ending at the last block
+type Window struct {
+	WindowSec   int64   `json:"window_sec"`
+	TPS         float64 `json:"tps"`
+	Utilization float64 `json:"gas_utilization_pct"`
+}
+
+// Trailing returns the window of seconds ending at the last of the blocks ordered by number.
+// Until the blocks span the whole window, the rate is over the time since the first block
+func Trailing(blocks []types.BlockInfo, windowSec int64) Window {
+	window := Window{WindowSec: windowSec}
+
+	if len(blocks) == 0 {
+		return window
+	}
+
+	var (
+		end   = blocks[len(blocks)-1].Time
+		start = blocks[0].Time
+		txs   uint64
+		used  float64
+		count int
+	)
+
+	if end-start > uint64(windowSec) {
+		start = end - uint64(windowSec)
+	}
+
+	for ind := len(blocks) - 1; ind >= 0 && blocks[ind].Time >= start; ind-- {
+		used += blockUtilization(blocks[ind])
+		count++
+
+		// the transactions of a block at the start of the window were collected before it
+		if blocks[ind].Time > start {
+			txs += uint64(blocks[ind].TransactionNum)
+		}
+	}
+
+	window.Utilization = used / float64(count)
+
+	if end > start {
+		window.TPS = float64(txs) / float64(end-start)
+	}
+
+	return window
+}
+
+// Render writes the statistics as a table
+func (s Stats) Render(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"STAT", "VALUE"})
+
+	table.AppendBulk([][]string{
+		{"BLOCKS", fmt.Sprintf("%d", s.Blocks)},
+		{"EMPTY BLOCKS", fmt.Sprintf("%d", s.EmptyBlocks)},
+		{"MEDIAN TXS PER BLOCK", fmt.Sprintf("%.1f", s.MedianTxs)},
+		{"TPS", fmt.Sprintf("%.2f", s.TPS)},
+		{"PEAK BLOCK TPS", fmt.Sprintf("%.2f", s.PeakTPS)},
+		{fmt.Sprintf("ROLLING %ds TPS MIN / MAX", s.RollingTPS.WindowSec), fmt.Sprintf("%.2f / %.2f", s.RollingTPS.Min, s.RollingTPS.Max)},
+		{"GAS PER SECOND", fmt.Sprintf("%.0f", s.GasPerSec)},
+		{"BLOCK TIME MEAN / STDDEV", fmt.Sprintf("%.2f s / %.2f s", s.BlockTime.Mean, s.BlockTime.StdDev)},
+		{"BLOCK TIME MIN / MAX", fmt.Sprintf("%.0f s / %.0f s", s.BlockTime.Min, s.BlockTime.Max)},
+		{"GAS USED P50 / P90 / P99", fmt.Sprintf("%.2f%% / %.2f%% / %.2f%%", s.Utilization.P50, s.Utilization.P90, s.Utilization.P99)},
+	})
+
+	table.Render()
+}
+
+// spread returns the mean, the population standard deviation and the bounds of the values
+func spread(values []float64) Spread {
+	if len(values) == 0 {
+		return Spread{}
+	}
+
+	s := Spread{Min: values[0], Max: values[0]}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+		s.Min = min(s.Min, value)
+		s.Max = max(s.Max, value)
+	}
+
+	s.Mean = sum / float64(len(values))
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - s.Mean) * (value - s.Mean)
+	}
+
+	s.StdDev = math.Sqrt(squares / float64(len(values)))
+
+	return s
+}
+
+// median returns the median of the sorted values, the mean of the two middle ones if their number is even
+func median(sorted []float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
+
+// blockUtilization returns the share of the block gas limit used, in percent
+func blockUtilization(block types.BlockInfo) float64 {
+	if block.GasLimit == 0 {
+		return 0
+	}
+
+	return float64(block.GasUsed) / float64(block.GasLimit) * 100
+}
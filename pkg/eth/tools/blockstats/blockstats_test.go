@@ -0,0 +1,158 @@
+package blockstats
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func testBlocks() []types.BlockInfo {
+	// block times of 2, 2, 4, 0 and 2 seconds
+	var blocks = []struct {
+		time uint64
+		txs  int
+	}{
+		{time: 100, txs: 10},
+		{time: 102, txs: 20},
+		{time: 104, txs: 0},
+		{time: 108, txs: 40},
+		{time: 108, txs: 10},
+		{time: 110, txs: 30},
+	}
+
+	result := make([]types.BlockInfo, 0, len(blocks))
+	for ind, block := range blocks {
+		result = append(result, types.BlockInfo{
+			TransactionNum: block.txs,
+			GasLimit:       1000,
+			GasUsed:        uint64(block.txs) * 10,
+			Number:         uint64(ind + 1),
+			Time:           block.time,
+		})
+	}
+
+	return result
+}
+
+func TestCalculate(t *testing.T) {
+	stats := Calculate(testBlocks(), 4)
+
+	assert.Equal(t, 6, stats.Blocks)
+	assert.Equal(t, 1, stats.EmptyBlocks)
+	assert.Equal(t, uint64(110), stats.TotalTxs)
+	assert.Equal(t, 10.0, stats.DurationSec)
+	assert.Equal(t, 11.0, stats.TPS)
+	assert.Equal(t, 110.0, stats.GasPerSec)
+	assert.Equal(t, 15.0, stats.MedianTxs)
+
+	// the fourth block has 40 txs over 4 seconds, the fifth 10 txs over the same second
+	assert.Equal(t, 15.0, stats.PeakTPS)
+
+	assert.Equal(t, Spread{Mean: 2, StdDev: 1.2649110640673518, Min: 0, Max: 4}, stats.BlockTime)
+	assert.Equal(t, Percentiles{P50: 10, P90: 40, P99: 40}, stats.Utilization)
+}
+
+func TestCalculate_RollingTPS(t *testing.T) {
+	var rollingTests = []struct {
+		name   string
+		window int64
+		want   RollingTPS
+	}{
+		{
+			// the windows ending at the blocks (100, 104], (104, 108] and (106, 110] hold 20, 50 and 80 txs
+			name:   "Four second window",
+			window: 4,
+			want:   RollingTPS{WindowSec: 4, Min: 5, Max: 20},
+		},
+		{
+			name:   "Window of all the blocks",
+			window: 10,
+			want:   RollingTPS{WindowSec: 10, Min: 10, Max: 10},
+		},
+		{
+			name:   "Window longer than the blocks",
+			window: 11,
+			want:   RollingTPS{WindowSec: 11},
+		},
+		{
+			name:   "Window disabled",
+			window: 0,
+			want:   RollingTPS{},
+		},
+	}
+
+	for _, tt := range rollingTests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Calculate(testBlocks(), tt.window).RollingTPS)
+		})
+	}
+}
+
+func TestCalculate_NoDuration(t *testing.T) {
+	assert.Equal(t, Stats{}, Calculate(nil, 10))
+
+	stats := Calculate(testBlocks()[3:5], 10)
+
+	assert.Equal(t, uint64(50), stats.TotalTxs)
+	assert.Zero(t, stats.DurationSec)
+	assert.Zero(t, stats.TPS)
+	assert.Zero(t, stats.GasPerSec)
+	assert.Equal(t, 10.0, stats.PeakTPS)
+}
+
+func TestTrailing(t *testing.T) {
+	var trailingTests = []struct {
+		name   string
+		blocks []types.BlockInfo
+		window int64
+		want   Window
+	}{
+		{
+			// (106, 110] holds 80 txs, the blocks at 108 and 110 use 40%, 10% and 30%
+			name:   "Window within the blocks",
+			blocks: testBlocks(),
+			window: 4,
+			want:   Window{WindowSec: 4, TPS: 20, Utilization: 80.0 / 3},
+		},
+		{
+			// 100 txs since the first block, over 10 seconds
+			name:   "Window longer than the blocks",
+			blocks: testBlocks(),
+			window: 60,
+			want:   Window{WindowSec: 60, TPS: 10, Utilization: 110.0 / 6},
+		},
+		{
+			name:   "Single block",
+			blocks: testBlocks()[:1],
+			window: 10,
+			want:   Window{WindowSec: 10, Utilization: 10},
+		},
+		{
+			name:   "No blocks",
+			window: 10,
+			want:   Window{WindowSec: 10},
+		},
+	}
+
+	for _, tt := range trailingTests {
+		t.Run(tt.name, func(t *testing.T) {
+			window := Trailing(tt.blocks, tt.window)
+
+			assert.Equal(t, tt.want.WindowSec, window.WindowSec)
+			assert.InDelta(t, tt.want.TPS, window.TPS, 1e-9)
+			assert.InDelta(t, tt.want.Utilization, window.Utilization, 1e-9)
+		})
+	}
+}
+
+func TestStats_Render(t *testing.T) {
+	var buf bytes.Buffer
+
+	Calculate(testBlocks(), 4).Render(&buf)
+
+	assert.Contains(t, buf.String(), "ROLLING 4s TPS MIN / MAX")
+	assert.Contains(t, buf.String(), "5.00 / 20.00")
+	assert.Contains(t, buf.String(), "10.00% / 40.00% / 40.00%")
+}
@@ -0,0 +1,257 @@
+package inclusion
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/latency"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/prom"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// blockPollInterval is the time between two checks for new blocks
+const blockPollInterval = 500 * time.Millisecond
+
+// Buckets are the upper bounds of the latency histogram, the blocks are observed every poll interval
+var Buckets = []time.Duration{
+	time.Second,
+	2 * time.Second,
+	3 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	20 * time.Second,
+	30 * time.Second,
+	time.Minute,
+}
+
+// Tracker watches the new blocks and measures the time between the send of every tracked transaction
+// and the observation of the block that included it. Both are local times, as the block timestamps
+// have a resolution of a second, and come from the clock of the node.
+// Instead of watching the blocks itself, the tracker can be fed the blocks searched by another watcher
+type Tracker struct {
+	ctx  context.Context
+	eth  *ethclient.Client
+	log  logger.Logger
+	prom *prom.Prom
+
+	// maxAge is the time after its send a transaction is not included anymore, and stops being tracked.
+	// If it is zero, the transactions are tracked until they are included
+	maxAge time.Duration
+	// started is set if the tracker watches the blocks itself
+	started bool
+	// next is the number of the next block to check
+	next uint64
+	stop chan struct{}
+	done chan struct{}
+
+	mux     sync.Mutex
+	pending map[common.Hash]time.Time
+	// expired is the number of transactions not included within the max age
+	expired   int64
+	latencies *latency.Distribution
+}
+
+// Report summarizes the inclusion latencies of the tracked transactions
+type Report struct {
+	NotIncluded int64
+	// Latency summarizes the latencies of the included transactions
+	Latency latency.Summary
+}
+
+// block holds the fields of a block needed to match the transactions, the transactions are only hashes
+type block struct {
+	Transactions []common.Hash `json:"transactions"`
+}
+
+func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, prom *prom.Prom, maxAge time.Duration) *Tracker {
+	return &Tracker{
+		ctx:       ctx,
+		eth:       eth,
+		log:       log.Named("inclusion"),
+		prom:      prom,
+		maxAge:    maxAge,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		pending:   make(map[common.Hash]time.Time),
+		latencies: latency.NewDistribution(Buckets),
+	}
+}
+
+// Start starts watching the blocks after the latest one. It is not called if the tracker is fed the blocks with Include
+func (t *Tracker) Start() error {
+	head, err := t.eth.BlockNumber(t.ctx)
+	if err != nil {
+		return err
+	}
+
+	t.next = head + 1
+	t.started = true
+
+	go t.watch()
+
+	return nil
+}
+
+// Track records the send time of the transaction, it must be called before the transaction is sent,
+// so that the transaction is tracked even if it is included before the send returns
+func (t *Tracker) Track(hash common.Hash, sentAt time.Time) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.pending[hash] = sentAt
+}
+
+// Forget stops tracking the transaction, for transactions that could not be sent
+func (t *Tracker) Forget(hash common.Hash) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	delete(t.pending, hash)
+}
+
+// Tracked returns the tracked transactions among the provided ones
+func (t *Tracker) Tracked(hashes []common.Hash) []common.Hash {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	tracked := make([]common.Hash, 0)
+
+	for _, hash := range hashes {
+		if _, ok := t.pending[hash]; ok {
+			tracked = append(tracked, hash)
+		}
+	}
+
+	return tracked
+}
+
+// Pending returns the number of tracked transactions not included yet
+func (t *Tracker) Pending() int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return len(t.pending)
+}
+
+// Stop waits, up to the provided time, for the pending transactions to be included,
+// stops watching the blocks, if it was started, and returns the report of all the tracked transactions
+func (t *Tracker) Stop(wait time.Duration) Report {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	if pending := t.Pending(); pending != 0 && wait > 0 {
+		t.log.Info("Waiting for the pending transactions to be included", "pending", pending, "wait", wait.String())
+	}
+
+waitLoop:
+	for t.Pending() != 0 {
+		select {
+		case <-deadline.C:
+			break waitLoop
+		case <-t.ctx.Done():
+			break waitLoop
+		case <-time.After(blockPollInterval):
+		}
+	}
+
+	if t.started {
+		close(t.stop)
+		<-t.done
+	}
+
+	return t.Report()
+}
+
+// Report returns the report of the transactions included so far
+func (t *Tracker) Report() Report {
+	t.mux.Lock()
+	notIncluded := int64(len(t.pending)) + t.expired
+	t.mux.Unlock()
+
+	return Report{
+		NotIncluded: notIncluded,
+		Latency:     t.latencies.Summary(),
+	}
+}
+
+func (t *Tracker) watch() {
+	defer close(t.done)
+
+	tick := time.NewTicker(blockPollInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-t.stop:
+			return
+		case <-t.ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		head, err := t.eth.BlockNumber(t.ctx)
+		if err != nil {
+			t.log.Debug("Could not fetch latest block number", "err", err.Error())
+			continue
+		}
+
+		for ; t.next <= head; t.next++ {
+			var blk *block
+			if err = t.eth.Client().CallContext(t.ctx, &blk, "eth_getBlockByNumber", hexutil.EncodeUint64(t.next), false); err != nil {
+				t.log.Debug("Could not fetch block", "number", t.next, "err", err.Error())
+				break
+			}
+
+			// the node can report a head before it serves the block
+			if blk == nil {
+				break
+			}
+
+			t.Include(time.Now(), blk.Transactions)
+		}
+	}
+}
+
+// Include records the latency of the tracked transactions of a block observed at the provided time
+func (t *Tracker) Include(observedAt time.Time, hashes []common.Hash) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	for _, hash := range hashes {
+		sentAt, ok := t.pending[hash]
+		if !ok {
+			continue
+		}
+
+		delete(t.pending, hash)
+
+		// the block is observed after the send on the same clock, so the latency is not negative,
+		// but it is late by up to a poll interval
+		inclusionLatency := observedAt.Sub(sentAt)
+
+		t.latencies.Record(inclusionLatency)
+		t.prom.ObserveTxInclusionLatency(inclusionLatency.Seconds())
+	}
+
+	t.evict(observedAt)
+	t.prom.SetTxPendingInclusion(float64(len(t.pending)))
+}
+
+// evict stops tracking the transactions sent longer than the max age before the block observation,
+// like the dropped and the replaced ones, and counts them as not included. The caller must hold the lock
+func (t *Tracker) evict(observedAt time.Time) {
+	if t.maxAge == 0 {
+		return
+	}
+
+	for hash, sentAt := range t.pending {
+		if observedAt.Sub(sentAt) > t.maxAge {
+			delete(t.pending, hash)
+			t.expired++
+		}
+	}
+}
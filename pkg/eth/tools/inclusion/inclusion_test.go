@@ -0,0 +1,197 @@
+package inclusion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/prom"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metrics are registered globally, so all the tests share them
+var testProm = prom.NewPrometheus(conf.Conf{}, logger.NewZapLogger())
+
+// chain serves the latest block number and the blocks added to it
+type chain struct {
+	mux    sync.Mutex
+	head   uint64
+	blocks map[uint64]block
+}
+
+func (c *chain) add(number uint64, blk block) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.blocks[number] = blk
+	c.head = max(c.head, number)
+}
+
+func (c *chain) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		c.mux.Lock()
+		defer c.mux.Unlock()
+
+		var result interface{}
+
+		switch req.Method {
+		case "eth_blockNumber":
+			result = hexutil.Uint64(c.head)
+		case "eth_getBlockByNumber":
+			var number hexutil.Uint64
+			require.NoError(t, json.Unmarshal(req.Params[0], &number))
+
+			if blk, ok := c.blocks[uint64(number)]; ok {
+				result = blk
+			}
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  result,
+		}))
+	}))
+}
+
+func TestTracker_WatchesBlocks(t *testing.T) {
+	c := &chain{head: 10, blocks: map[uint64]block{}}
+
+	srv := c.serve(t)
+	defer srv.Close()
+
+	eth, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+
+	defer eth.Close()
+
+	tracker := New(context.Background(), logger.NewZapLogger(), eth, testProm, 0)
+	require.NoError(t, tracker.Start())
+
+	var (
+		sentAt   = time.Now()
+		included = []common.Hash{{1}, {2}, {3}}
+		dropped  = common.Hash{4}
+		failed   = common.Hash{5}
+	)
+
+	for _, hash := range append(included, dropped, failed) {
+		tracker.Track(hash, sentAt)
+	}
+
+	tracker.Forget(failed)
+
+	// blocks up to the head at the start are not checked
+	c.add(10, block{Transactions: []common.Hash{dropped}})
+	c.add(11, block{Transactions: []common.Hash{included[0], {9}}})
+	c.add(12, block{Transactions: included[1:]})
+
+	report := tracker.Stop(2 * time.Second)
+
+	assert.Equal(t, int64(3), report.Latency.Count)
+	assert.Equal(t, int64(1), report.NotIncluded)
+
+	// the latency runs until the blocks are observed, on the local clock
+	assert.Positive(t, report.Latency.Mean)
+	assert.LessOrEqual(t, report.Latency.Max, time.Since(sentAt))
+}
+
+func TestTracker_Report(t *testing.T) {
+	tracker := New(context.Background(), logger.NewZapLogger(), nil, testProm, 0)
+	sentAt := time.Unix(1700000000, 0)
+
+	hashes := make([]common.Hash, 0)
+
+	for ind := range make([]struct{}, 100) {
+		hash := common.BigToHash(common.Big1)
+		hash[0] = byte(ind)
+
+		tracker.Track(hash, sentAt)
+		hashes = append(hashes, hash)
+	}
+
+	// a transaction included in the block observed right after its send has no latency
+	tracker.Include(sentAt, hashes[:1])
+
+	// every following block includes the next 11 transactions, a second later than the previous one
+	for ind := 1; ind < 91; ind += 11 {
+		tracker.Include(sentAt.Add(time.Duration(ind/11+1)*time.Second), hashes[ind:ind+11])
+	}
+
+	report := tracker.Report()
+
+	assert.Equal(t, int64(100), report.Latency.Count)
+	assert.Zero(t, report.NotIncluded)
+	assert.Equal(t, 5*time.Second, report.Latency.P50)
+	assert.Equal(t, 9*time.Second, report.Latency.P90)
+	assert.Equal(t, 9*time.Second, report.Latency.P99)
+	assert.Equal(t, 9*time.Second, report.Latency.Max)
+
+	// 0s, 1s | 2s | 3s | 4s, 5s | 6s to 9s | nothing above 10s
+	assert.Equal(t, []int64{12, 11, 11, 22, 44, 0, 0, 0, 0}, report.Latency.Histogram)
+}
+
+func TestTracker_Evicts(t *testing.T) {
+	tracker := New(context.Background(), logger.NewZapLogger(), nil, testProm, time.Minute)
+	observedAt := time.Unix(1700000000, 0)
+
+	var (
+		replaced = common.Hash{1}
+		included = common.Hash{2}
+		pending  = common.Hash{3}
+	)
+
+	tracker.Track(replaced, observedAt.Add(-2*time.Minute))
+	tracker.Track(included, observedAt.Add(-2*time.Minute))
+	tracker.Track(pending, observedAt.Add(-time.Second))
+
+	// the transactions included in the block are recorded before the old ones are evicted
+	tracker.Include(observedAt, []common.Hash{included})
+
+	report := tracker.Report()
+
+	assert.Equal(t, 1, tracker.Pending())
+	assert.Equal(t, int64(1), report.Latency.Count)
+	assert.Equal(t, int64(2), report.NotIncluded)
+}
+
+func TestTracker_Fed(t *testing.T) {
+	tracker := New(context.Background(), logger.NewZapLogger(), nil, testProm, 0)
+	sentAt := time.Now()
+
+	tracker.Track(common.Hash{1}, sentAt)
+	tracker.Track(common.Hash{2}, sentAt)
+
+	assert.Equal(t, []common.Hash{{1}}, tracker.Tracked([]common.Hash{{1}, {3}}))
+
+	tracker.Include(sentAt.Add(time.Second), []common.Hash{{1}})
+
+	// the tracker was not started, so there is no watching to stop
+	report := tracker.Stop(0)
+
+	assert.Equal(t, int64(1), report.Latency.Count)
+	assert.Equal(t, time.Second, report.Latency.Max)
+	assert.Equal(t, int64(1), report.NotIncluded)
+}
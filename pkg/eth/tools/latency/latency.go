@@ -0,0 +1,135 @@
+package latency
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// maxSamples is the number of durations kept for the percentiles, sampled uniformly from all the recorded ones
+const maxSamples = 100000
+
+// Distribution records durations with bounded memory, and summarizes them with percentiles and a histogram
+type Distribution struct {
+	mux     sync.Mutex
+	buckets []time.Duration
+
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+	samples []time.Duration
+	// counts has a count for every bucket, and the last one for the durations above all of them
+	counts []int64
+}
+
+// Summary summarizes the recorded durations
+type Summary struct {
+	Count int64
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+
+	// Buckets are the upper bounds of the histogram buckets
+	Buckets []time.Duration
+	// Histogram has a count for every bucket, and the last one for the durations above all of them
+	Histogram []int64
+}
+
+// NewDistribution returns a distribution with the provided histogram bucket upper bounds, in ascending order
+func NewDistribution(buckets []time.Duration) *Distribution {
+	return &Distribution{
+		buckets: buckets,
+		samples: make([]time.Duration, 0),
+		counts:  make([]int64, len(buckets)+1),
+	}
+}
+
+func (d *Distribution) Record(value time.Duration) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.count++
+	d.sum += value
+	d.max = max(d.max, value)
+
+	bucket, _ := slices.BinarySearch(d.buckets, value)
+	d.counts[bucket]++
+
+	// reservoir sampling keeps a uniform sample of the durations with bounded memory
+	if len(d.samples) < maxSamples {
+		d.samples = append(d.samples, value)
+	} else if ind := rand.Int63n(d.count); ind < maxSamples {
+		d.samples[ind] = value
+	}
+}
+
+func (d *Distribution) Summary() Summary {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	summary := Summary{
+		Count:     d.count,
+		Max:       d.max,
+		Buckets:   d.buckets,
+		Histogram: slices.Clone(d.counts),
+	}
+
+	if d.count != 0 {
+		summary.Mean = d.sum / time.Duration(d.count)
+	}
+
+	samples := slices.Clone(d.samples)
+	slices.Sort(samples)
+
+	summary.P50 = Percentile(samples, 50)
+	summary.P90 = Percentile(samples, 90)
+	summary.P99 = Percentile(samples, 99)
+
+	return summary
+}
+
+// Render writes the histogram of the summary as a table, with the percentiles in the footer
+func (s Summary) Render(w io.Writer, title string) {
+	if s.Count == 0 {
+		return
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{title, "TXS", "SHARE"})
+
+	for ind, count := range s.Histogram {
+		bucket := fmt.Sprintf("> %s", s.Buckets[len(s.Buckets)-1])
+		if ind < len(s.Buckets) {
+			bucket = fmt.Sprintf("<= %s", s.Buckets[ind])
+		}
+
+		table.Append([]string{
+			bucket,
+			fmt.Sprintf("%d", count),
+			fmt.Sprintf("%.2f%%", float64(count)/float64(s.Count)*100),
+		})
+	}
+
+	table.SetFooter([]string{"P50 / P90 / P99", fmt.Sprintf("%s / %s / %s", s.P50, s.P90, s.P99), fmt.Sprintf("MAX %s", s.Max)})
+	table.Render()
+}
+
+// Percentile returns the nearest-rank percentile of the sorted values
+func Percentile[T cmp.Ordered](sorted []T, pct int) T {
+	if len(sorted) == 0 {
+		var zero T
+		return zero
+	}
+
+	rank := (pct*len(sorted) + 99) / 100
+
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,58 @@
+package latency
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistribution_Summary(t *testing.T) {
+	dist := NewDistribution([]time.Duration{time.Second, 2 * time.Second, 5 * time.Second})
+
+	for ind := range make([]struct{}, 100) {
+		// 1ms to 100ms, and ten values above all the buckets
+		dist.Record(time.Duration(ind+1) * time.Millisecond)
+
+		if ind%10 == 0 {
+			dist.Record(time.Duration(ind+1) * time.Second)
+		}
+	}
+
+	summary := dist.Summary()
+
+	assert.Equal(t, int64(110), summary.Count)
+	assert.Equal(t, 91*time.Second, summary.Max)
+	assert.Equal(t, 55*time.Millisecond, summary.P50)
+	assert.Equal(t, 81*time.Second, summary.P99)
+	assert.Equal(t, []int64{101, 0, 0, 9}, summary.Histogram)
+}
+
+func TestSummary_Render(t *testing.T) {
+	var buf bytes.Buffer
+
+	Summary{}.Render(&buf, "LATENCY")
+	assert.Empty(t, buf.String(), "an empty summary is not rendered")
+
+	dist := NewDistribution([]time.Duration{time.Second})
+	dist.Record(500 * time.Millisecond)
+	dist.Record(3 * time.Second)
+
+	dist.Summary().Render(&buf, "LATENCY")
+
+	assert.Contains(t, buf.String(), "<= 1s")
+	assert.Contains(t, buf.String(), "> 1s")
+	assert.Contains(t, buf.String(), "50.00%")
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Equal(t, time.Duration(5), Percentile(sorted, 50))
+	assert.Equal(t, time.Duration(9), Percentile(sorted, 90))
+	assert.Equal(t, time.Duration(10), Percentile(sorted, 99))
+	assert.Equal(t, time.Duration(1), Percentile(sorted, 0))
+	assert.Zero(t, Percentile([]time.Duration(nil), 50))
+	assert.Equal(t, 9.5, Percentile([]float64{0.5, 9.5}, 90))
+}
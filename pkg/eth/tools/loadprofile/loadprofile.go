@@ -0,0 +1,147 @@
+package loadprofile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+)
+
+var ErrLoadProfileNotSupported = errors.New("load profile not supported")
+
+// Profile defines how many transactions the long-sender sends during the run
+type Profile interface {
+	// Rate returns the number of transactions to send in the send interval starting after the elapsed time
+	Rate(elapsed time.Duration) int64
+}
+
+// factoryFunc is the function which must return Profile interface
+type factoryFunc func(conf.Conf) Profile
+
+// profilesFactory is a map of functions, with conf.LoadProfile as key, that returns a Profile interface.
+var profilesFactory = map[conf.LoadProfile]factoryFunc{
+	conf.FlatProfile: func(conf conf.Conf) Profile {
+		return flat{rate: conf.TxPerSec}
+	},
+	conf.RampProfile: func(conf conf.Conf) Profile {
+		return ramp{
+			from:   conf.BaseTxPerSec,
+			to:     conf.TxPerSec,
+			period: time.Duration(conf.ProfilePeriodSec) * time.Second,
+		}
+	},
+	conf.StepProfile: func(conf conf.Conf) Profile {
+		return step{
+			from: conf.BaseTxPerSec,
+			to:   conf.TxPerSec,
+			step: conf.StepTxPerSec,
+			hold: time.Duration(conf.ProfilePeriodSec) * time.Second,
+		}
+	},
+	conf.SpikeProfile: func(conf conf.Conf) Profile {
+		return spike{
+			base:   conf.BaseTxPerSec,
+			peak:   conf.TxPerSec,
+			period: time.Duration(conf.ProfilePeriodSec) * time.Second,
+			length: time.Duration(conf.SpikeSec) * time.Second,
+		}
+	},
+	conf.SineProfile: func(conf conf.Conf) Profile {
+		return sine{
+			low:    conf.BaseTxPerSec,
+			high:   conf.TxPerSec,
+			period: time.Duration(conf.ProfilePeriodSec) * time.Second,
+		}
+	},
+}
+
+func New(conf conf.Conf) (Profile, error) {
+	profileConstructor, ok := profilesFactory[conf.LoadProfile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrLoadProfileNotSupported, conf.LoadProfile)
+	}
+
+	return profileConstructor(conf), nil
+}
+
+// flat sends the same number of transactions for the whole run
+type flat struct {
+	rate int64
+}
+
+func (f flat) Rate(_ time.Duration) int64 {
+	return f.rate
+}
+
+// ramp increases the rate linearly over the period and holds it afterward
+type ramp struct {
+	from   int64
+	to     int64
+	period time.Duration
+}
+
+func (r ramp) Rate(elapsed time.Duration) int64 {
+	if elapsed >= r.period {
+		return r.to
+	}
+
+	return r.from + int64(float64(r.to-r.from)*float64(elapsed)/float64(r.period))
+}
+
+// step increases the rate by a fixed number of transactions after every hold time, up to the maximum rate
+type step struct {
+	from int64
+	to   int64
+	step int64
+	hold time.Duration
+}
+
+func (s step) Rate(elapsed time.Duration) int64 {
+	return min(s.from+s.step*int64(elapsed/s.hold), s.to)
+}
+
+// spike holds the base rate and jumps to the peak rate at the end of every period
+type spike struct {
+	base   int64
+	peak   int64
+	period time.Duration
+	length time.Duration
+}
+
+func (s spike) Rate(elapsed time.Duration) int64 {
+	if elapsed%s.period >= s.period-s.length {
+		return s.peak
+	}
+
+	return s.base
+}
+
+// sine moves the rate between the low and the high rate as a sine wave, starting from the low rate
+type sine struct {
+	low    int64
+	high   int64
+	period time.Duration
+}
+
+func (s sine) Rate(elapsed time.Duration) int64 {
+	var (
+		mid   = float64(s.low+s.high) / 2
+		amp   = float64(s.high-s.low) / 2
+		phase = 2*math.Pi*float64(elapsed%s.period)/float64(s.period) - math.Pi/2
+	)
+
+	return int64(math.Round(mid + amp*math.Sin(phase)))
+}
+
+// SignerShare returns the part of the rate sent by the signer with the provided index,
+// the remainder of an uneven split is sent by the first signers
+func SignerShare(rate int64, signers int, index int) int64 {
+	share := rate / int64(signers)
+	if int64(index) < rate%int64(signers) {
+		share++
+	}
+
+	return share
+}
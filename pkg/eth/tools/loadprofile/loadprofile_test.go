@@ -0,0 +1,143 @@
+package loadprofile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	var cnf = conf.Conf{
+		TxPerSec:         100,
+		BaseTxPerSec:     20,
+		StepTxPerSec:     30,
+		ProfilePeriodSec: 60,
+		SpikeSec:         10,
+	}
+
+	var testCases = []struct {
+		name    string
+		profile conf.LoadProfile
+		rates   map[time.Duration]int64
+	}{
+		{
+			name:    "Flat",
+			profile: conf.FlatProfile,
+			rates: map[time.Duration]int64{
+				0:                100,
+				time.Hour:        100,
+				90 * time.Second: 100,
+			},
+		},
+		{
+			name:    "Ramp",
+			profile: conf.RampProfile,
+			rates: map[time.Duration]int64{
+				0:                20,
+				15 * time.Second: 40,
+				30 * time.Second: 60,
+				60 * time.Second: 100,
+				time.Hour:        100,
+			},
+		},
+		{
+			name:    "Step",
+			profile: conf.StepProfile,
+			rates: map[time.Duration]int64{
+				0:                 20,
+				59 * time.Second:  20,
+				60 * time.Second:  50,
+				150 * time.Second: 80,
+				180 * time.Second: 100,
+				time.Hour:         100,
+			},
+		},
+		{
+			name:    "Spike",
+			profile: conf.SpikeProfile,
+			rates: map[time.Duration]int64{
+				0:                 20,
+				49 * time.Second:  20,
+				50 * time.Second:  100,
+				59 * time.Second:  100,
+				60 * time.Second:  20,
+				115 * time.Second: 100,
+			},
+		},
+		{
+			name:    "Sine",
+			profile: conf.SineProfile,
+			rates: map[time.Duration]int64{
+				0:                20,
+				15 * time.Second: 60,
+				30 * time.Second: 100,
+				45 * time.Second: 60,
+				60 * time.Second: 20,
+				90 * time.Second: 100,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cnf.LoadProfile = tc.profile
+
+			profile, err := New(cnf)
+			require.NoError(t, err)
+
+			for elapsed, want := range tc.rates {
+				assert.Equal(t, want, profile.Rate(elapsed), "elapsed %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestNew_NotSupported(t *testing.T) {
+	_, err := New(conf.Conf{LoadProfile: "square"})
+	assert.ErrorIs(t, err, ErrLoadProfileNotSupported)
+}
+
+func TestSignerShare(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		rate    int64
+		signers int
+		want    []int64
+	}{
+		{
+			name:    "Even split",
+			rate:    9,
+			signers: 3,
+			want:    []int64{3, 3, 3},
+		},
+		{
+			name:    "Remainder sent by the first signers",
+			rate:    11,
+			signers: 3,
+			want:    []int64{4, 4, 3},
+		},
+		{
+			name:    "Fewer transactions than signers",
+			rate:    2,
+			signers: 4,
+			want:    []int64{1, 1, 0, 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var total int64
+
+			for ind, want := range tc.want {
+				share := SignerShare(tc.rate, tc.signers, ind)
+				assert.Equal(t, want, share)
+				total += share
+			}
+
+			assert.Equal(t, tc.rate, total)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package presign
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// diskPool keeps the pre-signed transactions of every signer in its own file,
+// so that the pool is not limited by the available memory.
+//
+// Every transaction is stored as its length, as a 4 byte big endian integer, followed by its binary encoding.
+type diskPool struct {
+	files []*diskFile
+}
+
+type diskFile struct {
+	mux    sync.Mutex
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	reader *bufio.Reader
+	// next is the transaction read from the file, but not taken yet
+	next *types.Transaction
+}
+
+func newDiskPool(dir string, signers int) (*diskPool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create pre-signed pool directory: %w", err)
+	}
+
+	pool := &diskPool{
+		files: make([]*diskFile, 0, signers),
+	}
+
+	for ind := range make([]struct{}, signers) {
+		path := filepath.Join(dir, fmt.Sprintf("signer-%d.txs", ind))
+
+		file, err := os.Create(path)
+		if err != nil {
+			_ = pool.Close()
+			return nil, fmt.Errorf("could not create pre-signed pool file: %w", err)
+		}
+
+		pool.files = append(pool.files, &diskFile{
+			path:   path,
+			file:   file,
+			writer: bufio.NewWriter(file),
+		})
+	}
+
+	return pool, nil
+}
+
+func (d *diskPool) Put(signer int, tx *types.Transaction) error {
+	f := d.files[signer]
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.writer == nil {
+		return ErrPoolSealed
+	}
+
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err = binary.Write(f.writer, binary.BigEndian, uint32(len(raw))); err != nil {
+		return err
+	}
+
+	_, err = f.writer.Write(raw)
+
+	return err
+}
+
+func (d *diskPool) Seal() error {
+	for _, f := range d.files {
+		f.mux.Lock()
+
+		if err := f.seal(); err != nil {
+			f.mux.Unlock()
+			return fmt.Errorf("could not seal pre-signed pool file %s: %w", f.path, err)
+		}
+
+		f.mux.Unlock()
+	}
+
+	return nil
+}
+
+func (d *diskPool) Take(signer int, nonce uint64) (*types.Transaction, error) {
+	f := d.files[signer]
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.reader == nil {
+		return nil, nil
+	}
+
+	for f.next == nil || f.next.Nonce() < nonce {
+		tx, err := f.read()
+		if errors.Is(err, io.EOF) {
+			f.next = nil
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not read pre-signed pool file %s: %w", f.path, err)
+		}
+
+		f.next = tx
+	}
+
+	if f.next.Nonce() != nonce {
+		return nil, nil
+	}
+
+	tx := f.next
+	f.next = nil
+
+	return tx, nil
+}
+
+// Close closes and removes the pool files
+func (d *diskPool) Close() error {
+	var errs []error
+
+	for _, f := range d.files {
+		f.mux.Lock()
+
+		if f.file != nil {
+			errs = append(errs, f.file.Close())
+			f.file = nil
+		}
+
+		errs = append(errs, os.Remove(f.path))
+		f.mux.Unlock()
+	}
+
+	return errors.Join(errs...)
+}
+
+// seal flushes the written transactions and reopens the file for reading
+func (f *diskFile) seal() error {
+	if f.writer == nil {
+		return nil
+	}
+
+	if err := f.writer.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	f.writer = nil
+	f.reader = bufio.NewReader(f.file)
+
+	return nil
+}
+
+func (f *diskFile) read() (*types.Transaction, error) {
+	var size uint32
+	if err := binary.Read(f.reader, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(f.reader, raw); err != nil {
+		return nil, err
+	}
+
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
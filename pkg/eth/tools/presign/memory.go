@@ -0,0 +1,75 @@
+package presign
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// memoryPool keeps the pre-signed transactions of every signer in memory
+type memoryPool struct {
+	mux    sync.Mutex
+	sealed bool
+	txs    [][]*types.Transaction
+}
+
+func newMemoryPool(signers int) *memoryPool {
+	return &memoryPool{
+		txs: make([][]*types.Transaction, signers),
+	}
+}
+
+func (m *memoryPool) Put(signer int, tx *types.Transaction) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.sealed {
+		return ErrPoolSealed
+	}
+
+	m.txs[signer] = append(m.txs[signer], tx)
+
+	return nil
+}
+
+func (m *memoryPool) Seal() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.sealed = true
+
+	return nil
+}
+
+func (m *memoryPool) Take(signer int, nonce uint64) (*types.Transaction, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	txs := m.txs[signer]
+
+	for len(txs) != 0 && txs[0].Nonce() < nonce {
+		txs[0] = nil
+		txs = txs[1:]
+	}
+
+	m.txs[signer] = txs
+
+	if len(txs) == 0 || txs[0].Nonce() != nonce {
+		return nil, nil
+	}
+
+	tx := txs[0]
+	txs[0] = nil
+	m.txs[signer] = txs[1:]
+
+	return tx, nil
+}
+
+func (m *memoryPool) Close() error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.txs = make([][]*types.Transaction, len(m.txs))
+
+	return nil
+}
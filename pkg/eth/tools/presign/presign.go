@@ -0,0 +1,96 @@
+package presign
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/core/types"
+	"golang.org/x/sync/errgroup"
+)
+
+var ErrPoolSealed = errors.New("pre-signed pool is sealed")
+
+// Pool holds the transactions signed before the sending starts, in nonce order for every signer
+type Pool interface {
+	// Put adds the next transaction of the signer with the provided index
+	Put(signer int, tx *types.Transaction) error
+	// Seal ends the pre-signing, transactions can only be taken from a sealed pool
+	Seal() error
+	// Take returns the pre-signed transaction of the signer with the provided nonce,
+	// dropping the transactions with lower nonces, and nil if the pool does not hold the nonce
+	Take(signer int, nonce uint64) (*types.Transaction, error)
+	// Close releases the resources held by the pool
+	Close() error
+}
+
+// New returns the on-disk pool if the pool directory is set, and the in-memory pool otherwise
+func New(conf conf.Conf, signers int) (Pool, error) {
+	if conf.PresignDir != "" {
+		return newDiskPool(conf.PresignDir, signers)
+	}
+
+	return newMemoryPool(signers), nil
+}
+
+// Fill signs count transactions for every signer, starting from its next nonce, and seals the pool
+func Fill(
+	ctx context.Context,
+	log logger.Logger,
+	pool Pool,
+	signers []*txsigner.TxSigner,
+	nonces []uint64,
+	count int,
+	nextCall func(signerIndex int) txsigner.Call,
+) error {
+	start := time.Now()
+	errGr, ctx := errgroup.WithContext(ctx)
+
+	for ind, signer := range signers {
+		ind := ind
+		signer := signer
+
+		errGr.Go(func() error {
+			for i := 0; i < count; i++ {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				tx, err := signer.GetNextSignedCall(nonces[ind]+uint64(i), nextCall(ind))
+				if err != nil {
+					return err
+				}
+
+				if err = pool.Put(ind, tx); err != nil {
+					return fmt.Errorf("could not store pre-signed transaction: %w", err)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	if err := errGr.Wait(); err != nil {
+		return err
+	}
+
+	if err := pool.Seal(); err != nil {
+		return err
+	}
+
+	total := count * len(signers)
+	elapsed := time.Since(start)
+
+	log.Info("Transactions pre-signed",
+		"total", total,
+		"per_account", count,
+		"duration", elapsed.Round(time.Millisecond).String(),
+		"signed_per_sec", fmt.Sprintf("%.2f", float64(total)/elapsed.Seconds()),
+	)
+
+	return nil
+}
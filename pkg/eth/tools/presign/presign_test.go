@@ -0,0 +1,123 @@
+package presign
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedTx(t *testing.T, nonce uint64) *types.Transaction {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x01")
+	tx, err := types.SignNewTx(key, types.NewLondonSigner(big.NewInt(1)), &types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(1),
+	})
+	require.NoError(t, err)
+
+	return tx
+}
+
+func TestPools(t *testing.T) {
+	var pools = []struct {
+		name string
+		conf conf.Conf
+	}{
+		{
+			name: "Memory",
+			conf: conf.Conf{},
+		},
+		{
+			name: "Disk",
+			conf: conf.Conf{PresignDir: filepath.Join(t.TempDir(), "pool")},
+		},
+	}
+
+	for _, tc := range pools {
+		t.Run(tc.name, func(t *testing.T) {
+			pool, err := New(tc.conf, 2)
+			require.NoError(t, err)
+
+			signed := make(map[uint64]*types.Transaction)
+
+			for nonce := uint64(10); nonce < 15; nonce++ {
+				tx := signedTx(t, nonce)
+				signed[nonce] = tx
+
+				require.NoError(t, pool.Put(0, tx))
+			}
+
+			require.NoError(t, pool.Put(1, signedTx(t, 0)))
+			require.NoError(t, pool.Seal())
+
+			assert.ErrorIs(t, pool.Put(0, signedTx(t, 15)), ErrPoolSealed)
+
+			// nonces are taken in order
+			tx, err := pool.Take(0, 10)
+			require.NoError(t, err)
+			assert.Equal(t, signed[10].Hash(), tx.Hash())
+
+			// a nonce below the pool is not held, and does not drop the pool
+			tx, err = pool.Take(0, 5)
+			require.NoError(t, err)
+			assert.Nil(t, tx)
+
+			// skipping ahead drops the lower nonces
+			tx, err = pool.Take(0, 13)
+			require.NoError(t, err)
+			assert.Equal(t, signed[13].Hash(), tx.Hash())
+
+			tx, err = pool.Take(0, 12)
+			require.NoError(t, err)
+			assert.Nil(t, tx)
+
+			tx, err = pool.Take(0, 14)
+			require.NoError(t, err)
+			assert.Equal(t, signed[14].Hash(), tx.Hash())
+
+			// the pool is exhausted
+			tx, err = pool.Take(0, 15)
+			require.NoError(t, err)
+			assert.Nil(t, tx)
+
+			// signers do not share transactions
+			tx, err = pool.Take(1, 0)
+			require.NoError(t, err)
+			require.NotNil(t, tx)
+			assert.Equal(t, uint64(0), tx.Nonce())
+
+			require.NoError(t, pool.Close())
+		})
+	}
+}
+
+func TestDiskPool_CloseRemovesFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	pool, err := New(conf.Conf{PresignDir: dir}, 3)
+	require.NoError(t, err)
+	require.NoError(t, pool.Put(2, signedTx(t, 1)))
+	require.NoError(t, pool.Seal())
+	require.NoError(t, pool.Close())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
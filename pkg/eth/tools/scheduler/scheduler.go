@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/latency"
+)
+
+// LagBuckets are the upper bounds of the schedule lag histogram
+var LagBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Job is a unit of work run by the scheduler, made of one or more items, like the transactions of a batch.
+// The lag is the time between the intended start of the job and its actual start,
+// and the job returns the number of its items that failed
+type Job func(lag time.Duration) (failed int)
+
+type scheduledJob struct {
+	intended time.Time
+	items    int
+	job      Job
+}
+
+// Scheduler runs the jobs at their intended time on a bounded pool of workers.
+//
+// The schedule does not depend on how long the jobs take, so when all the workers are busy the jobs start late,
+// and the lag between the intended and the actual start is recorded instead of silently lowering the rate.
+type Scheduler struct {
+	ctx     context.Context
+	workers int
+	jobs    chan scheduledJob
+	timer   *time.Timer
+	wg      sync.WaitGroup
+
+	mux   sync.Mutex
+	stats stats
+	lags  *latency.Distribution
+}
+
+type stats struct {
+	jobs          int64
+	scheduled     int64
+	completed     int64
+	failed        int64
+	firstIntended time.Time
+	lastDone      time.Time
+}
+
+// Report summarizes the jobs run by the scheduler, the counts are the numbers of job items
+type Report struct {
+	Scheduled int64
+	Completed int64
+	Failed    int64
+	// Duration is the time between the intended start of the first job and the end of the last one
+	Duration     time.Duration
+	AchievedRate float64
+	// Lag summarizes the schedule lags of the jobs
+	Lag latency.Summary
+}
+
+func New(ctx context.Context, workers int) *Scheduler {
+	timer := time.NewTimer(0)
+	<-timer.C
+
+	return &Scheduler{
+		ctx:     ctx,
+		workers: workers,
+		jobs:    make(chan scheduledJob),
+		timer:   timer,
+		lags:    latency.NewDistribution(LagBuckets),
+	}
+}
+
+// Start starts the workers
+func (s *Scheduler) Start() {
+	for range make([]struct{}, s.workers) {
+		s.wg.Add(1)
+
+		go s.work()
+	}
+}
+
+// Schedule waits for the intended time and hands the job to the first free worker,
+// it must be called from a single goroutine and returns false if the context is done before the job is handed over
+func (s *Scheduler) Schedule(intended time.Time, items int, job Job) bool {
+	if wait := time.Until(intended); wait > 0 {
+		s.timer.Reset(wait)
+
+		select {
+		case <-s.timer.C:
+		case <-s.ctx.Done():
+			if !s.timer.Stop() {
+				<-s.timer.C
+			}
+
+			return false
+		}
+	}
+
+	// the job is counted before the hand over, so a report never has more finished than scheduled jobs
+	s.mux.Lock()
+	if s.stats.scheduled == 0 {
+		s.stats.firstIntended = intended
+	}
+	s.stats.scheduled += int64(items)
+	s.mux.Unlock()
+
+	select {
+	case s.jobs <- scheduledJob{intended: intended, items: items, job: job}:
+		return true
+	case <-s.ctx.Done():
+		s.mux.Lock()
+		s.stats.scheduled -= int64(items)
+		s.mux.Unlock()
+
+		return false
+	}
+}
+
+// Stop waits for the running jobs to finish and returns the report of all the jobs
+func (s *Scheduler) Stop() Report {
+	close(s.jobs)
+	s.wg.Wait()
+
+	return s.Report()
+}
+
+// Report returns the report of the jobs finished so far
+func (s *Scheduler) Report() Report {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	report := Report{
+		Scheduled: s.stats.scheduled,
+		Completed: s.stats.completed,
+		Failed:    s.stats.failed,
+		Lag:       s.lags.Summary(),
+	}
+
+	if s.stats.jobs != 0 {
+		report.Duration = s.stats.lastDone.Sub(s.stats.firstIntended)
+	}
+
+	if report.Duration > 0 {
+		report.AchievedRate = float64(s.stats.completed) / report.Duration.Seconds()
+	}
+
+	return report
+}
+
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+
+	for j := range s.jobs {
+		lag := time.Since(j.intended)
+		failed := j.job(lag)
+
+		s.record(lag, j.items, failed, time.Now())
+	}
+}
+
+func (s *Scheduler) record(lag time.Duration, items, failed int, done time.Time) {
+	s.lags.Record(lag)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.stats.jobs++
+	s.stats.completed += int64(items - failed)
+	s.stats.failed += int64(failed)
+
+	if done.After(s.stats.lastDone) {
+		s.stats.lastDone = done
+	}
+}
+
+// Spread returns the intended start times of n jobs spread evenly over the interval
+func Spread(start time.Time, interval time.Duration, n int64) []time.Time {
+	times := make([]time.Time, 0, n)
+
+	for i := int64(0); i < n; i++ {
+		times = append(times, start.Add(time.Duration(i)*interval/time.Duration(n)))
+	}
+
+	return times
+}
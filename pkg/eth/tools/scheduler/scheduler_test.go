@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_OnTime(t *testing.T) {
+	var (
+		sched = New(context.Background(), 10)
+		ran   atomic.Int64
+		start = time.Now().Add(10 * time.Millisecond)
+	)
+
+	sched.Start()
+
+	for _, intended := range Spread(start, 100*time.Millisecond, 20) {
+		require.True(t, sched.Schedule(intended, 1, func(_ time.Duration) int {
+			ran.Add(1)
+			return 0
+		}))
+
+		// jobs never start before their intended time
+		assert.False(t, time.Now().Before(intended))
+	}
+
+	report := sched.Stop()
+
+	assert.Equal(t, int64(20), ran.Load())
+	assert.Equal(t, int64(20), report.Scheduled)
+	assert.Equal(t, int64(20), report.Completed)
+	assert.Zero(t, report.Failed)
+	assert.Less(t, report.Lag.P99, 50*time.Millisecond)
+	assert.InDelta(t, 200, report.AchievedRate, 60)
+}
+
+func TestScheduler_RecordsLagWhenWorkersAreBusy(t *testing.T) {
+	var (
+		sched = New(context.Background(), 1)
+		start = time.Now()
+	)
+
+	sched.Start()
+
+	// a single worker can run one 20ms job at a time, so jobs intended every 5ms start later and later
+	for _, intended := range Spread(start, 50*time.Millisecond, 10) {
+		sched.Schedule(intended, 1, func(_ time.Duration) int {
+			time.Sleep(20 * time.Millisecond)
+			return 0
+		})
+	}
+
+	report := sched.Stop()
+
+	assert.Equal(t, int64(10), report.Completed)
+	// the last job is intended at 45ms but can only start after nine jobs of 20ms
+	assert.GreaterOrEqual(t, report.Lag.Max, 130*time.Millisecond)
+	assert.Greater(t, report.Lag.P50, 10*time.Millisecond)
+	assert.Less(t, report.AchievedRate, 60.0)
+}
+
+func TestScheduler_Failures(t *testing.T) {
+	sched := New(context.Background(), 2)
+	sched.Start()
+
+	for ind, intended := range Spread(time.Now(), 10*time.Millisecond, 4) {
+		ind := ind
+
+		sched.Schedule(intended, 1, func(_ time.Duration) int {
+			if ind%2 == 0 {
+				return 1
+			}
+
+			return 0
+		})
+	}
+
+	report := sched.Stop()
+
+	assert.Equal(t, int64(2), report.Completed)
+	assert.Equal(t, int64(2), report.Failed)
+}
+
+func TestScheduler_Batches(t *testing.T) {
+	sched := New(context.Background(), 2)
+	sched.Start()
+
+	for _, intended := range Spread(time.Now(), 10*time.Millisecond, 3) {
+		// every batch of 10 items has 3 failed items
+		sched.Schedule(intended, 10, func(_ time.Duration) int {
+			return 3
+		})
+	}
+
+	report := sched.Stop()
+
+	assert.Equal(t, int64(30), report.Scheduled)
+	assert.Equal(t, int64(21), report.Completed)
+	assert.Equal(t, int64(9), report.Failed)
+}
+
+func TestScheduler_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sched := New(ctx, 1)
+	sched.Start()
+
+	cancel()
+
+	assert.False(t, sched.Schedule(time.Now().Add(time.Hour), 1, func(_ time.Duration) int { return 0 }))
+	assert.Zero(t, sched.Stop().Scheduled)
+}
+
+func TestSpread(t *testing.T) {
+	start := time.Now()
+	times := Spread(start, time.Second, 4)
+
+	require.Len(t, times, 4)
+
+	for ind, intended := range times {
+		assert.Equal(t, time.Duration(ind)*250*time.Millisecond, intended.Sub(start))
+	}
+
+	assert.Empty(t, Spread(start, time.Second, 0))
+}
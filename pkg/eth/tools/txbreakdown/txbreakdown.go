@@ -0,0 +1,211 @@
+package txbreakdown
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	ethTypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Breakdown splits the transactions of a range of blocks by sender, recipient and transaction type,
+// and the gas they used by recipient, to tell the load test traffic from the organic one
+type Breakdown struct {
+	Transactions int    `json:"transactions"`
+	GasUsed      uint64 `json:"gas_used"`
+	// Senders are the senders of the most transactions, with their share of the transactions
+	Senders []Entry `json:"top_senders"`
+	// Recipients are the recipients of the most transactions, with their share of the transactions.
+	// A contract creation counts for the created contract
+	Recipients []Entry `json:"top_recipients"`
+	// Types are all the transaction types, with their share of the transactions
+	Types []Entry `json:"tx_types"`
+	// Gas are the recipients that used the most gas, with their share of the gas used by the transactions
+	Gas []Entry `json:"top_gas"`
+}
+
+// Entry is an address or a transaction type, with its transactions, the gas they used, and its share in percent
+type Entry struct {
+	Name     string  `json:"name"`
+	Contract bool    `json:"contract"`
+	Txs      int     `json:"txs"`
+	GasUsed  uint64  `json:"gas_used"`
+	Share    float64 `json:"share_pct"`
+}
+
+// Calculate returns the breakdown of the receipts, with the top entries of the addresses
+func Calculate(receipts []types.Receipt, top int) Breakdown {
+	var (
+		breakdown  = Breakdown{Transactions: len(receipts)}
+		senders    = make(map[string]*Entry)
+		recipients = make(map[string]*Entry)
+		txTypes    = make(map[string]*Entry)
+	)
+
+	for _, receipt := range receipts {
+		breakdown.GasUsed += receipt.GasUsed
+
+		recipient, created := receipt.To, false
+		if recipient == "" {
+			recipient, created = receipt.ContractAddress, true
+		}
+
+		add(senders, receipt.From, receipt)
+		add(txTypes, TypeName(receipt.Type), receipt)
+
+		// a created contract is known to be a contract, the other recipients are checked by Label
+		if entry := add(recipients, recipient, receipt); created {
+			entry.Contract = true
+		}
+	}
+
+	breakdown.Senders = txShares(senders, breakdown.Transactions, top)
+	breakdown.Recipients = txShares(recipients, breakdown.Transactions, top)
+	breakdown.Types = txShares(txTypes, breakdown.Transactions, len(txTypes))
+	breakdown.Gas = gasShares(recipients, breakdown.GasUsed, top)
+
+	return breakdown
+}
+
+// TypeName returns the name of the transaction type, as the tx-type flag names it
+func TypeName(txType uint8) string {
+	switch txType {
+	case ethTypes.LegacyTxType:
+		return "legacy"
+	case ethTypes.AccessListTxType:
+		return "access-list"
+	case ethTypes.DynamicFeeTxType:
+		return "dynamic"
+	case ethTypes.BlobTxType:
+		return "blob"
+	default:
+		return fmt.Sprintf("type-%d", txType)
+	}
+}
+
+// Label marks the recipients that are contracts, checking every address once
+func (b *Breakdown) Label(isContract func(address string) (bool, error)) error {
+	checked := make(map[string]bool)
+
+	for _, entries := range [][]Entry{b.Recipients, b.Gas} {
+		for ind := range entries {
+			if entries[ind].Contract {
+				checked[entries[ind].Name] = true
+				continue
+			}
+
+			contract, ok := checked[entries[ind].Name]
+			if !ok {
+				var err error
+				if contract, err = isContract(entries[ind].Name); err != nil {
+					return fmt.Errorf("could not check address %s: %w", entries[ind].Name, err)
+				}
+
+				checked[entries[ind].Name] = contract
+			}
+
+			entries[ind].Contract = contract
+		}
+	}
+
+	return nil
+}
+
+// Render writes the breakdown as tables
+func (b Breakdown) Render(w io.Writer) {
+	senders := tablewriter.NewWriter(w)
+	senders.SetHeader([]string{"TOP SENDER", "TXS", "SHARE"})
+
+	for _, entry := range b.Senders {
+		senders.Append([]string{entry.Name, fmt.Sprintf("%d", entry.Txs), fmt.Sprintf("%.2f%%", entry.Share)})
+	}
+
+	senders.Render()
+
+	recipients := tablewriter.NewWriter(w)
+	recipients.SetHeader([]string{"TOP RECIPIENT", "CONTRACT", "TXS", "SHARE"})
+
+	for _, entry := range b.Recipients {
+		recipients.Append([]string{entry.Name, fmt.Sprintf("%t", entry.Contract), fmt.Sprintf("%d", entry.Txs), fmt.Sprintf("%.2f%%", entry.Share)})
+	}
+
+	recipients.Render()
+
+	txTypes := tablewriter.NewWriter(w)
+	txTypes.SetHeader([]string{"TX TYPE", "TXS", "SHARE"})
+
+	for _, entry := range b.Types {
+		txTypes.Append([]string{entry.Name, fmt.Sprintf("%d", entry.Txs), fmt.Sprintf("%.2f%%", entry.Share)})
+	}
+
+	txTypes.Render()
+
+	gas := tablewriter.NewWriter(w)
+	gas.SetHeader([]string{"TOP GAS RECIPIENT", "CONTRACT", "GAS USED", "SHARE"})
+
+	for _, entry := range b.Gas {
+		gas.Append([]string{entry.Name, fmt.Sprintf("%t", entry.Contract), fmt.Sprintf("%d", entry.GasUsed), fmt.Sprintf("%.2f%%", entry.Share)})
+	}
+
+	gas.Render()
+}
+
+// add counts the receipt for the named entry, and returns the entry
+func add(entries map[string]*Entry, name string, receipt types.Receipt) *Entry {
+	entry, ok := entries[name]
+	if !ok {
+		entry = &Entry{Name: name}
+		entries[name] = entry
+	}
+
+	entry.Txs++
+	entry.GasUsed += receipt.GasUsed
+
+	return entry
+}
+
+// txShares returns the top entries by transactions, with their share of the total transactions
+func txShares(entries map[string]*Entry, total, top int) []Entry {
+	return topEntries(entries, top, func(entry *Entry) float64 {
+		return float64(entry.Txs) / float64(max(total, 1)) * 100
+	}, func(a, b *Entry) int {
+		return cmp.Compare(b.Txs, a.Txs)
+	})
+}
+
+// gasShares returns the top entries by gas used, with their share of the total gas used
+func gasShares(entries map[string]*Entry, total uint64, top int) []Entry {
+	return topEntries(entries, top, func(entry *Entry) float64 {
+		return float64(entry.GasUsed) / float64(max(total, 1)) * 100
+	}, func(a, b *Entry) int {
+		return cmp.Compare(b.GasUsed, a.GasUsed)
+	})
+}
+
+// topEntries sorts the entries, the ties by name so that the order is stable, and returns the top ones with their shares
+func topEntries(entries map[string]*Entry, top int, share func(*Entry) float64, compare func(a, b *Entry) int) []Entry {
+	sorted := make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		sorted = append(sorted, entry)
+	}
+
+	slices.SortFunc(sorted, func(a, b *Entry) int {
+		if order := compare(a, b); order != 0 {
+			return order
+		}
+
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	result := make([]Entry, 0, min(top, len(sorted)))
+	for _, entry := range sorted[:min(top, len(sorted))] {
+		shared := *entry
+		shared.Share = share(entry)
+		result = append(result, shared)
+	}
+
+	return result
+}
@@ -0,0 +1,102 @@
+package txbreakdown
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReceipts() []types.Receipt {
+	return []types.Receipt{
+		{From: "0xa", To: "0xc", Type: 2, GasUsed: 50000},
+		{From: "0xa", To: "0xc", Type: 2, GasUsed: 50000},
+		{From: "0xa", To: "0xd", Type: 0, GasUsed: 21000},
+		{From: "0xb", To: "0xd", Type: 1, GasUsed: 21000},
+		{From: "0xb", ContractAddress: "0xe", Type: 3, GasUsed: 258000},
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	breakdown := Calculate(testReceipts(), 2)
+
+	assert.Equal(t, 5, breakdown.Transactions)
+	assert.Equal(t, uint64(400000), breakdown.GasUsed)
+
+	assert.Equal(t, []Entry{
+		{Name: "0xa", Txs: 3, GasUsed: 121000, Share: 60},
+		{Name: "0xb", Txs: 2, GasUsed: 279000, Share: 40},
+	}, breakdown.Senders)
+
+	// the recipients with the same number of transactions are ordered by address
+	assert.Equal(t, []Entry{
+		{Name: "0xc", Txs: 2, GasUsed: 100000, Share: 40},
+		{Name: "0xd", Txs: 2, GasUsed: 42000, Share: 40},
+	}, breakdown.Recipients)
+
+	assert.Equal(t, []Entry{
+		{Name: "dynamic", Txs: 2, GasUsed: 100000, Share: 40},
+		{Name: "access-list", Txs: 1, GasUsed: 21000, Share: 20},
+		{Name: "blob", Txs: 1, GasUsed: 258000, Share: 20},
+		{Name: "legacy", Txs: 1, GasUsed: 21000, Share: 20},
+	}, breakdown.Types)
+
+	// the created contract is known to be a contract
+	assert.Equal(t, []Entry{
+		{Name: "0xe", Contract: true, Txs: 1, GasUsed: 258000, Share: 64.5},
+		{Name: "0xc", Txs: 2, GasUsed: 100000, Share: 25},
+	}, breakdown.Gas)
+}
+
+func TestCalculate_NoReceipts(t *testing.T) {
+	breakdown := Calculate(nil, 10)
+
+	assert.Zero(t, breakdown.Transactions)
+	assert.Empty(t, breakdown.Senders)
+	assert.Empty(t, breakdown.Types)
+}
+
+func TestTypeName(t *testing.T) {
+	assert.Equal(t, "legacy", TypeName(0))
+	assert.Equal(t, "blob", TypeName(3))
+	assert.Equal(t, "type-126", TypeName(126))
+}
+
+func TestBreakdown_Label(t *testing.T) {
+	var (
+		breakdown = Calculate(testReceipts(), 3)
+		checked   = make([]string, 0)
+	)
+
+	require.NoError(t, breakdown.Label(func(address string) (bool, error) {
+		checked = append(checked, address)
+		return address == "0xc", nil
+	}))
+
+	// every address is checked once, the created contract is not checked
+	assert.ElementsMatch(t, []string{"0xc", "0xd"}, checked)
+
+	for _, entry := range append(breakdown.Recipients, breakdown.Gas...) {
+		assert.Equal(t, entry.Name != "0xd", entry.Contract, entry.Name)
+	}
+
+	err := breakdown.Label(func(string) (bool, error) {
+		return false, errors.New("node unavailable")
+	})
+	assert.Error(t, err)
+}
+
+func TestBreakdown_Render(t *testing.T) {
+	var buf bytes.Buffer
+
+	Calculate(testReceipts(), 2).Render(&buf)
+
+	for _, header := range []string{"TOP SENDER", "TOP RECIPIENT", "TX TYPE", "TOP GAS RECIPIENT"} {
+		assert.Contains(t, buf.String(), header)
+	}
+
+	assert.Contains(t, buf.String(), "64.50%")
+}
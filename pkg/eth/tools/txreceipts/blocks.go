@@ -0,0 +1,179 @@
+package txreceipts
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// headsBuffer is the number of new block headers buffered while a block is searched
+const headsBuffer = 64
+
+// fetchReceiptsByBlocks searches every block built since Start for the sent transactions, driven by a newHeads
+// subscription, until all the transactions are included or the context is done.
+// It returns false if the receipts must be polled per transaction instead: if Start was not called,
+// if the endpoint does not support subscriptions, or if the subscription is lost
+func (r *TxReceipts) fetchReceiptsByBlocks(ctx context.Context) bool {
+	if !r.started {
+		return false
+	}
+
+	heads := make(chan *types.Header, headsBuffer)
+
+	sub, err := r.eth.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		r.log.Debug("Could not subscribe to new blocks, polling the receipt of every transaction", "err", err.Error())
+		return false
+	}
+
+	defer sub.Unsubscribe()
+
+	// the blocks built before the subscription are searched first
+	if head, err := r.eth.BlockNumber(ctx); err != nil {
+		r.log.Debug("Could not fetch latest block number", "err", err.Error())
+	} else {
+		r.searchBlocks(ctx, head)
+	}
+
+	for r.safeReceipts.pending() != 0 {
+		select {
+		case <-ctx.Done():
+			r.log.Error("Could not verify transactions",
+				"verification_duration", r.conf.WaitForConfirmTimeout,
+				"not_included", r.safeReceipts.pending(),
+			)
+
+			return true
+		case err = <-sub.Err():
+			r.log.Warn("New blocks subscription lost, polling the receipts of the remaining transactions", "err", err)
+
+			return false
+		case header := <-heads:
+			// a reorg replaces blocks that could already be searched
+			if number := header.Number.Uint64(); number < r.next {
+				r.next = number
+			}
+
+			r.searchBlocks(ctx, header.Number.Uint64())
+		}
+	}
+
+	return true
+}
+
+// searchBlocks stores the receipts of the sent transactions included in the blocks up to the head,
+// and feeds the blocks to the inclusion observer. A block that can not be fetched is searched again with the next head
+func (r *TxReceipts) searchBlocks(ctx context.Context, head uint64) {
+	for ; r.next <= head; r.next++ {
+		receipts, err := r.blockReceipts(ctx, r.next)
+		if err != nil {
+			r.log.Debug("Could not fetch block receipts", "number", r.next, "err", err.Error())
+			return
+		}
+
+		if r.inclusion != nil {
+			hashes := make([]common.Hash, 0, len(receipts))
+			for _, receipt := range receipts {
+				hashes = append(hashes, receipt.TxHash)
+			}
+
+			r.inclusion.Include(time.Now(), hashes)
+		}
+
+		stored := r.safeReceipts.storeBlockReceipts(receipts)
+
+		r.log.Debug("Block searched", "number", r.next, "receipts", stored)
+	}
+}
+
+// blockReceipts returns the receipts of the block with eth_getBlockReceipts, or if the node does not serve it,
+// the receipts of the sent transactions in the block
+func (r *TxReceipts) blockReceipts(ctx context.Context, number uint64) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	if err := r.blocks.ByNumber(ctx, number, r.tracked, &receipts); err != nil {
+		return nil, err
+	}
+
+	return receipts, nil
+}
+
+// storeBlockReceipts stores the receipts of the sent transactions, skipping the receipts of other transactions,
+// and returns the number of stored receipts
+func (s *safeReceipts) storeBlockReceipts(receipts []*types.Receipt) int {
+	s.Lock()
+	defer s.Unlock()
+
+	var stored int
+
+	for _, receipt := range receipts {
+		if s.store(receipt) {
+			stored++
+		}
+	}
+
+	return stored
+}
+
+// tracked returns the hashes of the sent transactions among the provided ones,
+// and of the transactions tracked by the inclusion observer
+func (r *TxReceipts) tracked(hashes []common.Hash) []common.Hash {
+	tracked := r.safeReceipts.tracked(hashes)
+	if r.inclusion == nil {
+		return tracked
+	}
+
+	sent := make(map[common.Hash]bool, len(tracked))
+	for _, hash := range tracked {
+		sent[hash] = true
+	}
+
+	for _, hash := range r.inclusion.Tracked(hashes) {
+		if !sent[hash] {
+			tracked = append(tracked, hash)
+		}
+	}
+
+	return tracked
+}
+
+// tracked returns the hashes of the sent transactions among the provided ones
+func (s *safeReceipts) tracked(hashes []common.Hash) []common.Hash {
+	s.Lock()
+	defer s.Unlock()
+
+	tracked := make([]common.Hash, 0)
+
+	for _, hash := range hashes {
+		if _, ok := s.txs[hash]; ok {
+			tracked = append(tracked, hash)
+		}
+	}
+
+	return tracked
+}
+
+// notIncluded returns the hashes of the sent transactions without a receipt
+func (s *safeReceipts) notIncluded() []common.Hash {
+	s.Lock()
+	defer s.Unlock()
+
+	hashes := make([]common.Hash, 0)
+
+	for hash, tx := range s.txs {
+		if tx.receipt == nil {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes
+}
+
+// pending returns the number of sent transactions without a receipt, that were not abandoned
+func (s *safeReceipts) pending() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return int(s.sent - s.included - (s.abandoned - s.abandonedIncluded))
+}
@@ -0,0 +1,309 @@
+package txreceipts
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ethAPI serves the blocks of a chain, their transaction receipts, and the newHeads subscription
+type ethAPI struct {
+	mux    sync.Mutex
+	head   uint64
+	blocks map[uint64][]common.Hash
+	heads  chan *types.Header
+	// receiptCalls counts the receipts fetched by transaction hash
+	receiptCalls atomic.Int64
+	// blockNumberCalls counts the polls of the latest block number
+	blockNumberCalls atomic.Int64
+}
+
+// ethReceiptsAPI is an ethAPI that also serves eth_getBlockReceipts
+type ethReceiptsAPI struct {
+	*ethAPI
+}
+
+func (a *ethAPI) BlockNumber() hexutil.Uint64 {
+	a.blockNumberCalls.Add(1)
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	return hexutil.Uint64(a.head)
+}
+
+func (a *ethAPI) GetBlockByNumber(number hexutil.Uint64, _ bool) map[string][]common.Hash {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	if uint64(number) > a.head {
+		return nil
+	}
+
+	return map[string][]common.Hash{"transactions": a.blocks[uint64(number)]}
+}
+
+func (a *ethAPI) GetTransactionReceipt(hash common.Hash) *types.Receipt {
+	a.receiptCalls.Add(1)
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	for number, hashes := range a.blocks {
+		for _, h := range hashes {
+			if h == hash && number <= a.head {
+				return receipt(hash, number)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *ethAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, ok := rpc.NotifierFromContext(ctx)
+	if !ok {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		for {
+			select {
+			case header := <-a.heads:
+				_ = notifier.Notify(sub.ID, header)
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+
+	return sub, nil
+}
+
+func (a *ethReceiptsAPI) GetBlockReceipts(number rpc.BlockNumberOrHash) []*types.Receipt {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	blockNumber, _ := number.Number()
+	if uint64(blockNumber) > a.head {
+		return nil
+	}
+
+	receipts := make([]*types.Receipt, 0)
+	for _, hash := range a.blocks[uint64(blockNumber)] {
+		receipts = append(receipts, receipt(hash, uint64(blockNumber)))
+	}
+
+	return receipts
+}
+
+// build adds a block with the transactions on top of the chain, and announces it
+func (a *ethAPI) build(hashes ...common.Hash) {
+	a.mux.Lock()
+	a.head++
+	a.blocks[a.head] = hashes
+	header := &types.Header{Number: new(big.Int).SetUint64(a.head), Difficulty: common.Big0}
+	a.mux.Unlock()
+
+	a.heads <- header
+}
+
+func TestTxReceipts_FetchReceiptsByBlocks(t *testing.T) {
+	var tests = []struct {
+		name          string
+		blockReceipts bool
+	}{
+		{
+			name:          "Block receipts",
+			blockReceipts: true,
+		},
+		{
+			name:          "Receipts of the sent transactions",
+			blockReceipts: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				api = &ethAPI{
+					head:   10,
+					blocks: map[uint64][]common.Hash{},
+					heads:  make(chan *types.Header),
+				}
+				srv         = rpc.NewServer()
+				sent        = []common.Hash{{1}, {2}, {3}}
+				notSent     = common.Hash{4}
+				service any = api
+			)
+
+			if tc.blockReceipts {
+				service = &ethReceiptsAPI{api}
+			}
+
+			require.NoError(t, srv.RegisterName("eth", service))
+			t.Cleanup(srv.Stop)
+
+			eth := ethclient.NewClient(rpc.DialInProc(srv))
+			t.Cleanup(eth.Close)
+
+			r := New(context.Background(), logger.NewZapLogger(), eth, conf.Conf{
+				Finality:              conf.DepthFinality,
+				WaitForConfirmTimeout: 1,
+			}, testProm)
+			require.NoError(t, r.Start())
+
+			for nonce, hash := range sent {
+				r.StoreTxHash(hash, common.Address{}, uint64(nonce), time.Now())
+			}
+
+			// the first block is built before the subscription, so it is only found by searching the past blocks
+			api.mux.Lock()
+			api.head = 11
+			api.blocks[11] = []common.Hash{sent[0]}
+			api.mux.Unlock()
+
+			go func() {
+				api.build(notSent)
+				api.build(sent[1], sent[2])
+			}()
+
+			r.ConfirmTransactions()
+
+			included, final := r.safeReceipts.counts()
+			assert.Equal(t, uint64(3), included)
+			assert.Equal(t, uint64(3), final)
+
+			if tc.blockReceipts {
+				assert.Zero(t, api.receiptCalls.Load())
+			} else {
+				// only the receipts of the sent transactions are fetched
+				assert.Equal(t, int64(len(sent)), api.receiptCalls.Load())
+			}
+		})
+	}
+}
+
+func TestTxReceipts_ConfirmByHeads(t *testing.T) {
+	var (
+		api = &ethAPI{
+			head:   10,
+			blocks: map[uint64][]common.Hash{},
+			heads:  make(chan *types.Header),
+		}
+		srv  = rpc.NewServer()
+		sent = []common.Hash{{1}, {2}, {3}}
+	)
+
+	require.NoError(t, srv.RegisterName("eth", &ethReceiptsAPI{api}))
+	t.Cleanup(srv.Stop)
+
+	eth := ethclient.NewClient(rpc.DialInProc(srv))
+	t.Cleanup(eth.Close)
+
+	r := New(context.Background(), logger.NewZapLogger(), eth, conf.Conf{
+		Finality:     conf.DepthFinality,
+		ConfirmDepth: 1,
+	}, testProm)
+	require.NoError(t, r.Start())
+
+	for nonce, hash := range sent {
+		r.StoreTxHash(hash, common.Address{}, uint64(nonce), time.Now())
+	}
+
+	api.build(sent[0])
+	api.build(sent[1], sent[2])
+	api.build()
+
+	// the transactions of the second block are final once the third one is built on top of it
+	assert.Eventually(t, func() bool {
+		r.safeReceipts.Lock()
+		defer r.safeReceipts.Unlock()
+
+		return len(r.safeReceipts.txs) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	r.Stop()
+
+	// the latest block number is only fetched by Start, the new blocks come from the subscription
+	assert.Equal(t, int64(1), api.blockNumberCalls.Load())
+
+	_, total := r.summarize(nil)
+	assert.Equal(t, int64(3), total.Statuses[Succeeded])
+}
+
+// inclusionMock tracks the provided transactions, and records the included ones
+type inclusionMock struct {
+	tracked  []common.Hash
+	included []common.Hash
+}
+
+func (i *inclusionMock) Tracked(hashes []common.Hash) []common.Hash {
+	tracked := make([]common.Hash, 0)
+
+	for _, hash := range hashes {
+		for _, h := range i.tracked {
+			if hash == h {
+				tracked = append(tracked, hash)
+			}
+		}
+	}
+
+	return tracked
+}
+
+func (i *inclusionMock) Include(_ time.Time, hashes []common.Hash) {
+	i.included = append(i.included, hashes...)
+}
+
+func TestTxReceipts_SearchBlocksFeedsInclusion(t *testing.T) {
+	var (
+		api = &ethAPI{
+			head:   11,
+			blocks: map[uint64][]common.Hash{},
+		}
+		srv = rpc.NewServer()
+		// a transaction still being sent is only tracked by the inclusion
+		stored   = common.Hash{1}
+		sending  = common.Hash{2}
+		notSent  = common.Hash{3}
+		observer = &inclusionMock{tracked: []common.Hash{stored, sending}}
+	)
+
+	api.blocks[11] = []common.Hash{stored, sending, notSent}
+
+	require.NoError(t, srv.RegisterName("eth", api))
+	t.Cleanup(srv.Stop)
+
+	eth := ethclient.NewClient(rpc.DialInProc(srv))
+	t.Cleanup(eth.Close)
+
+	r := New(context.Background(), logger.NewZapLogger(), eth, conf.Conf{}, testProm)
+	r.OnInclusion(observer)
+	r.StoreTxHash(stored, common.Address{}, 0, time.Now())
+
+	r.next = 11
+	r.searchBlocks(context.Background(), 11)
+
+	// the receipts of the transactions tracked by either are fetched once
+	assert.Equal(t, []common.Hash{stored, sending}, observer.included)
+	assert.Equal(t, int64(2), api.receiptCalls.Load())
+
+	included, _ := r.safeReceipts.counts()
+	assert.Equal(t, uint64(1), included)
+}
@@ -0,0 +1,295 @@
+package txreceipts
+
+import (
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// OnStuckNonce sets the function called with the account and the nonce of every detected stuck nonce,
+// it must be set before Start
+func (r *TxReceipts) OnStuckNonce(fn func(from common.Address, nonce uint64)) {
+	r.onStuckNonce = fn
+}
+
+// InclusionObserver is fed the blocks searched for the sent transactions, so that it does not fetch them again
+type InclusionObserver interface {
+	// Tracked returns the transactions the observer tracks among the transactions of a block
+	Tracked(hashes []common.Hash) []common.Hash
+	// Include is called with the transactions of a searched block, and the time the block was observed
+	Include(observedAt time.Time, hashes []common.Hash)
+}
+
+// OnInclusion sets the observer fed every searched block, it must be set before Start
+func (r *TxReceipts) OnInclusion(observer InclusionObserver) {
+	r.inclusion = observer
+}
+
+// Start records the latest block, so that the blocks built after it can be searched for the sent transactions,
+// and starts confirming the sent transactions in the background. It must be called before the first transaction is sent
+func (r *TxReceipts) Start() error {
+	head, err := r.eth.BlockNumber(r.ctx)
+	if err != nil {
+		return err
+	}
+
+	r.next = head + 1
+	r.started = true
+
+	go r.confirm()
+
+	return nil
+}
+
+// confirm searches the new blocks for the sent transactions while they are being sent,
+// so that the final transactions are evicted instead of piling up until the send stops.
+// The new blocks are followed through a newHeads subscription, or polled if the endpoint does not support it
+func (r *TxReceipts) confirm() {
+	defer close(r.done)
+
+	if !r.confirmByHeads() {
+		r.confirmByPolling()
+	}
+}
+
+// confirmByHeads confirms the transactions on every new block header, until the confirmation is stopped.
+// It returns false if the blocks must be polled instead: if the endpoint does not support subscriptions,
+// or if the subscription is lost
+func (r *TxReceipts) confirmByHeads() bool {
+	heads := make(chan *types.Header, headsBuffer)
+
+	sub, err := r.eth.SubscribeNewHead(r.ctx, heads)
+	if err != nil {
+		r.log.Debug("Could not subscribe to new blocks, polling them", "err", err.Error())
+		return false
+	}
+
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-r.stop:
+			return true
+		case <-r.ctx.Done():
+			return true
+		case err = <-sub.Err():
+			r.log.Warn("New blocks subscription lost, polling the new blocks", "err", err)
+			return false
+		case header := <-heads:
+			head := header.Number.Uint64()
+
+			// a reorg replaces blocks that could already be searched
+			if head < r.next {
+				r.next = head
+			}
+
+			r.searchBlocks(r.ctx, head)
+
+			// the depth finality follows the new head, the tagged blocks are fetched
+			if r.conf.Finality == conf.DepthFinality {
+				r.finalize(r.ctx, depthHead(head, r.conf.ConfirmDepth))
+			} else {
+				r.finalizeToFinalHead()
+			}
+
+			r.settle(time.Duration(r.conf.WaitForConfirmTimeout) * time.Minute)
+			r.detectStuckNonces()
+		}
+	}
+}
+
+// confirmByPolling confirms the transactions on the blocks polled every finality poll interval,
+// until the confirmation is stopped
+func (r *TxReceipts) confirmByPolling() {
+	tick := time.NewTicker(finalityPollInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-r.ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		if head, err := r.eth.BlockNumber(r.ctx); err != nil {
+			r.log.Debug("Could not fetch latest block number", "err", err.Error())
+		} else {
+			r.searchBlocks(r.ctx, head)
+		}
+
+		r.finalizeToFinalHead()
+		r.settle(time.Duration(r.conf.WaitForConfirmTimeout) * time.Minute)
+		r.detectStuckNonces()
+	}
+}
+
+// finalizeToFinalHead marks final the included transactions with blocks at or below the final block
+func (r *TxReceipts) finalizeToFinalHead() {
+	if head, err := r.finalHead(r.ctx); err != nil {
+		r.log.Debug("Could not fetch final block", "finality", r.finalityName(), "err", err.Error())
+	} else {
+		r.finalize(r.ctx, head)
+	}
+}
+
+// Stop stops the background confirmation, if it was started. It can be called more than once,
+// ConfirmTransactions also stops it
+func (r *TxReceipts) Stop() {
+	if !r.started {
+		return
+	}
+
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+
+	<-r.done
+}
+
+// settle evicts the final transactions, the superseded ones and, if the timeout is set, the ones not final
+// within the timeout, and updates the confirmation metrics
+func (r *TxReceipts) settle(timeout time.Duration) {
+	var dropped map[common.Hash]bool
+	if timeout != 0 {
+		dropped = r.droppedTxs(r.ctx, r.safeReceipts.expired(timeout))
+	}
+
+	succeeded, reverted := r.safeReceipts.settle(timeout, dropped)
+
+	r.prom.AddTxConfirmedCount(float64(succeeded))
+	r.prom.AddTxRevertedCount(float64(reverted))
+	r.prom.SetTxPendingConfirmation(float64(r.safeReceipts.unconfirmed()))
+}
+
+// detectStuckNonces reports the accounts whose oldest transaction waits for inclusion for longer than the stuck timeout
+func (r *TxReceipts) detectStuckNonces() {
+	if r.conf.StuckTimeoutSec == 0 {
+		return
+	}
+
+	for from, nonce := range r.safeReceipts.stuckNonces(time.Duration(r.conf.StuckTimeoutSec) * time.Second) {
+		r.log.Warn("Stuck nonce detected",
+			"from", from.Hex(),
+			"nonce", nonce,
+			"timeout_sec", r.conf.StuckTimeoutSec,
+		)
+
+		r.prom.IncreaseTxStuckNonceCount()
+
+		if r.onStuckNonce != nil {
+			r.onStuckNonce(from, nonce)
+		}
+	}
+}
+
+// settle removes the final transactions, the transactions superseded by an included one with the same nonce
+// and, if the timeout is set, the transactions not final within the timeout, adding their outcome to the summary
+// of their account. The timed out transactions are dropped if the node does not know them anymore.
+// It returns the number of succeeded and reverted transactions
+func (s *safeReceipts) settle(timeout time.Duration, dropped map[common.Hash]bool) (succeeded, reverted int) {
+	s.Lock()
+	defer s.Unlock()
+
+	for hash, tx := range s.txs {
+		var status Status
+
+		switch {
+		case tx.final:
+			status = tx.status(false)
+		case tx.receipt == nil && tx.nonce < s.nonces[tx.from]:
+			status = Dropped
+		case timeout != 0 && time.Since(tx.sentAt) > timeout:
+			status = tx.status(dropped[hash])
+		default:
+			continue
+		}
+
+		summary, ok := s.settled[tx.from]
+		if !ok {
+			summary = newAccountSummary(tx.from)
+			s.settled[tx.from] = summary
+		}
+
+		switch status {
+		case Succeeded:
+			succeeded++
+		case Reverted:
+			reverted++
+		default:
+			s.abandoned++
+
+			if tx.receipt != nil {
+				s.abandonedIncluded++
+			}
+		}
+
+		summary.add(status, tx.receipt)
+		delete(s.txs, hash)
+	}
+
+	return succeeded, reverted
+}
+
+// expired returns the transactions without a receipt, and not superseded, sent before the timeout
+func (s *safeReceipts) expired(timeout time.Duration) []common.Hash {
+	s.Lock()
+	defer s.Unlock()
+
+	hashes := make([]common.Hash, 0)
+
+	for hash, tx := range s.txs {
+		if tx.receipt == nil && tx.nonce >= s.nonces[tx.from] && time.Since(tx.sentAt) > timeout {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return hashes
+}
+
+// unconfirmed returns the number of sent transactions that are not final yet, and were not abandoned
+func (s *safeReceipts) unconfirmed() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.sent - s.final - s.abandoned
+}
+
+// stuckNonces returns the nonce of the oldest not included transaction of every account, if it was sent before
+// the timeout. A nonce is returned only once, and the transactions replaced by an included one are skipped
+func (s *safeReceipts) stuckNonces(timeout time.Duration) map[common.Address]uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	oldest := make(map[common.Address]*trackedTx)
+
+	for _, tx := range s.txs {
+		if tx.receipt != nil || tx.nonce < s.nonces[tx.from] {
+			continue
+		}
+
+		if current, ok := oldest[tx.from]; !ok || tx.nonce < current.nonce {
+			oldest[tx.from] = tx
+		}
+	}
+
+	stuck := make(map[common.Address]uint64)
+
+	for from, tx := range oldest {
+		if time.Since(tx.sentAt) < timeout {
+			continue
+		}
+
+		if reported, ok := s.stuck[from]; ok && reported == tx.nonce {
+			continue
+		}
+
+		s.stuck[from] = tx.nonce
+		stuck[from] = tx.nonce
+	}
+
+	return stuck
+}
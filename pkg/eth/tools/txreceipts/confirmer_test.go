@@ -0,0 +1,157 @@
+package txreceipts
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxReceipts_Settle(t *testing.T) {
+	var (
+		account   = common.HexToAddress("0x0a")
+		succeeded = common.Hash{1}
+		reverted  = common.Hash{2}
+		included  = common.Hash{3}
+		r         = newTestReceipts(t, &chain{}, conf.Conf{})
+	)
+
+	r.StoreTxHash(succeeded, account, 0, time.Now())
+	r.StoreTxHash(reverted, account, 1, time.Now())
+	r.StoreTxHash(included, account, 2, time.Now())
+
+	failed := receipt(reverted, 1)
+	failed.Status = types.ReceiptStatusFailed
+
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(succeeded, 1)))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(failed))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(included, 2)))
+
+	r.markFinal(succeeded, nil)
+	r.markFinal(reverted, nil)
+
+	succeededCount, revertedCount := r.safeReceipts.settle(0, nil)
+	assert.Equal(t, 1, succeededCount)
+	assert.Equal(t, 1, revertedCount)
+	assert.Equal(t, uint64(1), r.safeReceipts.unconfirmed())
+
+	// only the transaction that is not final yet is kept
+	assert.Len(t, r.safeReceipts.txs, 1)
+	assert.Contains(t, r.safeReceipts.txs, included)
+
+	// the outcome of the evicted transactions is still reported
+	summaries, total := r.summarize(nil)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, int64(3), total.Sent)
+	assert.Equal(t, int64(1), total.Statuses[Succeeded])
+	assert.Equal(t, int64(1), total.Statuses[Reverted])
+	assert.Equal(t, int64(1), total.Statuses[TimedOut])
+}
+
+func TestTxReceipts_SettleAbandoned(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	knownTx, err := types.SignNewTx(key, types.NewEIP155Signer(big.NewInt(1)), &types.LegacyTx{
+		GasPrice: big.NewInt(1),
+		To:       &common.Address{},
+		Value:    big.NewInt(1),
+	})
+	require.NoError(t, err)
+
+	var (
+		account    = common.HexToAddress("0x0a")
+		oldAccount = common.HexToAddress("0x0b")
+		slowChain  = common.HexToAddress("0x0c")
+		old        = time.Now().Add(-time.Hour)
+		replaced   = common.Hash{1}
+		included   = common.Hash{2}
+		dropped    = common.Hash{3}
+		timedOut   = knownTx.Hash()
+		notFinal   = common.Hash{4}
+		recent     = common.Hash{5}
+		r          = newTestReceipts(t, &chain{pending: map[common.Hash]*types.Transaction{timedOut: knownTx}}, conf.Conf{})
+		expiration = time.Minute
+	)
+
+	// the replaced transaction will never be included, as its nonce was used by another one
+	r.StoreTxHash(replaced, account, 0, time.Now())
+	r.StoreTxHash(included, account, 0, time.Now())
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(included, 1)))
+
+	// the old transactions are not final within the timeout, the node does not know one of them anymore
+	r.StoreTxHash(dropped, oldAccount, 0, old)
+	r.StoreTxHash(timedOut, oldAccount, 1, old)
+	r.StoreTxHash(notFinal, slowChain, 0, old)
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(notFinal, 2)))
+
+	r.StoreTxHash(recent, account, 1, time.Now())
+
+	r.settle(expiration)
+
+	// only the included transaction waiting for finality and the recent one are kept
+	assert.Len(t, r.safeReceipts.txs, 2)
+	assert.Contains(t, r.safeReceipts.txs, included)
+	assert.Contains(t, r.safeReceipts.txs, recent)
+
+	// the abandoned transactions are neither awaited for inclusion nor for finality
+	assert.Equal(t, 1, r.safeReceipts.pending())
+	assert.Equal(t, uint64(1), r.safeReceipts.awaitingFinality())
+	assert.Equal(t, uint64(2), r.safeReceipts.unconfirmed())
+
+	_, total := r.summarize(nil)
+	assert.Equal(t, int64(6), total.Sent)
+	assert.Equal(t, int64(2), total.Statuses[Dropped])
+	// the timed out transactions include the one included, but not final
+	assert.Equal(t, int64(4), total.Statuses[TimedOut])
+}
+
+func TestTxReceipts_StuckNonces(t *testing.T) {
+	var (
+		stuckAccount    = common.HexToAddress("0x0a")
+		movingAccount   = common.HexToAddress("0x0b")
+		replacedAccount = common.HexToAddress("0x0c")
+		old             = time.Now().Add(-time.Minute)
+		r               = newTestReceipts(t, &chain{}, conf.Conf{})
+	)
+
+	// the oldest transaction of the account was dropped, and the following ones wait for it
+	r.StoreTxHash(common.Hash{1}, stuckAccount, 5, old)
+	r.StoreTxHash(common.Hash{2}, stuckAccount, 6, old)
+	r.StoreTxHash(common.Hash{3}, stuckAccount, 7, old)
+
+	// the account is still within the timeout
+	r.StoreTxHash(common.Hash{4}, movingAccount, 1, time.Now())
+
+	// the dropped transaction was replaced by one with the same nonce, which was included
+	r.StoreTxHash(common.Hash{5}, replacedAccount, 3, old)
+	r.StoreTxHash(common.Hash{6}, replacedAccount, 3, time.Now())
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(common.Hash{6}, 1)))
+
+	stuck := r.safeReceipts.stuckNonces(30 * time.Second)
+	assert.Equal(t, map[common.Address]uint64{stuckAccount: 5}, stuck)
+
+	// a stuck nonce is reported once
+	assert.Empty(t, r.safeReceipts.stuckNonces(30*time.Second))
+
+	var reported []uint64
+
+	r.conf.StuckTimeoutSec = 30
+	r.OnStuckNonce(func(from common.Address, nonce uint64) {
+		assert.Equal(t, stuckAccount, from)
+		reported = append(reported, nonce)
+	})
+
+	// once the gap is filled, the account can get stuck on a later nonce
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(common.Hash{1}, 2)))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(common.Hash{2}, 2)))
+
+	r.detectStuckNonces()
+	assert.Equal(t, []uint64{7}, reported)
+}
@@ -0,0 +1,200 @@
+package txreceipts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/olekukonko/tablewriter"
+	"golang.org/x/sync/errgroup"
+)
+
+// dropCheckLimit is the number of transactions checked concurrently for being dropped
+const dropCheckLimit = 50
+
+// Status is the outcome of a sent transaction
+type Status string
+
+const (
+	// Succeeded transactions are final and their execution succeeded
+	Succeeded Status = "succeeded"
+	// Reverted transactions are final, but their execution reverted
+	Reverted Status = "reverted"
+	// Dropped transactions were not included, and the node does not know them anymore
+	Dropped Status = "dropped"
+	// TimedOut transactions were not final before the confirmation timeout
+	TimedOut Status = "timed out"
+)
+
+// statuses are all the transaction statuses, in the order of the breakdown table
+var statuses = []Status{Succeeded, Reverted, Dropped, TimedOut}
+
+// AccountSummary holds the outcome of the transactions sent from an account, and the gas and fees they paid
+type AccountSummary struct {
+	Account  common.Address
+	Sent     int64
+	Statuses map[Status]int64
+	GasUsed  uint64
+	// Fees is the sum of the gas used multiplied by the effective gas price, in wei
+	Fees *big.Int
+}
+
+func newAccountSummary(account common.Address) *AccountSummary {
+	return &AccountSummary{
+		Account:  account,
+		Statuses: make(map[Status]int64, len(statuses)),
+		Fees:     big.NewInt(0),
+	}
+}
+
+func (a *AccountSummary) add(status Status, receipt *types.Receipt) {
+	a.Sent++
+	a.Statuses[status]++
+
+	if receipt == nil {
+		return
+	}
+
+	a.GasUsed += receipt.GasUsed
+
+	if receipt.EffectiveGasPrice != nil {
+		fee := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice)
+		a.Fees.Add(a.Fees, fee)
+	}
+}
+
+func (a *AccountSummary) merge(other *AccountSummary) {
+	a.Sent += other.Sent
+	a.GasUsed += other.GasUsed
+	a.Fees.Add(a.Fees, other.Fees)
+
+	for status, count := range other.Statuses {
+		a.Statuses[status] += count
+	}
+}
+
+// status classifies the transaction, dropped tells whether the node does not know the transaction anymore
+func (t *trackedTx) status(dropped bool) Status {
+	switch {
+	case t.final && t.receipt.Status == types.ReceiptStatusSuccessful:
+		return Succeeded
+	case t.final:
+		return Reverted
+	case t.receipt == nil && dropped:
+		return Dropped
+	default:
+		return TimedOut
+	}
+}
+
+// droppedTxs returns the transactions among the missing ones, without a receipt, that the node does not know anymore
+func (r *TxReceipts) droppedTxs(ctx context.Context, missing []common.Hash) map[common.Hash]bool {
+	var (
+		dropped = make(map[common.Hash]bool)
+		mux     sync.Mutex
+	)
+
+	errGr := errgroup.Group{}
+	errGr.SetLimit(dropCheckLimit)
+
+	for _, hash := range missing {
+		hash := hash
+
+		errGr.Go(func() error {
+			_, _, err := r.eth.TransactionByHash(ctx, hash)
+			if errors.Is(err, ethereum.NotFound) {
+				mux.Lock()
+				dropped[hash] = true
+				mux.Unlock()
+			} else if err != nil {
+				r.log.Debug("Could not check if transaction was dropped", "hash", hash, "err", err.Error())
+			}
+
+			return nil
+		})
+	}
+
+	_ = errGr.Wait()
+
+	return dropped
+}
+
+// summarize classifies the transactions and returns the summary of every account, ordered by account,
+// and the summary of all of them
+func (r *TxReceipts) summarize(dropped map[common.Hash]bool) ([]*AccountSummary, *AccountSummary) {
+	r.safeReceipts.Lock()
+	defer r.safeReceipts.Unlock()
+
+	accounts := make(map[common.Address]*AccountSummary)
+
+	// the final transactions evicted while sending only left their outcome
+	for account, settled := range r.safeReceipts.settled {
+		summary := newAccountSummary(account)
+		summary.merge(settled)
+		accounts[account] = summary
+	}
+
+	for hash, tx := range r.safeReceipts.txs {
+		summary, ok := accounts[tx.from]
+		if !ok {
+			summary = newAccountSummary(tx.from)
+			accounts[tx.from] = summary
+		}
+
+		summary.add(tx.status(dropped[hash]), tx.receipt)
+	}
+
+	var (
+		summaries = make([]*AccountSummary, 0, len(accounts))
+		total     = newAccountSummary(common.Address{})
+	)
+
+	for _, summary := range accounts {
+		summaries = append(summaries, summary)
+		total.merge(summary)
+	}
+
+	slices.SortFunc(summaries, func(a, b *AccountSummary) int {
+		return bytes.Compare(a.Account.Bytes(), b.Account.Bytes())
+	})
+
+	return summaries, total
+}
+
+// outputBreakdown prints the outcome, the gas and the fees of the transactions of every account
+func (r *TxReceipts) outputBreakdown(summaries []*AccountSummary, total *AccountSummary) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ACCOUNT", "SENT", "SUCCEEDED", "REVERTED", "DROPPED", "TIMED_OUT", "GAS_USED", "FEES (ETH)"})
+
+	row := func(name string, summary *AccountSummary) []string {
+		cells := []string{name, fmt.Sprintf("%d", summary.Sent)}
+
+		for _, status := range statuses {
+			cells = append(cells, fmt.Sprintf("%d", summary.Statuses[status]))
+		}
+
+		return append(cells, fmt.Sprintf("%d", summary.GasUsed), weiToEth(summary.Fees))
+	}
+
+	for _, summary := range summaries {
+		table.Append(row(summary.Account.Hex(), summary))
+	}
+
+	table.SetFooter(row("TOTAL", total))
+	table.Render()
+}
+
+func weiToEth(wei *big.Int) string {
+	eth := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether))
+
+	return eth.Text('f', 18)
+}
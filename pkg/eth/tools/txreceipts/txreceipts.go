@@ -2,82 +2,207 @@ package txreceipts
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"os"
 	"runtime"
 	"sync"
 	"time"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockreceipts"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/latency"
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/prom"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// finalityPollInterval is the time between two checks of the final block
+	finalityPollInterval = time.Second
+	// finalityVerifyLimit is the number of receipts fetched concurrently to verify that a transaction was not reorged
+	finalityVerifyLimit = 50
+)
+
+// FinalityBuckets are the upper bounds of the time to finality histogram
+var FinalityBuckets = []time.Duration{
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+}
+
 type TxReceipts struct {
 	ctx  context.Context
 	eth  *ethclient.Client
 	log  logger.Logger
 	conf conf.Conf
+	prom *prom.Prom
 
 	wg      *sync.WaitGroup
 	limiter chan struct{}
 
 	safeReceipts safeReceipts
+	finality     *latency.Distribution
+
+	// started is set once the first block to search for the sent transactions is known
+	started bool
+	// next is the number of the next block to search for the sent transactions
+	next uint64
+	// blocks fetches the receipts of the searched blocks
+	blocks *blockreceipts.Fetcher
+
+	// stop and done control the background confirmation while sending
+	stop         chan struct{}
+	stopOnce     sync.Once
+	done         chan struct{}
+	onStuckNonce func(from common.Address, nonce uint64)
+	inclusion    InclusionObserver
+}
+
+// Report summarizes the outcome of all the sent transactions and their time to finality
+type Report struct {
+	Total    *AccountSummary
+	Finality latency.Summary
 }
 
 type safeReceipts struct {
 	sync.Mutex
-	receipts  map[common.Hash]*types.Receipt
-	confirmed uint64
+	// txs are the sent transactions that are not final yet, the final ones are only kept in the settled summaries
+	txs     map[common.Hash]*trackedTx
+	settled map[common.Address]*AccountSummary
+	// nonces are the nonces following the highest included nonce of every account
+	nonces map[common.Address]uint64
+	// abandoned is the number of transactions evicted before they were final, as superseded or timed out,
+	// and abandonedIncluded is the number of them that were included
+	abandoned         uint64
+	abandonedIncluded uint64
+	// stuck are the last reported stuck nonces of every account
+	stuck    map[common.Address]uint64
+	sent     uint64
+	included uint64
+	final    uint64
 }
 
-func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, cfg conf.Conf) *TxReceipts {
+// trackedTx is a sent transaction, with its receipt once it is included
+type trackedTx struct {
+	from    common.Address
+	nonce   uint64
+	sentAt  time.Time
+	receipt *types.Receipt
+	final   bool
+}
+
+func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, cfg conf.Conf, prom *prom.Prom) *TxReceipts {
+	log = log.Named("txreceipts")
+
 	return &TxReceipts{
 		ctx:     ctx,
 		eth:     eth,
-		log:     log.Named("txreceipts"),
+		log:     log,
 		conf:    cfg,
+		prom:    prom,
 		wg:      &sync.WaitGroup{},
 		limiter: make(chan struct{}, runtime.NumCPU()*100),
 		safeReceipts: safeReceipts{
-			receipts: make(map[common.Hash]*types.Receipt, 0),
+			txs:     make(map[common.Hash]*trackedTx, 0),
+			settled: make(map[common.Address]*AccountSummary),
+			nonces:  make(map[common.Address]uint64),
+			stuck:   make(map[common.Address]uint64),
 		},
+		finality: latency.NewDistribution(FinalityBuckets),
+		// the receipts of the sent transactions in a block are fetched one request at a time
+		blocks: blockreceipts.New(log, eth.Client(), 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
 	}
 }
 
-func (r *TxReceipts) StoreTxHash(hash common.Hash) {
-	r.safeReceipts.storeTxHash(hash)
+// StoreTxHash stores the hash of a sent transaction, with its sender, its nonce and the time it was sent
+func (r *TxReceipts) StoreTxHash(hash common.Hash, from common.Address, nonce uint64, sentAt time.Time) {
+	r.safeReceipts.storeTxHash(hash, from, nonce, sentAt)
 }
 
-func (r *TxReceipts) ConfirmTransactions() {
+// ConfirmTransactions waits for the receipts of the stored transactions and for their blocks to become final,
+// and reports the outcome of the transactions, the gas and fees paid by every account and the time to finality
+func (r *TxReceipts) ConfirmTransactions() Report {
 	var (
-		txHashes = make([]common.Hash, 0)
+		fetched      = make(chan struct{})
+		finalityDone = make(chan struct{})
 	)
 
+	r.Stop()
+
 	ctx, cancel := context.WithTimeout(r.ctx, time.Minute*time.Duration(r.conf.WaitForConfirmTimeout))
 	defer cancel()
 
-	// extract tx hashes to prevent data race
-	for hash := range r.safeReceipts.receipts {
-		txHashes = append(txHashes, hash)
-	}
+	go r.watchFinality(ctx, fetched, finalityDone)
 
-	for _, hash := range txHashes {
-		r.limiter <- struct{}{}
-		r.wg.Add(1)
+	// over a subscription, every block is fetched once, instead of polling the receipt of every transaction
+	if !r.fetchReceiptsByBlocks(ctx) {
+		for _, hash := range r.safeReceipts.notIncluded() {
+			r.limiter <- struct{}{}
+			r.wg.Add(1)
 
-		go r.tryFetchReceiptsWithDeadline(ctx, hash)
+			go r.tryFetchReceiptsWithDeadline(ctx, hash)
+		}
+
+		r.wg.Wait()
 	}
 
-	r.wg.Wait()
+	close(fetched)
+	<-finalityDone
+
+	// the transactions left are classified by summarize, after they are checked for being dropped
+	r.settle(0)
+
+	var (
+		sent            = r.safeReceipts.sentCount()
+		included, final = r.safeReceipts.counts()
+		// the confirmation context could be done, so the dropped transactions are checked with the parent one
+		summaries, total = r.summarize(r.droppedTxs(r.ctx, r.safeReceipts.notIncluded()))
+	)
 
-	if r.safeReceipts.confirmed == uint64(len(txHashes)) {
-		r.log.Info("All transactions successfully confirmed", "sent_tx", len(txHashes), "receipts", r.safeReceipts.confirmed)
+	if total.Statuses[Succeeded] == int64(sent) {
+		r.log.Info("All transactions successfully confirmed",
+			"sent_tx", sent,
+			"receipts", included,
+			"final", final,
+			"finality", r.finalityName(),
+		)
 	} else {
-		r.log.Error("Transactions not confirmed", "sent_tx", len(txHashes), "receipts", r.safeReceipts.confirmed)
+		r.log.Error("Transactions not confirmed",
+			"sent_tx", sent,
+			"receipts", included,
+			"final", final,
+			"finality", r.finalityName(),
+			"succeeded", total.Statuses[Succeeded],
+			"reverted", total.Statuses[Reverted],
+			"dropped", total.Statuses[Dropped],
+			"timed_out", total.Statuses[TimedOut],
+		)
 	}
 
+	r.outputBreakdown(summaries, total)
+	r.outputFinality()
+
+	return Report{
+		Total:    total,
+		Finality: r.finality.Summary(),
+	}
 }
 
 func (r *TxReceipts) tryFetchReceiptsWithDeadline(ctx context.Context, hash common.Hash) {
@@ -114,24 +239,261 @@ func (r *TxReceipts) tryFetchReceiptsWithDeadline(ctx context.Context, hash comm
 	}
 }
 
-func (s *safeReceipts) storeTxHash(hash common.Hash) {
+// watchFinality marks the included transactions final as the final block advances,
+// until all the included transactions are final, after the receipts are fetched
+func (r *TxReceipts) watchFinality(ctx context.Context, fetched <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	tick := time.NewTicker(finalityPollInterval)
+	defer tick.Stop()
+
+	for {
+		// the receipts are fetched before the final check, so no transaction is included after it
+		var receiptsFetched bool
+
+		select {
+		case <-fetched:
+			receiptsFetched = true
+		default:
+		}
+
+		head, err := r.finalHead(ctx)
+		if err != nil {
+			r.log.Debug("Could not fetch final block", "finality", r.finalityName(), "err", err.Error())
+		} else {
+			r.finalize(ctx, head)
+		}
+
+		if receiptsFetched && r.safeReceipts.awaitingFinality() == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// finalHead returns the number of the highest block whose transactions are final
+func (r *TxReceipts) finalHead(ctx context.Context) (uint64, error) {
+	switch r.conf.Finality {
+	case conf.SafeFinality:
+		return r.taggedBlock(ctx, rpc.SafeBlockNumber)
+	case conf.FinalizedFinality:
+		return r.taggedBlock(ctx, rpc.FinalizedBlockNumber)
+	default:
+		latest, err := r.eth.BlockNumber(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		return depthHead(latest, r.conf.ConfirmDepth), nil
+	}
+}
+
+// depthHead returns the number of the highest block with the confirmation depth built on top of it
+func depthHead(latest uint64, confirmDepth int64) uint64 {
+	depth := uint64(confirmDepth)
+	if latest < depth {
+		return 0
+	}
+
+	return latest - depth
+}
+
+func (r *TxReceipts) taggedBlock(ctx context.Context, tag rpc.BlockNumber) (uint64, error) {
+	header, err := r.eth.HeaderByNumber(ctx, big.NewInt(tag.Int64()))
+	if err != nil {
+		return 0, fmt.Errorf("could not fetch %s block: %w", tag.String(), err)
+	}
+
+	return header.Number.Uint64(), nil
+}
+
+// finalize marks final the included transactions with blocks at or below the final block.
+// Their receipts are fetched again, so that a transaction moved to another block by a reorg is not marked final
+func (r *TxReceipts) finalize(ctx context.Context, head uint64) {
+	var (
+		candidates = r.safeReceipts.finalityCandidates(head)
+		// transactions are final as soon as they are included, so there is nothing a reorg could change
+		verify = r.conf.Finality != conf.DepthFinality || r.conf.ConfirmDepth != 0
+	)
+
+	errGr := errgroup.Group{}
+	errGr.SetLimit(finalityVerifyLimit)
+
+	for _, hash := range candidates {
+		hash := hash
+
+		if !verify {
+			r.markFinal(hash, nil)
+			continue
+		}
+
+		errGr.Go(func() error {
+			receipt, err := r.eth.TransactionReceipt(ctx, hash)
+			if errors.Is(err, ethereum.NotFound) {
+				r.log.Debug("Transaction removed from its block, waiting for it to be included again", "hash", hash)
+				return nil
+			}
+
+			if err != nil {
+				r.log.Debug("Could not verify transaction receipt", "hash", hash, "err", err.Error())
+				return nil
+			}
+
+			if receipt.BlockNumber.Uint64() > head {
+				r.log.Debug("Transaction moved to another block", "hash", hash, "block", receipt.BlockNumber)
+
+				if err = r.safeReceipts.storeTxReceipt(receipt); err != nil {
+					r.log.Error("Could not store receipt", "err", err.Error())
+				}
+
+				return nil
+			}
+
+			r.markFinal(hash, receipt)
+
+			return nil
+		})
+	}
+
+	_ = errGr.Wait()
+}
+
+// markFinal marks the transaction final, and records its time to finality
+func (r *TxReceipts) markFinal(hash common.Hash, receipt *types.Receipt) {
+	sentAt, ok := r.safeReceipts.markFinal(hash, receipt)
+	if !ok {
+		return
+	}
+
+	if !sentAt.IsZero() {
+		r.finality.Record(time.Since(sentAt))
+	}
+
+	r.log.Debug("Transaction final", "hash", hash, "finality", r.finalityName())
+}
+
+func (r *TxReceipts) outputFinality() {
+	summary := r.finality.Summary()
+
+	r.log.Info("Time to finality report",
+		"finality", r.finalityName(),
+		"final", summary.Count,
+		"mean", summary.Mean.String(),
+		"p50", summary.P50.String(),
+		"p90", summary.P90.String(),
+		"p99", summary.P99.String(),
+		"max", summary.Max.String(),
+	)
+
+	summary.Render(os.Stdout, "TIME_TO_FINALITY")
+}
+
+// finalityName describes when the transactions are final
+func (r *TxReceipts) finalityName() string {
+	if r.conf.Finality == conf.SafeFinality || r.conf.Finality == conf.FinalizedFinality {
+		return r.conf.Finality.String()
+	}
+
+	return fmt.Sprintf("%d blocks", r.conf.ConfirmDepth)
+}
+
+func (s *safeReceipts) storeTxHash(hash common.Hash, from common.Address, nonce uint64, sentAt time.Time) {
 	s.Lock()
 	defer s.Unlock()
 
-	s.receipts[hash] = nil
+	s.txs[hash] = &trackedTx{from: from, nonce: nonce, sentAt: sentAt}
+	s.sent++
 }
 
 func (s *safeReceipts) storeTxReceipt(receipt *types.Receipt) error {
 	s.Lock()
 	defer s.Unlock()
 
-	_, ok := s.receipts[receipt.TxHash]
-	if !ok {
+	if !s.store(receipt) {
 		return fmt.Errorf("tx hash for the receipt not found: %s", receipt.TxHash)
 	}
 
-	s.receipts[receipt.TxHash] = receipt
-	s.confirmed++
-
 	return nil
 }
+
+// store stores the receipt of a sent transaction, and returns false if the transaction was not sent.
+// The caller must hold the lock
+func (s *safeReceipts) store(receipt *types.Receipt) bool {
+	tx, ok := s.txs[receipt.TxHash]
+	if !ok {
+		return false
+	}
+
+	if tx.receipt == nil {
+		s.included++
+	}
+
+	tx.receipt = receipt
+	s.nonces[tx.from] = max(s.nonces[tx.from], tx.nonce+1)
+
+	return true
+}
+
+// finalityCandidates returns the included transactions, not final yet, with blocks at or below the head
+func (s *safeReceipts) finalityCandidates(head uint64) []common.Hash {
+	s.Lock()
+	defer s.Unlock()
+
+	candidates := make([]common.Hash, 0)
+
+	for hash, tx := range s.txs {
+		if tx.receipt != nil && !tx.final && tx.receipt.BlockNumber.Uint64() <= head {
+			candidates = append(candidates, hash)
+		}
+	}
+
+	return candidates
+}
+
+// markFinal marks the transaction final, updating its receipt if provided,
+// and returns its send time, or false if it was already final
+func (s *safeReceipts) markFinal(hash common.Hash, receipt *types.Receipt) (time.Time, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	tx, ok := s.txs[hash]
+	if !ok || tx.final {
+		return time.Time{}, false
+	}
+
+	if receipt != nil {
+		tx.receipt = receipt
+	}
+
+	tx.final = true
+	s.final++
+
+	return tx.sentAt, true
+}
+
+func (s *safeReceipts) counts() (included, final uint64) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.included, s.final
+}
+
+// awaitingFinality returns the number of included transactions that are not final yet, and were not abandoned
+func (s *safeReceipts) awaitingFinality() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.included - s.abandonedIncluded - s.final
+}
+
+func (s *safeReceipts) sentCount() uint64 {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.sent
+}
@@ -0,0 +1,306 @@
+package txreceipts
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ZeljkoBenovic/tpser/pkg/prom"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metrics are registered globally, so all the tests share them
+var testProm = prom.NewPrometheus(conf.Conf{}, logger.NewZapLogger())
+
+// chain serves the latest and the tagged block numbers, and the receipts of the included transactions
+type chain struct {
+	mux       sync.Mutex
+	head      uint64
+	finalized uint64
+	safe      uint64
+	blocks    map[common.Hash]uint64
+	// pending are the transactions known to the node, but not included
+	pending map[common.Hash]*types.Transaction
+}
+
+func (c *chain) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		c.mux.Lock()
+		defer c.mux.Unlock()
+
+		var result interface{}
+
+		switch req.Method {
+		case "eth_blockNumber":
+			result = hexutil.Uint64(c.head)
+		case "eth_getBlockByNumber":
+			var tag string
+			require.NoError(t, json.Unmarshal(req.Params[0], &tag))
+
+			number := map[string]uint64{"finalized": c.finalized, "safe": c.safe}[tag]
+			result = &types.Header{Number: new(big.Int).SetUint64(number), Difficulty: common.Big0}
+		case "eth_getTransactionByHash":
+			var hash common.Hash
+			require.NoError(t, json.Unmarshal(req.Params[0], &hash))
+
+			if tx, ok := c.pending[hash]; ok {
+				result = tx
+			}
+		case "eth_getTransactionReceipt":
+			var hash common.Hash
+			require.NoError(t, json.Unmarshal(req.Params[0], &hash))
+
+			if block, ok := c.blocks[hash]; ok {
+				result = receipt(hash, block)
+			}
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  result,
+		}))
+	}))
+}
+
+func receipt(hash common.Hash, block uint64) *types.Receipt {
+	return &types.Receipt{
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      hash,
+		BlockNumber: new(big.Int).SetUint64(block),
+		Logs:        []*types.Log{},
+	}
+}
+
+func newTestReceipts(t *testing.T, c *chain, cnf conf.Conf) *TxReceipts {
+	t.Helper()
+
+	srv := c.serve(t)
+	t.Cleanup(srv.Close)
+
+	eth, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+
+	t.Cleanup(eth.Close)
+
+	return New(context.Background(), logger.NewZapLogger(), eth, cnf, testProm)
+}
+
+func TestTxReceipts_FinalHead(t *testing.T) {
+	var heads = []struct {
+		name string
+		conf conf.Conf
+		want uint64
+	}{
+		{
+			name: "Inclusion",
+			conf: conf.Conf{Finality: conf.DepthFinality},
+			want: 100,
+		},
+		{
+			name: "Confirmation depth",
+			conf: conf.Conf{Finality: conf.DepthFinality, ConfirmDepth: 12},
+			want: 88,
+		},
+		{
+			name: "Confirmation depth above the chain",
+			conf: conf.Conf{Finality: conf.DepthFinality, ConfirmDepth: 200},
+			want: 0,
+		},
+		{
+			name: "Safe block",
+			conf: conf.Conf{Finality: conf.SafeFinality},
+			want: 90,
+		},
+		{
+			name: "Finalized block",
+			conf: conf.Conf{Finality: conf.FinalizedFinality},
+			want: 64,
+		},
+	}
+
+	for _, tc := range heads {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newTestReceipts(t, &chain{head: 100, safe: 90, finalized: 64}, tc.conf)
+
+			head, err := r.finalHead(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, head)
+		})
+	}
+}
+
+func TestTxReceipts_Finalize(t *testing.T) {
+	var (
+		final    = common.Hash{1}
+		reorged  = common.Hash{2}
+		included = common.Hash{3}
+		pending  = common.Hash{4}
+		c        = &chain{
+			head:      10,
+			finalized: 7,
+			// the reorged transaction was first seen in block 7
+			blocks: map[common.Hash]uint64{final: 5, reorged: 8, included: 9},
+		}
+		r = newTestReceipts(t, c, conf.Conf{Finality: conf.FinalizedFinality})
+	)
+
+	for _, hash := range []common.Hash{final, reorged, included, pending} {
+		r.StoreTxHash(hash, common.Address{}, 0, time.Now().Add(-time.Minute))
+	}
+
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(final, 5)))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(reorged, 7)))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(receipt(included, 9)))
+
+	r.finalize(context.Background(), 7)
+
+	includedCount, finalCount := r.safeReceipts.counts()
+	assert.Equal(t, uint64(3), includedCount)
+	assert.Equal(t, uint64(1), finalCount)
+	assert.True(t, r.safeReceipts.txs[final].final)
+
+	// the reorged transaction waits for its new block to be final
+	assert.False(t, r.safeReceipts.txs[reorged].final)
+	assert.Equal(t, uint64(8), r.safeReceipts.txs[reorged].receipt.BlockNumber.Uint64())
+
+	r.finalize(context.Background(), 9)
+
+	_, finalCount = r.safeReceipts.counts()
+	assert.Equal(t, uint64(3), finalCount)
+	assert.False(t, r.safeReceipts.txs[pending].final)
+
+	summary := r.finality.Summary()
+	assert.Equal(t, int64(3), summary.Count)
+	assert.GreaterOrEqual(t, summary.P50, time.Minute)
+}
+
+func TestTxReceipts_ConfirmTransactions(t *testing.T) {
+	var (
+		hashes = []common.Hash{{1}, {2}, {3}}
+		c      = &chain{
+			head:   20,
+			blocks: map[common.Hash]uint64{hashes[0]: 18, hashes[1]: 19, hashes[2]: 20},
+		}
+		r = newTestReceipts(t, c, conf.Conf{Finality: conf.DepthFinality, ConfirmDepth: 2, WaitForConfirmTimeout: 1})
+	)
+
+	for _, hash := range hashes {
+		r.StoreTxHash(hash, common.Address{}, 0, time.Now())
+	}
+
+	// two more blocks make all the transactions final
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+
+		c.mux.Lock()
+		c.head = 22
+		c.mux.Unlock()
+	}()
+
+	r.ConfirmTransactions()
+
+	included, final := r.safeReceipts.counts()
+	assert.Equal(t, uint64(3), included)
+	assert.Equal(t, uint64(3), final)
+	assert.GreaterOrEqual(t, r.finality.Summary().Max, time.Second)
+}
+
+func TestTxReceipts_Summarize(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	pendingTx, err := types.SignNewTx(key, types.NewEIP155Signer(big.NewInt(1)), &types.LegacyTx{
+		Gas:      21000,
+		GasPrice: big.NewInt(1),
+		To:       &common.Address{},
+		Value:    big.NewInt(1),
+	})
+	require.NoError(t, err)
+
+	var (
+		accountA = common.HexToAddress("0x0a")
+		accountB = common.HexToAddress("0x0b")
+
+		succeeded = common.Hash{1}
+		reverted  = common.Hash{2}
+		notFinal  = common.Hash{3}
+		dropped   = common.Hash{4}
+		pending   = pendingTx.Hash()
+
+		r = newTestReceipts(t, &chain{pending: map[common.Hash]*types.Transaction{pending: pendingTx}}, conf.Conf{})
+	)
+
+	r.StoreTxHash(succeeded, accountA, 0, time.Now())
+	r.StoreTxHash(reverted, accountA, 1, time.Now())
+	r.StoreTxHash(notFinal, accountB, 0, time.Now())
+	r.StoreTxHash(dropped, accountB, 1, time.Now())
+	r.StoreTxHash(pending, accountB, 2, time.Now())
+
+	withGas := func(hash common.Hash, status, gasUsed uint64, gasPrice int64) *types.Receipt {
+		rcpt := receipt(hash, 1)
+		rcpt.Status = status
+		rcpt.GasUsed = gasUsed
+		rcpt.EffectiveGasPrice = big.NewInt(gasPrice)
+
+		return rcpt
+	}
+
+	require.NoError(t, r.safeReceipts.storeTxReceipt(withGas(succeeded, types.ReceiptStatusSuccessful, 21000, 2e9)))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(withGas(reverted, types.ReceiptStatusFailed, 30000, 1e9)))
+	require.NoError(t, r.safeReceipts.storeTxReceipt(withGas(notFinal, types.ReceiptStatusSuccessful, 21000, 1e9)))
+
+	r.markFinal(succeeded, nil)
+	r.markFinal(reverted, nil)
+
+	summaries, total := r.summarize(r.droppedTxs(context.Background(), r.safeReceipts.notIncluded()))
+
+	require.Len(t, summaries, 2)
+
+	a, b := summaries[0], summaries[1]
+	assert.Equal(t, accountA, a.Account)
+	assert.Equal(t, int64(2), a.Sent)
+	assert.Equal(t, int64(1), a.Statuses[Succeeded])
+	assert.Equal(t, int64(1), a.Statuses[Reverted])
+	// reverted transactions pay for their gas too
+	assert.Equal(t, uint64(51000), a.GasUsed)
+	assert.Equal(t, big.NewInt(72000e9), a.Fees)
+
+	assert.Equal(t, accountB, b.Account)
+	assert.Equal(t, int64(3), b.Sent)
+	assert.Equal(t, int64(1), b.Statuses[Dropped])
+	// the pending and the included, but not final, transactions timed out
+	assert.Equal(t, int64(2), b.Statuses[TimedOut])
+	assert.Equal(t, big.NewInt(21000e9), b.Fees)
+
+	assert.Equal(t, int64(5), total.Sent)
+	assert.Equal(t, int64(1), total.Statuses[Succeeded])
+	assert.Equal(t, uint64(72000), total.GasUsed)
+	assert.Equal(t, big.NewInt(93000e9), total.Fees)
+	assert.Equal(t, "0.000093000000000000", weiToEth(total.Fees))
+}
@@ -6,8 +6,10 @@ import (
 
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type TxSender struct {
@@ -16,6 +18,13 @@ type TxSender struct {
 	log logger.Logger
 }
 
+// BatchResult is the outcome of a single transaction sent in a batch
+type BatchResult struct {
+	Hash  common.Hash
+	Nonce uint64
+	Err   error
+}
+
 func New(ctx context.Context, log logger.Logger, eth *ethclient.Client) *TxSender {
 	return &TxSender{
 		ctx: ctx,
@@ -32,3 +41,60 @@ func (t *TxSender) SendSignedTransaction(signedTx *types.Transaction) (common.Ha
 
 	return signedTx.Hash(), nil
 }
+
+// SendSignedTransactions sends the transactions in a single JSON-RPC batch request,
+// and returns the result of every transaction in the order of the provided transactions
+func (t *TxSender) SendSignedTransactions(signedTxs []*types.Transaction) []BatchResult {
+	var (
+		results = make([]BatchResult, len(signedTxs))
+		elems   = make([]rpc.BatchElem, 0, len(signedTxs))
+		// resultInd maps the batch elements to the results, transactions that could not be encoded are not sent
+		resultInd = make([]int, 0, len(signedTxs))
+	)
+
+	for ind, tx := range signedTxs {
+		results[ind] = BatchResult{
+			Hash:  tx.Hash(),
+			Nonce: tx.Nonce(),
+		}
+
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			results[ind].Err = fmt.Errorf("could not encode transaction: %w", err)
+			continue
+		}
+
+		elems = append(elems, rpc.BatchElem{
+			Method: "eth_sendRawTransaction",
+			Args:   []interface{}{hexutil.Encode(raw)},
+			Result: new(common.Hash),
+		})
+		resultInd = append(resultInd, ind)
+	}
+
+	if len(elems) == 0 {
+		return results
+	}
+
+	// the error of the whole request applies to every transaction sent in it
+	if err := t.eth.Client().BatchCallContext(t.ctx, elems); err != nil {
+		t.log.Debug("Could not send transactions batch", "err", err, "batch_size", len(elems))
+
+		for _, ind := range resultInd {
+			results[ind].Err = fmt.Errorf("could not send transactions batch: %w", err)
+		}
+
+		return results
+	}
+
+	for elemInd, elem := range elems {
+		if elem.Error != nil {
+			ind := resultInd[elemInd]
+
+			t.log.Debug("Could not send transaction", "err", elem.Error, "tx_hash", results[ind].Hash)
+			results[ind].Err = fmt.Errorf("could not send transaction: %w", elem.Error)
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,147 @@
+package txsender
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []string        `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// batchServer answers the eth_sendRawTransaction batch requests,
+// rejecting the transactions with the nonces in the rejected set
+func batchServer(t *testing.T, rejected map[uint64]bool, batches *int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []rpcRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reqs))
+
+		*batches++
+
+		resps := make([]rpcResponse, 0, len(reqs))
+
+		for _, req := range reqs {
+			assert.Equal(t, "eth_sendRawTransaction", req.Method)
+
+			tx := new(types.Transaction)
+			require.NoError(t, tx.UnmarshalBinary(hexutil.MustDecode(req.Params[0])))
+
+			resp := rpcResponse{Version: "2.0", ID: req.ID}
+			if rejected[tx.Nonce()] {
+				resp.Error = &rpcError{Code: -32000, Message: "nonce too low"}
+			} else {
+				resp.Result = tx.Hash()
+			}
+
+			resps = append(resps, resp)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(resps))
+	}))
+}
+
+func signedTxs(t *testing.T, count int) []*types.Transaction {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	to := common.HexToAddress("0x01")
+	txs := make([]*types.Transaction, 0, count)
+
+	for nonce := range make([]struct{}, count) {
+		tx, err := types.SignNewTx(key, types.NewLondonSigner(big.NewInt(1)), &types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Nonce:     uint64(nonce),
+			GasTipCap: big.NewInt(1),
+			GasFeeCap: big.NewInt(2),
+			Gas:       21000,
+			To:        &to,
+			Value:     big.NewInt(1),
+		})
+		require.NoError(t, err)
+
+		txs = append(txs, tx)
+	}
+
+	return txs
+}
+
+func TestTxSender_SendSignedTransactions(t *testing.T) {
+	var batches int
+
+	srv := batchServer(t, map[uint64]bool{1: true, 3: true}, &batches)
+	defer srv.Close()
+
+	eth, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+
+	defer eth.Close()
+
+	txs := signedTxs(t, 5)
+	results := New(context.Background(), logger.NewZapLogger(), eth).SendSignedTransactions(txs)
+
+	assert.Equal(t, 1, batches)
+	require.Len(t, results, len(txs))
+
+	for ind, res := range results {
+		// every result is mapped back to its transaction
+		assert.Equal(t, txs[ind].Hash(), res.Hash)
+		assert.Equal(t, txs[ind].Nonce(), res.Nonce)
+
+		if res.Nonce == 1 || res.Nonce == 3 {
+			assert.ErrorContains(t, res.Err, "nonce too low")
+		} else {
+			assert.NoError(t, res.Err)
+		}
+	}
+}
+
+func TestTxSender_SendSignedTransactions_RequestFailed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	eth, err := ethclient.Dial(srv.URL)
+	require.NoError(t, err)
+
+	defer eth.Close()
+
+	results := New(context.Background(), logger.NewZapLogger(), eth).SendSignedTransactions(signedTxs(t, 3))
+
+	// a failed request fails every transaction of the batch
+	for _, res := range results {
+		assert.ErrorContains(t, res.Err, "could not send transactions batch")
+	}
+}
@@ -6,13 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
 )
 
@@ -20,24 +26,43 @@ type txSignerEthClient interface {
 	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
 	SuggestGasPrice(ctx context.Context) (*big.Int, error)
 	ChainID(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+type accessListCreator interface {
+	CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*types.AccessList, uint64, string, error)
 }
 
 var (
 	ErrPubKey                  = errors.New("could not get public key from private")
 	ErrPKOrMnemonicNotProvided = errors.New("private key or mnemonic not provided")
+	ErrBaseFeeNotAvailable     = errors.New("base fee not available, network is not post-london")
 )
 
 var (
-	DefaultGasPrice = big.NewInt(30000000000)
-	EOAGasLimit     = uint64(21000)
-	EOAValue        = big.NewInt(100000)
+	DefaultGasPrice  = big.NewInt(30000000000)
+	DefaultGasTipCap = big.NewInt(1000000000)
+	EOAGasLimit      = uint64(21000)
+	EOAValue         = big.NewInt(100000)
+)
+
+const (
+	// FeeHistoryBlocks is the number of latest blocks used to derive the priority fee
+	FeeHistoryBlocks = 10
+	// FeeHistoryPercentile is the percentile of the priority fees paid in each of the latest blocks
+	FeeHistoryPercentile = 50
+	// maxCachedAccessLists is the number of created access lists kept, the least recently used one is evicted beyond it
+	maxCachedAccessLists = 1024
 )
 
 type TxSigner struct {
-	ctx  context.Context
-	log  logger.Logger
-	eth  txSignerEthClient
-	conf conf.Conf
+	ctx         context.Context
+	log         logger.Logger
+	eth         txSignerEthClient
+	accessLists accessListCreator
+	conf        conf.Conf
 
 	privateKey *ecdsa.PrivateKey
 	publicKey  *ecdsa.PublicKey
@@ -47,6 +72,53 @@ type TxSigner struct {
 	gasPrice   *big.Int
 	gasLimit   uint64
 	chainId    *big.Int
+
+	fees             *feeOracle
+	accessListsCache accessListsCache
+}
+
+// accessListsCache holds the created access lists and their gas used, keyed by the called contract and method
+type accessListsCache struct {
+	sync.Mutex
+	lists *lru.BasicLRU[accessListKey, createdAccessList]
+}
+
+// accessListKey is the called contract and the selector of the called method
+type accessListKey struct {
+	to       common.Address
+	selector [4]byte
+}
+
+type createdAccessList struct {
+	accessList types.AccessList
+	gasUsed    uint64
+}
+
+// feeOracles holds the fee oracle of every client, so all the signers of a client share its fees
+var feeOracles = struct {
+	sync.Mutex
+	byClient map[txSignerEthClient]*feeOracle
+}{
+	byClient: make(map[txSignerEthClient]*feeOracle),
+}
+
+// feeOracle holds the dynamic fee caps of a client, which are refreshed in the background during the run
+type feeOracle struct {
+	sync.RWMutex
+	log       logger.Logger
+	eth       txSignerEthClient
+	gasTipCap *big.Int
+	gasFeeCap *big.Int
+}
+
+// Call describes the payload of a transaction, a nil To creates a contract.
+// The AccessList is used only by the transaction types that support it.
+type Call struct {
+	To         *common.Address
+	Value      *big.Int
+	Data       []byte
+	Gas        uint64
+	AccessList types.AccessList
 }
 
 type Options struct {
@@ -63,10 +135,11 @@ func WithNumberOfAccounts(accNo int) SignerOpts {
 
 func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) *TxSigner {
 	return &TxSigner{
-		ctx:  ctx,
-		log:  log,
-		eth:  eth,
-		conf: conf,
+		ctx:         ctx,
+		log:         log,
+		eth:         eth,
+		accessLists: gethclient.New(eth.Client()),
+		conf:        conf,
 	}
 }
 
@@ -135,6 +208,15 @@ func (t *TxSigner) SetToAddress(toAddressString string) error {
 	t.chainId = chainId
 	t.to = common.HexToAddress(toAddressString)
 
+	if t.conf.TxType == conf.DynamicFeeTxType {
+		oracle, err := startFeeOracle(t.ctx, t.log, t.eth, time.Duration(t.conf.FeeRefreshSec)*time.Second)
+		if err != nil {
+			return err
+		}
+
+		t.fees = oracle
+	}
+
 	return nil
 }
 
@@ -145,16 +227,71 @@ func (t *TxSigner) GetFreshNonce() (uint64, error) {
 	return t.eth.PendingNonceAt(t.ctx, t.from)
 }
 func (t *TxSigner) GetNextSignedTx(nextNonce uint64) (*types.Transaction, error) {
-	newTx := types.NewTx(&types.LegacyTx{
-		Nonce:    nextNonce,
-		GasPrice: t.gasPrice,
-		Gas:      t.gasLimit,
-		To:       &t.to,
-		Value:    EOAValue,
-		Data:     []byte{},
+	return t.GetNextSignedCall(nextNonce, Call{
+		To:    &t.to,
+		Value: EOAValue,
+		Data:  []byte{},
+		Gas:   t.gasLimit,
 	})
+}
+
+// GetNextSignedCall signs a transaction with the provided nonce and payload
+func (t *TxSigner) GetNextSignedCall(nextNonce uint64, call Call) (*types.Transaction, error) {
+	value := call.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+
+	var (
+		newTx  *types.Transaction
+		signer types.Signer
+	)
 
-	tx, err := types.SignTx(newTx, types.NewEIP155Signer(t.chainId), t.privateKey)
+	if t.conf.CreateAccessList && t.conf.TxType != conf.LegacyTxType && call.To != nil && call.AccessList == nil && len(call.Data) > 0 {
+		call = t.withCreatedAccessList(call)
+	}
+
+	switch t.conf.TxType {
+	case conf.AccessListTxType:
+		newTx = types.NewTx(&types.AccessListTx{
+			ChainID:    t.chainId,
+			Nonce:      nextNonce,
+			GasPrice:   t.gasPrice,
+			Gas:        call.Gas,
+			To:         call.To,
+			Value:      value,
+			Data:       call.Data,
+			AccessList: call.AccessList,
+		})
+		signer = types.NewEIP2930Signer(t.chainId)
+	case conf.DynamicFeeTxType:
+		gasTipCap, gasFeeCap := t.getFees()
+
+		newTx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:    t.chainId,
+			Nonce:      nextNonce,
+			GasTipCap:  gasTipCap,
+			GasFeeCap:  gasFeeCap,
+			Gas:        call.Gas,
+			To:         call.To,
+			Value:      value,
+			Data:       call.Data,
+			AccessList: call.AccessList,
+		})
+		signer = types.NewLondonSigner(t.chainId)
+	default:
+		newTx = types.NewTx(&types.LegacyTx{
+			Nonce:    nextNonce,
+			GasPrice: t.gasPrice,
+			Gas:      call.Gas,
+			To:       call.To,
+			Value:    value,
+			Data:     call.Data,
+		})
+		signer = types.NewEIP155Signer(t.chainId)
+	}
+
+	tx, err := types.SignTx(newTx, signer, t.privateKey)
 	if err != nil {
 		return nil, fmt.Errorf("could not sign the transaction: %w", err)
 	}
@@ -162,10 +299,215 @@ func (t *TxSigner) GetNextSignedTx(nextNonce uint64) (*types.Transaction, error)
 	return tx, nil
 }
 
+// withCreatedAccessList returns the call with the access list created by the node.
+// The access list is created once per contract and method, for the first call of the method,
+// and the storage keys of the later calls are taken from it, as they mostly touch the same slots.
+// The gas limit is raised if the node reports more gas used with the access list.
+// If the access list can not be created, the call is returned unchanged.
+func (t *TxSigner) withCreatedAccessList(call Call) Call {
+	key := accessListKey{to: *call.To}
+	copy(key.selector[:], call.Data)
+
+	created, ok := t.accessListsCache.get(key)
+	if !ok {
+		accessList, gasUsed, vmErr, err := t.accessLists.CreateAccessList(t.ctx, ethereum.CallMsg{
+			From:  t.from,
+			To:    call.To,
+			Value: call.Value,
+			Data:  call.Data,
+		})
+		if err != nil || vmErr != "" || accessList == nil {
+			t.log.Debug("Could not create access list", "err", err, "vm_err", vmErr, "from", t.from.String())
+			return call
+		}
+
+		created = createdAccessList{
+			accessList: *accessList,
+			gasUsed:    gasUsed,
+		}
+
+		t.accessListsCache.add(key, created)
+	}
+
+	call.AccessList = created.accessList
+	call.Gas = max(call.Gas, created.gasUsed)
+
+	return call
+}
+
+// get returns the access list created for the contract method
+func (c *accessListsCache) get(key accessListKey) (createdAccessList, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.lists == nil {
+		return createdAccessList{}, false
+	}
+
+	return c.lists.Get(key)
+}
+
+// add stores the access list created for the contract method, evicting the least recently used one if the cache is full
+func (c *accessListsCache) add(key accessListKey, created createdAccessList) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.lists == nil {
+		lists := lru.NewBasicLRU[accessListKey, createdAccessList](maxCachedAccessLists)
+		c.lists = &lists
+	}
+
+	c.lists.Add(key, created)
+}
+
+// getFees returns the latest dynamic fee caps of the client
+func (t *TxSigner) getFees() (*big.Int, *big.Int) {
+	if t.fees == nil {
+		return nil, nil
+	}
+
+	t.fees.RLock()
+	defer t.fees.RUnlock()
+
+	return t.fees.gasTipCap, t.fees.gasFeeCap
+}
+
+// startFeeOracle returns the fee oracle of the client, starting it if the client has none yet.
+// A new oracle refreshes the fees once before it is returned, and then every interval until the context is done.
+func startFeeOracle(ctx context.Context, log logger.Logger, eth txSignerEthClient, interval time.Duration) (*feeOracle, error) {
+	feeOracles.Lock()
+	defer feeOracles.Unlock()
+
+	if oracle, ok := feeOracles.byClient[eth]; ok {
+		return oracle, nil
+	}
+
+	oracle := &feeOracle{
+		log: log,
+		eth: eth,
+	}
+
+	if err := oracle.refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	feeOracles.byClient[eth] = oracle
+
+	go oracle.run(ctx, interval)
+
+	return oracle, nil
+}
+
+// run refreshes the fees every interval until the context is done.
+// If a refresh fails, the previous fees are kept until the next one.
+func (f *feeOracle) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(max(interval, time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			feeOracles.Lock()
+			if feeOracles.byClient[f.eth] == f {
+				delete(feeOracles.byClient, f.eth)
+			}
+			feeOracles.Unlock()
+
+			return
+		case <-ticker.C:
+			if err := f.refresh(ctx); err != nil {
+				f.log.Warn("Could not refresh transaction fees, using previous", "err", err.Error())
+			}
+		}
+	}
+}
+
+// refresh derives the fee caps from the fee history of the latest blocks.
+// The tip cap is the median of the priority fees paid, and the fee cap
+// is twice the base fee of the next block plus the tip cap.
+func (f *feeOracle) refresh(ctx context.Context) error {
+	var (
+		gasTipCap *big.Int
+		baseFee   *big.Int
+	)
+
+	history, err := f.eth.FeeHistory(ctx, FeeHistoryBlocks, nil, []float64{FeeHistoryPercentile})
+	if err != nil {
+		f.log.Debug("Could not get fee history", "err", err.Error())
+	} else {
+		gasTipCap = medianReward(history.Reward)
+
+		if len(history.BaseFee) > 0 {
+			baseFee = history.BaseFee[len(history.BaseFee)-1]
+		}
+	}
+
+	if gasTipCap == nil || gasTipCap.Sign() == 0 {
+		gasTipCap, err = f.eth.SuggestGasTipCap(ctx)
+		if err != nil {
+			f.log.Warn("Could not get suggested gas tip cap, using default", "default", DefaultGasTipCap)
+			gasTipCap = DefaultGasTipCap
+		}
+	}
+
+	if baseFee == nil {
+		header, err := f.eth.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("could not get latest header: %w", err)
+		}
+
+		if header.BaseFee == nil {
+			return ErrBaseFeeNotAvailable
+		}
+
+		baseFee = header.BaseFee
+	}
+
+	gasFeeCap := new(big.Int).Add(new(big.Int).Mul(baseFee, big.NewInt(2)), gasTipCap)
+
+	f.Lock()
+	f.gasTipCap = gasTipCap
+	f.gasFeeCap = gasFeeCap
+	f.Unlock()
+
+	f.log.Debug("Transaction fees refreshed",
+		"base_fee", baseFee.String(),
+		"gas_tip_cap", gasTipCap.String(),
+		"gas_fee_cap", gasFeeCap.String(),
+	)
+
+	return nil
+}
+
+// medianReward returns the median of the first reward percentile of each block
+func medianReward(rewards [][]*big.Int) *big.Int {
+	tips := make([]*big.Int, 0, len(rewards))
+
+	for _, reward := range rewards {
+		if len(reward) > 0 && reward[0] != nil {
+			tips = append(tips, reward[0])
+		}
+	}
+
+	if len(tips) == 0 {
+		return nil
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].Cmp(tips[j]) < 0
+	})
+
+	return tips[len(tips)/2]
+}
+
 func (t *TxSigner) GetFromAddress() string {
 	return t.from.String()
 }
 
+func (t *TxSigner) GetFrom() common.Address {
+	return t.from
+}
+
 func (t *TxSigner) getPrivateKeyFromMnemonicDerivedNumber(accNo int) (*ecdsa.PrivateKey, error) {
 	wallet, err := hdwallet.NewFromMnemonic(t.conf.Mnemonic)
 	if err != nil {
@@ -1,15 +1,20 @@
 package txsigner
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ZeljkoBenovic/tpser/pkg/conf"
 	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 )
@@ -28,6 +33,35 @@ func (e ethClientMock) ChainID(ctx context.Context) (*big.Int, error) {
 	return big.NewInt(1000), nil
 }
 
+func (e ethClientMock) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(5), nil
+}
+
+func (e ethClientMock) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	return &ethereum.FeeHistory{
+		Reward:  [][]*big.Int{{big.NewInt(1)}, {big.NewInt(3)}, {big.NewInt(2)}},
+		BaseFee: []*big.Int{big.NewInt(10), big.NewInt(11), big.NewInt(12), big.NewInt(13)},
+	}, nil
+}
+
+func (e ethClientMock) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: big.NewInt(7)}, nil
+}
+
+// feeHistoryErrMock fails on fee history, so the fallbacks are used
+type feeHistoryErrMock struct {
+	ethClientMock
+	baseFee *big.Int
+}
+
+func (e feeHistoryErrMock) FeeHistory(ctx context.Context, blockCount uint64, lastBlock *big.Int, rewardPercentiles []float64) (*ethereum.FeeHistory, error) {
+	return nil, errors.New("method not found")
+}
+
+func (e feeHistoryErrMock) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: e.baseFee}, nil
+}
+
 func TestTxSigner_SetPrivateKey(t *testing.T) {
 	t.Parallel()
 	txs := TxSigner{}
@@ -219,6 +253,226 @@ func TestTxSigner_GetNextSignedTx(t *testing.T) {
 	}
 }
 
+func TestFeeOracle_refresh(t *testing.T) {
+	var testCases = []struct {
+		name          string
+		eth           txSignerEthClient
+		wantGasTipCap *big.Int
+		wantGasFeeCap *big.Int
+		shouldErr     bool
+	}{
+		{
+			name:          "Fees from fee history",
+			eth:           ethClientMock{},
+			wantGasTipCap: big.NewInt(2),
+			wantGasFeeCap: big.NewInt(28),
+		},
+		{
+			name:          "Fee history not available",
+			eth:           feeHistoryErrMock{baseFee: big.NewInt(7)},
+			wantGasTipCap: big.NewInt(5),
+			wantGasFeeCap: big.NewInt(19),
+		},
+		{
+			name:      "Pre-london network",
+			eth:       feeHistoryErrMock{},
+			shouldErr: true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			oracle := feeOracle{
+				eth: tt.eth,
+				log: logger.NewZapLogger(),
+			}
+
+			err := oracle.refresh(context.Background())
+			if tt.shouldErr {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.wantGasTipCap, oracle.gasTipCap)
+			assert.Equal(t, tt.wantGasFeeCap, oracle.gasFeeCap)
+		})
+	}
+}
+
+func TestStartFeeOracle(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log := logger.NewZapLogger()
+
+	_, err := startFeeOracle(ctx, log, &feeHistoryErrMock{}, time.Second)
+	assert.ErrorIs(t, err, ErrBaseFeeNotAvailable)
+
+	eth := &feeHistoryErrMock{baseFee: big.NewInt(7)}
+
+	oracle, err := startFeeOracle(ctx, log, eth, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(5), oracle.gasTipCap)
+	assert.Equal(t, big.NewInt(19), oracle.gasFeeCap)
+
+	shared, err := startFeeOracle(ctx, log, eth, time.Second)
+	assert.Nil(t, err)
+	assert.Same(t, oracle, shared)
+
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		feeOracles.Lock()
+		defer feeOracles.Unlock()
+
+		_, ok := feeOracles.byClient[eth]
+
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTxSigner_GetNextSignedCallDynamicFee(t *testing.T) {
+	testPrivKey, _ := crypto.GenerateKey()
+	testPubKey := testPrivKey.Public().(*ecdsa.PublicKey)
+	testAddress := crypto.PubkeyToAddress(*testPubKey)
+
+	tx := TxSigner{
+		eth:        ethClientMock{},
+		ctx:        context.Background(),
+		log:        logger.NewZapLogger(),
+		conf:       conf.Conf{TxType: conf.DynamicFeeTxType, FeeRefreshSec: 10},
+		privateKey: testPrivKey,
+		from:       testAddress,
+		chainId:    big.NewInt(1000),
+		fees:       &feeOracle{gasTipCap: big.NewInt(100), gasFeeCap: big.NewInt(200)},
+	}
+
+	signed, err := tx.GetNextSignedCall(3, Call{To: &testAddress, Gas: EOAGasLimit})
+	assert.Nil(t, err)
+
+	assert.Equal(t, uint8(types.DynamicFeeTxType), signed.Type())
+	assert.Equal(t, uint64(3), signed.Nonce())
+	assert.Equal(t, big.NewInt(100), signed.GasTipCap())
+	assert.Equal(t, big.NewInt(200), signed.GasFeeCap())
+
+	sender, err := types.Sender(types.NewLondonSigner(tx.chainId), signed)
+	assert.Nil(t, err)
+	assert.Equal(t, testAddress, sender)
+}
+
+type accessListCreatorMock struct {
+	calls      int
+	accessList types.AccessList
+	gasUsed    uint64
+	err        error
+}
+
+func (a *accessListCreatorMock) CreateAccessList(ctx context.Context, msg ethereum.CallMsg) (*types.AccessList, uint64, string, error) {
+	a.calls++
+	if a.err != nil {
+		return nil, 0, "", a.err
+	}
+
+	return &a.accessList, a.gasUsed, "", nil
+}
+
+func TestTxSigner_GetNextSignedCallAccessList(t *testing.T) {
+	testPrivKey, _ := crypto.GenerateKey()
+	testPubKey := testPrivKey.Public().(*ecdsa.PublicKey)
+	testAddress := crypto.PubkeyToAddress(*testPubKey)
+
+	contractAccessList := types.AccessList{
+		{Address: testAddress, StorageKeys: []common.Hash{common.HexToHash("0x01")}},
+	}
+
+	var testCases = []struct {
+		name             string
+		createAccessList bool
+		creator          *accessListCreatorMock
+		call             Call
+		signedTwice      bool
+		wantAccessList   types.AccessList
+		wantGas          uint64
+		wantCreatorCalls int
+	}{
+		{
+			name:             "Access list not created",
+			createAccessList: false,
+			creator:          &accessListCreatorMock{accessList: contractAccessList, gasUsed: 50000},
+			call:             Call{To: &testAddress, Data: []byte{0x01}, Gas: 40000},
+			wantAccessList:   types.AccessList{},
+			wantGas:          40000,
+			wantCreatorCalls: 0,
+		},
+		{
+			name:             "Access list created once per contract method",
+			createAccessList: true,
+			creator:          &accessListCreatorMock{accessList: contractAccessList, gasUsed: 50000},
+			call:             Call{To: &testAddress, Data: []byte{0xa9, 0x05, 0x9c, 0xbb, 0x01}, Gas: 40000},
+			signedTwice:      true,
+			wantAccessList:   contractAccessList,
+			wantGas:          50000,
+			wantCreatorCalls: 1,
+		},
+		{
+			name:             "Access list not created for value transfer",
+			createAccessList: true,
+			creator:          &accessListCreatorMock{accessList: contractAccessList, gasUsed: 50000},
+			call:             Call{To: &testAddress, Gas: EOAGasLimit},
+			wantAccessList:   types.AccessList{},
+			wantGas:          EOAGasLimit,
+			wantCreatorCalls: 0,
+		},
+		{
+			name:             "Access list creation fails",
+			createAccessList: true,
+			creator:          &accessListCreatorMock{err: errors.New("method not found")},
+			call:             Call{To: &testAddress, Data: []byte{0x01}, Gas: 40000},
+			wantAccessList:   types.AccessList{},
+			wantGas:          40000,
+			wantCreatorCalls: 1,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := TxSigner{
+				ctx:         context.Background(),
+				log:         logger.NewZapLogger(),
+				accessLists: tt.creator,
+				conf:        conf.Conf{TxType: conf.AccessListTxType, CreateAccessList: tt.createAccessList},
+				privateKey:  testPrivKey,
+				from:        testAddress,
+				chainId:     big.NewInt(1000),
+				gasPrice:    big.NewInt(100),
+			}
+
+			signed, err := tx.GetNextSignedCall(1, tt.call)
+			assert.Nil(t, err)
+
+			// the second call is of the same method, with other arguments
+			if tt.signedTwice {
+				next := tt.call
+				next.Data = append(bytes.Clone(tt.call.Data), 0x02)
+
+				signed, err = tx.GetNextSignedCall(2, next)
+				assert.Nil(t, err)
+			}
+
+			assert.Equal(t, uint8(types.AccessListTxType), signed.Type())
+			assert.Equal(t, tt.wantAccessList, signed.AccessList())
+			assert.Equal(t, tt.wantGas, signed.Gas())
+			assert.Equal(t, big.NewInt(100), signed.GasPrice())
+			assert.Equal(t, tt.wantCreatorCalls, tt.creator.calls)
+
+			sender, err := types.Sender(types.NewEIP2930Signer(tx.chainId), signed)
+			assert.Nil(t, err)
+			assert.Equal(t, testAddress, sender)
+		})
+	}
+}
+
 func TestTxSigner_getPrivateKeyFromMnemonicDerivedNumber(t *testing.T) {
 	var keysMap = make(map[int]string)
 
@@ -0,0 +1,31 @@
+package workload
+
+import (
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// eoa sends plain value transfers to the configured address
+type eoa struct {
+	to common.Address
+}
+
+func newEOA(conf conf.Conf) *eoa {
+	return &eoa{
+		to: common.HexToAddress(conf.ToAddress),
+	}
+}
+
+func (e *eoa) Setup(_ []*txsigner.TxSigner) error {
+	return nil
+}
+
+func (e *eoa) NextCall(_ int) txsigner.Call {
+	return txsigner.Call{
+		To:    &e.to,
+		Value: txsigner.EOAValue,
+		Data:  []byte{},
+		Gas:   txsigner.EOAGasLimit,
+	}
+}
@@ -0,0 +1,182 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/contracts"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ERC20DeployGasLimit   = uint64(1000000)
+	ERC20MintGasLimit     = uint64(100000)
+	ERC20TransferGasLimit = uint64(60000)
+	ERC20TransferAmount   = big.NewInt(1)
+)
+
+// erc20 sends token transfers to the configured address,
+// using either the bundled token or an existing one
+type erc20 struct {
+	setup
+	conf conf.Conf
+
+	token        common.Address
+	to           common.Address
+	transferData []byte
+	transferGas  uint64
+}
+
+func newERC20(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) *erc20 {
+	return &erc20{
+		setup: setup{
+			ctx: ctx,
+			log: log,
+			eth: eth,
+		},
+		conf: conf,
+		to:   common.HexToAddress(conf.ToAddress),
+	}
+}
+
+func (e *erc20) Setup(signers []*txsigner.TxSigner) error {
+	var err error
+
+	if e.conf.ERC20Token != "" {
+		e.token = common.HexToAddress(e.conf.ERC20Token)
+		err = e.distributeBalance(signers)
+	} else {
+		err = e.deployAndMint(signers)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	e.transferData, err = contracts.ERC20ABI.Pack("transfer", e.to, ERC20TransferAmount)
+	if err != nil {
+		return fmt.Errorf("could not pack transfer call: %w", err)
+	}
+
+	e.transferGas = e.estimateGas(signers[0].GetFrom(), txsigner.Call{
+		To:   &e.token,
+		Data: e.transferData,
+	}, ERC20TransferGasLimit)
+
+	e.log.Info("ERC20 workload ready", "token", e.token.String(), "transfer_gas", e.transferGas)
+
+	return nil
+}
+
+func (e *erc20) NextCall(_ int) txsigner.Call {
+	return txsigner.Call{
+		To:   &e.token,
+		Data: e.transferData,
+		Gas:  e.transferGas,
+	}
+}
+
+// deployAndMint deploys the bundled token from the first signer and mints tokens to every signer
+func (e *erc20) deployAndMint(signers []*txsigner.TxSigner) error {
+	code, err := contracts.ERC20DeployCode()
+	if err != nil {
+		return err
+	}
+
+	deploy := txsigner.Call{Data: code}
+	deploy.Gas = e.estimateGas(signers[0].GetFrom(), deploy, ERC20DeployGasLimit)
+
+	receipt, err := e.sendAndWait(signers[0], deploy)
+	if err != nil {
+		return fmt.Errorf("could not deploy erc20 token: %w", err)
+	}
+
+	e.token = receipt.ContractAddress
+	e.log.Info("ERC20 token deployed", "address", e.token.String(), "hash", receipt.TxHash.String())
+
+	amount := new(big.Int).Mul(big.NewInt(e.conf.ERC20Mint), new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+	errGr, _ := errgroup.WithContext(e.ctx)
+
+	for _, signer := range signers {
+		signer := signer
+
+		errGr.Go(func() error {
+			data, err := contracts.ERC20ABI.Pack("mint", signer.GetFrom(), amount)
+			if err != nil {
+				return fmt.Errorf("could not pack mint call: %w", err)
+			}
+
+			if _, err = e.sendAndWait(signer, txsigner.Call{To: &e.token, Data: data, Gas: ERC20MintGasLimit}); err != nil {
+				return fmt.Errorf("could not mint tokens to %s: %w", signer.GetFromAddress(), err)
+			}
+
+			e.log.Debug("ERC20 tokens minted", "to", signer.GetFromAddress(), "amount", amount.String())
+
+			return nil
+		})
+	}
+
+	return errGr.Wait()
+}
+
+// distributeBalance splits the token balance of the first signer evenly between all signers
+func (e *erc20) distributeBalance(signers []*txsigner.TxSigner) error {
+	if len(signers) < 2 {
+		return nil
+	}
+
+	balance, err := e.balanceOf(signers[0].GetFrom())
+	if err != nil {
+		return err
+	}
+
+	share := new(big.Int).Div(balance, big.NewInt(int64(len(signers))))
+	if share.Sign() == 0 {
+		e.log.Warn("ERC20 balance too low to distribute", "from", signers[0].GetFromAddress(), "balance", balance.String())
+		return nil
+	}
+
+	for _, signer := range signers[1:] {
+		data, err := contracts.ERC20ABI.Pack("transfer", signer.GetFrom(), share)
+		if err != nil {
+			return fmt.Errorf("could not pack transfer call: %w", err)
+		}
+
+		call := txsigner.Call{To: &e.token, Data: data}
+		call.Gas = e.estimateGas(signers[0].GetFrom(), call, ERC20TransferGasLimit)
+
+		if _, err = e.sendAndWait(signers[0], call); err != nil {
+			return fmt.Errorf("could not distribute tokens to %s: %w", signer.GetFromAddress(), err)
+		}
+
+		e.log.Debug("ERC20 tokens distributed", "to", signer.GetFromAddress(), "amount", share.String())
+	}
+
+	return nil
+}
+
+func (e *erc20) balanceOf(account common.Address) (*big.Int, error) {
+	data, err := contracts.ERC20ABI.Pack("balanceOf", account)
+	if err != nil {
+		return nil, fmt.Errorf("could not pack balanceOf call: %w", err)
+	}
+
+	out, err := e.eth.CallContract(e.ctx, ethereum.CallMsg{To: &e.token, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not get token balance: %w", err)
+	}
+
+	res, err := contracts.ERC20ABI.Unpack("balanceOf", out)
+	if err != nil {
+		return nil, fmt.Errorf("could not unpack token balance: %w", err)
+	}
+
+	return res[0].(*big.Int), nil
+}
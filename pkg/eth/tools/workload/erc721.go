@@ -0,0 +1,250 @@
+package workload
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/contracts"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"golang.org/x/sync/errgroup"
+)
+
+var (
+	ERC721DeployGasLimit   = uint64(2000000)
+	ERC721MintGasLimit     = uint64(60000)
+	ERC721TransferGasLimit = uint64(100000)
+	// ERC721MintBatchSize is the maximum number of tokens minted in a single transaction
+	ERC721MintBatchSize = int64(100)
+	// ERC721MinRoundSec is the shortest time, in seconds, in which the accounts send all the tokens they hold.
+	// A token is sent on only in the next round, so the round must outlast the inclusion of its transfer
+	ERC721MinRoundSec = int64(20)
+)
+
+// erc721 deploys the bundled NFT, mints tokens to every sender account
+// and then passes them around the ring of sender accounts with safeTransferFrom.
+//
+// Every account sends its tokens to the next account, so in each round an account
+// sends the tokens it received from the previous account in the round before.
+// A token of a failed send is still held by its account, which sends it again with its next call.
+type erc721 struct {
+	setup
+	conf conf.Conf
+
+	token       common.Address
+	accounts    []common.Address
+	perAcc      uint64
+	transferGas uint64
+
+	mux sync.Mutex
+	// held are the tokens every account holds, in the order it sends them. A sent token is held by the next account
+	// from the send on, and is sent by it only after all the tokens it held before
+	held [][]uint64
+}
+
+func newERC721(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) *erc721 {
+	return &erc721{
+		setup: setup{
+			ctx: ctx,
+			log: log,
+			eth: eth,
+		},
+		conf: conf,
+	}
+}
+
+func (e *erc721) Setup(signers []*txsigner.TxSigner) error {
+	code, err := contracts.ERC721DeployCode()
+	if err != nil {
+		return err
+	}
+
+	deploy := txsigner.Call{Data: code}
+	deploy.Gas = e.estimateGas(signers[0].GetFrom(), deploy, ERC721DeployGasLimit)
+
+	receipt, err := e.sendAndWait(signers[0], deploy)
+	if err != nil {
+		return fmt.Errorf("could not deploy erc721 token: %w", err)
+	}
+
+	e.token = receipt.ContractAddress
+	e.log.Info("ERC721 token deployed", "address", e.token.String(), "hash", receipt.TxHash.String())
+
+	e.accounts = make([]common.Address, 0, len(signers))
+	for _, signer := range signers {
+		e.accounts = append(e.accounts, signer.GetFrom())
+	}
+
+	e.perAcc = uint64(tokensPerAccount(e.conf, len(signers)))
+	e.held = minted(len(signers), e.perAcc)
+
+	if int64(e.perAcc) > e.conf.ERC721Mint {
+		e.log.Info("Minting more tokens to every account, so that a round of transfers outlasts their inclusion",
+			"tokens", e.perAcc,
+			"round_sec", ERC721MinRoundSec,
+		)
+	}
+
+	errGr, _ := errgroup.WithContext(e.ctx)
+
+	for ind, signer := range signers {
+		ind := ind
+		signer := signer
+
+		errGr.Go(func() error {
+			return e.mint(ind, signer)
+		})
+	}
+
+	if err = errGr.Wait(); err != nil {
+		return err
+	}
+
+	e.transferGas = e.estimateGas(signers[0].GetFrom(), e.transfer(0, 0), ERC721TransferGasLimit)
+
+	e.log.Info("ERC721 workload ready", "token", e.token.String(), "transfer_gas", e.transferGas)
+
+	return nil
+}
+
+func (e *erc721) NextCall(signerIndex int) txsigner.Call {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	held := e.held[signerIndex]
+
+	// an account that sent all its tokens before it received new ones holds nothing, so its transfer reverts
+	if len(held) == 0 {
+		return e.transfer(signerIndex, uint64(signerIndex)*e.perAcc)
+	}
+
+	var (
+		next    = (signerIndex + 1) % len(e.accounts)
+		tokenId = held[0]
+	)
+
+	e.held[signerIndex] = held[1:]
+	e.held[next] = append(e.held[next], tokenId)
+
+	return e.transfer(signerIndex, tokenId)
+}
+
+// SendFailed takes the token of the failed transfer back from the next account, and the signer sends it again
+// with its next call. Otherwise the next account would send a token it never received, in every later round
+func (e *erc721) SendFailed(signerIndex int, data []byte) {
+	if len(data) < 4 {
+		return
+	}
+
+	args, err := contracts.ERC721ABI.Methods["safeTransferFrom"].Inputs.Unpack(data[4:])
+	if err != nil || len(args) != 3 {
+		e.log.Debug("Could not decode failed transfer", "err", err)
+		return
+	}
+
+	tokenId, ok := args[2].(*big.Int)
+	if !ok {
+		return
+	}
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	// the token was queued last, so it is searched from the end
+	var (
+		next = (signerIndex + 1) % len(e.accounts)
+		ind  = -1
+	)
+
+	for i := len(e.held[next]) - 1; i >= 0; i-- {
+		if e.held[next][i] == tokenId.Uint64() {
+			ind = i
+			break
+		}
+	}
+
+	// the next account already sent the token on, which only happens if a whole round was sent since
+	if ind < 0 {
+		return
+	}
+
+	e.held[next] = slices.Delete(e.held[next], ind, ind+1)
+	e.held[signerIndex] = slices.Insert(e.held[signerIndex], 0, tokenId.Uint64())
+}
+
+// minted returns the tokens held by the accounts after the mint, token ids of each account start at index * perAcc
+func minted(accounts int, perAcc uint64) [][]uint64 {
+	held := make([][]uint64, accounts)
+
+	for ind := range held {
+		held[ind] = make([]uint64, 0, perAcc)
+
+		for id := uint64(ind) * perAcc; id < uint64(ind+1)*perAcc; id++ {
+			held[ind] = append(held[ind], id)
+		}
+	}
+
+	return held
+}
+
+// transfer returns the safeTransferFrom call of the token from the signer to the next account
+func (e *erc721) transfer(signerIndex int, tokenId uint64) txsigner.Call {
+	to := e.accounts[(signerIndex+1)%len(e.accounts)]
+
+	// the abi is bundled and the arguments are well typed, so packing can not fail
+	data, _ := contracts.ERC721ABI.Pack("safeTransferFrom", e.accounts[signerIndex], to, new(big.Int).SetUint64(tokenId))
+
+	return txsigner.Call{
+		To:   &e.token,
+		Data: data,
+		Gas:  e.transferGas,
+	}
+}
+
+// tokensPerAccount returns the number of tokens minted to each of the accounts: ERC721Mint, or more if the accounts
+// would send all of them in less than ERC721MinRoundSec at the highest rate of the mode,
+// or if every account pre-signs more transfers than it holds tokens
+func tokensPerAccount(cnf conf.Conf, accounts int) int64 {
+	rate := cnf.TxPerSec / max(cnf.TxSendInterval, 1)
+	if cnf.Mode == conf.FindMaxTPS {
+		rate = cnf.Search.MaxTxPerSec
+	}
+
+	perRound := rate * ERC721MinRoundSec
+
+	return max(cnf.ERC721Mint, (perRound+int64(accounts)-1)/int64(accounts), int64(cnf.Presign))
+}
+
+// mint mints the tokens of the signer in batches, token ids of each account start at index * tokens per account
+func (e *erc721) mint(index int, signer *txsigner.TxSigner) error {
+	var (
+		perAcc  = int64(e.perAcc)
+		firstId = int64(index) * perAcc
+	)
+
+	for minted := int64(0); minted < perAcc; minted += ERC721MintBatchSize {
+		count := min(ERC721MintBatchSize, perAcc-minted)
+
+		data, err := contracts.ERC721ABI.Pack("mintBatch", signer.GetFrom(), big.NewInt(firstId+minted), big.NewInt(count))
+		if err != nil {
+			return fmt.Errorf("could not pack mintBatch call: %w", err)
+		}
+
+		call := txsigner.Call{To: &e.token, Data: data}
+		call.Gas = e.estimateGas(signer.GetFrom(), call, uint64(count)*ERC721MintGasLimit+ERC721MintGasLimit)
+
+		if _, err = e.sendAndWait(signer, call); err != nil {
+			return fmt.Errorf("could not mint tokens to %s: %w", signer.GetFromAddress(), err)
+		}
+
+		e.log.Debug("ERC721 tokens minted", "to", signer.GetFromAddress(), "first_id", firstId+minted, "count", count)
+	}
+
+	return nil
+}
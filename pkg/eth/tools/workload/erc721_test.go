@@ -0,0 +1,184 @@
+package workload
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/contracts"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestERC721_NextCall(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		accounts int
+		perAcc   int64
+		rounds   int
+	}{
+		{
+			name:     "Single account sends to itself",
+			accounts: 1,
+			perAcc:   3,
+			rounds:   4,
+		},
+		{
+			name:     "Two accounts",
+			accounts: 2,
+			perAcc:   5,
+			rounds:   5,
+		},
+		{
+			name:     "Ring of accounts",
+			accounts: 7,
+			perAcc:   4,
+			rounds:   15,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			e, owners := newTestERC721(tt.accounts, tt.perAcc)
+
+			// every round, all accounts send all tokens they hold before the next round starts
+			for round := 0; round < tt.rounds; round++ {
+				for sent := int64(0); sent < tt.perAcc; sent++ {
+					for i := range e.accounts {
+						call := e.NextCall(i)
+						assert.Equal(t, e.token, *call.To)
+
+						from, to, tokenId := unpackTransfer(t, call)
+
+						assert.Equal(t, e.accounts[i], from)
+						assert.Equal(t, e.accounts[(i+1)%tt.accounts], to)
+						assert.Equal(t, from, owners[tokenId], fmt.Sprintf("round %d token %d", round, tokenId))
+
+						owners[tokenId] = to
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestERC721_SendFailed(t *testing.T) {
+	const (
+		accounts = 3
+		perAcc   = 4
+		rounds   = 6
+	)
+
+	var (
+		e, owners = newTestERC721(accounts, perAcc)
+		// failed is the token of the failed send of every account, until it is sent again
+		failed = map[int]uint64{}
+	)
+
+	// every round, another account fails to send its third call, and still holds the token afterwards
+	for round := 0; round < rounds; round++ {
+		for sent := 0; sent < perAcc; sent++ {
+			for i := range e.accounts {
+				call := e.NextCall(i)
+				from, to, tokenId := unpackTransfer(t, call)
+
+				require.Equal(t, from, owners[tokenId], fmt.Sprintf("round %d token %d", round, tokenId))
+
+				// the token of the failed send is sent again with the next call
+				if token, ok := failed[i]; ok {
+					assert.Equal(t, token, tokenId)
+					delete(failed, i)
+				}
+
+				if i == round%accounts && sent == 2 {
+					e.SendFailed(i, call.Data)
+					failed[i] = tokenId
+
+					continue
+				}
+
+				owners[tokenId] = to
+			}
+		}
+	}
+}
+
+func TestERC721_TokensPerAccount(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		conf     conf.Conf
+		accounts int
+		expected int64
+	}{
+		{
+			name:     "Minted tokens outlast the round",
+			conf:     conf.Conf{ERC721Mint: 1000, TxPerSec: 100, TxSendInterval: 1},
+			accounts: 2,
+			expected: 1000,
+		},
+		{
+			name:     "More tokens minted for the rate",
+			conf:     conf.Conf{ERC721Mint: 100, TxPerSec: 100, TxSendInterval: 1},
+			accounts: 3,
+			expected: 667,
+		},
+		{
+			name:     "Rate over a longer interval",
+			conf:     conf.Conf{ERC721Mint: 100, TxPerSec: 100, TxSendInterval: 5},
+			accounts: 1,
+			expected: 400,
+		},
+		{
+			name:     "Highest rate of the search",
+			conf:     conf.Conf{Mode: conf.FindMaxTPS, ERC721Mint: 100, TxPerSec: 100, Search: conf.Search{MaxTxPerSec: 500}},
+			accounts: 10,
+			expected: 1000,
+		},
+		{
+			name:     "More transfers pre-signed than minted",
+			conf:     conf.Conf{ERC721Mint: 100, TxPerSec: 10, TxSendInterval: 1, Presign: 500},
+			accounts: 1,
+			expected: 500,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tokensPerAccount(tt.conf, tt.accounts))
+		})
+	}
+}
+
+// newTestERC721 returns the workload of the accounts, and the owners of their minted tokens
+func newTestERC721(accounts int, perAcc int64) (*erc721, map[uint64]common.Address) {
+	e := &erc721{
+		conf:     conf.Conf{ERC721Mint: perAcc},
+		accounts: make([]common.Address, accounts),
+		perAcc:   uint64(perAcc),
+		held:     minted(accounts, uint64(perAcc)),
+	}
+
+	owners := map[uint64]common.Address{}
+	for i := range e.accounts {
+		e.accounts[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+
+		for id := int64(i) * perAcc; id < int64(i+1)*perAcc; id++ {
+			owners[uint64(id)] = e.accounts[i]
+		}
+	}
+
+	return e, owners
+}
+
+// unpackTransfer returns the sender, the recipient and the token of the safeTransferFrom call
+func unpackTransfer(t *testing.T, call txsigner.Call) (common.Address, common.Address, uint64) {
+	t.Helper()
+
+	args, err := contracts.ERC721ABI.Methods["safeTransferFrom"].Inputs.Unpack(call.Data[4:])
+	require.NoError(t, err)
+
+	return args[0].(common.Address), args[1].(common.Address), args[2].(*big.Int).Uint64()
+}
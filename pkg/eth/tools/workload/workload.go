@@ -0,0 +1,121 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/txsigner"
+	"github.com/ZeljkoBenovic/tpser/pkg/logger"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	ErrWorkloadNotSupported = errors.New("workload not supported")
+	ErrSetupTxFailed        = errors.New("workload setup transaction failed")
+)
+
+// Workload builds the payload of every transaction sent by the long-sender
+type Workload interface {
+	// Setup prepares the on-chain state the workload depends on, before the sending starts
+	Setup(signers []*txsigner.TxSigner) error
+	// NextCall returns the payload of the next transaction for the signer with the provided index
+	NextCall(signerIndex int) txsigner.Call
+}
+
+// FailureHandler is implemented by the workloads whose next calls depend on the previous calls being sent
+type FailureHandler interface {
+	// SendFailed handles the call data of a transaction of the signer that could not be sent
+	SendFailed(signerIndex int, data []byte)
+}
+
+// SendFailed reports the call data of a transaction of the signer that could not be sent to the workload,
+// if the workload handles failed sends
+func SendFailed(wl Workload, signerIndex int, data []byte) {
+	if handler, ok := wl.(FailureHandler); ok {
+		handler.SendFailed(signerIndex, data)
+	}
+}
+
+// factoryFunc is the function which must return Workload interface
+type factoryFunc func(context.Context, logger.Logger, *ethclient.Client, conf.Conf) Workload
+
+// workloadsFactory is a map of functions, with conf.Workload as key, that returns a Workload interface.
+var workloadsFactory = map[conf.Workload]factoryFunc{
+	conf.EOAWorkload: func(_ context.Context, _ logger.Logger, _ *ethclient.Client, conf conf.Conf) Workload {
+		return newEOA(conf)
+	},
+	conf.ERC20Workload: func(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) Workload {
+		return newERC20(ctx, log, eth, conf)
+	},
+	conf.ERC721Workload: func(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) Workload {
+		return newERC721(ctx, log, eth, conf)
+	},
+}
+
+func New(ctx context.Context, log logger.Logger, eth *ethclient.Client, conf conf.Conf) (Workload, error) {
+	workloadConstructor, ok := workloadsFactory[conf.Workload]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWorkloadNotSupported, conf.Workload)
+	}
+
+	return workloadConstructor(ctx, log.Named("workload"), eth, conf), nil
+}
+
+// setup holds the helpers used by the workloads that must send transactions before the sending starts
+type setup struct {
+	ctx context.Context
+	log logger.Logger
+	eth *ethclient.Client
+}
+
+// sendAndWait signs the call with the pending nonce of the signer, sends it and waits for a successful receipt
+func (s *setup) sendAndWait(signer *txsigner.TxSigner, call txsigner.Call) (*types.Receipt, error) {
+	nonce, err := signer.GetFreshNonce()
+	if err != nil {
+		return nil, fmt.Errorf("could not get pending nonce: %w", err)
+	}
+
+	tx, err := signer.GetNextSignedCall(nonce, call)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.eth.SendTransaction(s.ctx, tx); err != nil {
+		return nil, fmt.Errorf("could not send setup transaction: %w", err)
+	}
+
+	s.log.Debug("Setup transaction sent", "hash", tx.Hash().String(), "from", signer.GetFromAddress())
+
+	receipt, err := bind.WaitMined(s.ctx, s.eth, tx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get setup transaction receipt: %w", err)
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("%w: %s", ErrSetupTxFailed, tx.Hash().String())
+	}
+
+	return receipt, nil
+}
+
+// estimateGas returns the estimated gas of the call increased by 20%, or the fallback if the estimation fails
+func (s *setup) estimateGas(from common.Address, call txsigner.Call, fallback uint64) uint64 {
+	gas, err := s.eth.EstimateGas(s.ctx, ethereum.CallMsg{
+		From:  from,
+		To:    call.To,
+		Value: call.Value,
+		Data:  call.Data,
+	})
+	if err != nil {
+		s.log.Warn("Could not estimate gas, using default", "default", fallback, "err", err.Error())
+		return fallback
+	}
+
+	return gas * 12 / 10
+}
@@ -1,10 +1,21 @@
 package types
 
 type BlockInfo struct {
-	TransactionNum int
-	GasLimit       uint64
-	GasUsed        uint64
-	Hash           string
-	Number         uint64
-	Time           uint64
+	TransactionNum int    `json:"txs"`
+	GasLimit       uint64 `json:"gas_limit"`
+	GasUsed        uint64 `json:"gas_used"`
+	Hash           string `json:"hash"`
+	Number         uint64 `json:"number"`
+	Time           uint64 `json:"timestamp"`
+}
+
+// Receipt holds the receipt fields of an included transaction
+type Receipt struct {
+	TxHash string `json:"tx_hash"`
+	From   string `json:"from"`
+	// To is empty for a contract creation, with the created contract in ContractAddress
+	To              string `json:"to"`
+	ContractAddress string `json:"contract_address,omitempty"`
+	Type            uint8  `json:"type"`
+	GasUsed         uint64 `json:"gas_used"`
 }
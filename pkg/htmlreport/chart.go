@@ -0,0 +1,269 @@
+package htmlreport
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth  = 880
+	chartHeight = 280
+	// the plot area is inset by the paddings, leaving room for the axis labels and the legend
+	chartPadLeft   = 70
+	chartPadRight  = 20
+	chartPadTop    = 30
+	chartPadBottom = 45
+	// chartTicks is the number of intervals between the grid lines of the value axis
+	chartTicks = 4
+	// maxChartPoints is the number of points a line is reduced to, so that long runs keep the report small
+	maxChartPoints = 2000
+)
+
+// palette are the colors of the chart series, in order
+var palette = []string{"#2563eb", "#16a34a", "#dc2626", "#d97706", "#7c3aed", "#0891b2"}
+
+// Point is a point of a line chart
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Series is a named line of a line chart
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// LineChart plots one or more series against a shared horizontal axis
+type LineChart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	Series []Series
+}
+
+// BarSeries holds a value for every category of a bar chart
+type BarSeries struct {
+	Name   string
+	Values []float64
+}
+
+// BarChart plots the series as groups of bars, one group per category
+type BarChart struct {
+	Title      string
+	YLabel     string
+	Categories []string
+	Series     []BarSeries
+}
+
+// bounds is the range of the plotted values, the value axis always starts at zero
+type bounds struct {
+	minX, maxX float64
+	maxY       float64
+}
+
+func (b bounds) x(v float64) float64 {
+	return chartPadLeft + (v-b.minX)/(b.maxX-b.minX)*(chartWidth-chartPadLeft-chartPadRight)
+}
+
+func (b bounds) y(v float64) float64 {
+	return chartHeight - chartPadBottom - v/b.maxY*(chartHeight-chartPadTop-chartPadBottom)
+}
+
+// SVG renders the chart as an inline svg element
+func (c LineChart) SVG() template.HTML {
+	b := bounds{minX: math.Inf(1), maxX: math.Inf(-1)}
+
+	for _, series := range c.Series {
+		for _, p := range series.Points {
+			b.minX = min(b.minX, p.X)
+			b.maxX = max(b.maxX, p.X)
+			b.maxY = max(b.maxY, p.Y)
+		}
+	}
+
+	if math.IsInf(b.minX, 1) {
+		return empty(c.Title)
+	}
+
+	if b.maxX == b.minX {
+		b.maxX = b.minX + 1
+	}
+
+	b.maxY = niceMax(b.maxY)
+
+	var svg strings.Builder
+
+	open(&svg, c.Title)
+	valueAxis(&svg, b, c.YLabel)
+
+	for _, tick := range []float64{b.minX, (b.minX + b.maxX) / 2, b.maxX} {
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle" class="tick">%s</text>`,
+			b.x(tick), chartHeight-chartPadBottom+16, formatValue(tick))
+	}
+
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle" class="label">%s</text>`,
+		chartPadLeft+(chartWidth-chartPadLeft-chartPadRight)/2, chartHeight-6, html.EscapeString(c.XLabel))
+
+	for ind, series := range c.Series {
+		points := make([]string, 0, len(series.Points))
+		for _, p := range downsample(series.Points, maxChartPoints) {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", b.x(p.X), b.y(p.Y)))
+		}
+
+		fmt.Fprintf(&svg, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`,
+			palette[ind%len(palette)], strings.Join(points, " "))
+	}
+
+	legend(&svg, seriesNames(c.Series))
+	svg.WriteString(`</svg>`)
+
+	return template.HTML(svg.String())
+}
+
+// SVG renders the chart as an inline svg element
+func (c BarChart) SVG() template.HTML {
+	var b bounds
+
+	for _, series := range c.Series {
+		for _, v := range series.Values {
+			b.maxY = max(b.maxY, v)
+		}
+	}
+
+	if len(c.Categories) == 0 || len(c.Series) == 0 {
+		return empty(c.Title)
+	}
+
+	b.maxY = niceMax(b.maxY)
+
+	var (
+		svg      strings.Builder
+		group    = float64(chartWidth-chartPadLeft-chartPadRight) / float64(len(c.Categories))
+		barWidth = group * 0.8 / float64(len(c.Series))
+	)
+
+	open(&svg, c.Title)
+	valueAxis(&svg, b, c.YLabel)
+
+	for cat, category := range c.Categories {
+		groupStart := chartPadLeft + float64(cat)*group + group*0.1
+
+		for ind, series := range c.Series {
+			if cat >= len(series.Values) {
+				continue
+			}
+
+			value := series.Values[cat]
+			fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %s: %s</title></rect>`,
+				groupStart+float64(ind)*barWidth, b.y(value), barWidth, b.y(0)-b.y(value), palette[ind%len(palette)],
+				html.EscapeString(category), html.EscapeString(series.Name), formatValue(value))
+		}
+
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle" class="tick">%s</text>`,
+			groupStart+group*0.4, chartHeight-chartPadBottom+16, html.EscapeString(category))
+	}
+
+	names := make([]string, 0, len(c.Series))
+	for _, series := range c.Series {
+		names = append(names, series.Name)
+	}
+
+	legend(&svg, names)
+	svg.WriteString(`</svg>`)
+
+	return template.HTML(svg.String())
+}
+
+func open(svg *strings.Builder, title string) {
+	fmt.Fprintf(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img" aria-label="%s">`,
+		chartWidth, chartHeight, html.EscapeString(title))
+	fmt.Fprintf(svg, `<text x="%d" y="18" class="title">%s</text>`, chartPadLeft, html.EscapeString(title))
+}
+
+// valueAxis draws the grid lines of the value axis, with their values and the axis label
+func valueAxis(svg *strings.Builder, b bounds, label string) {
+	for tick := range make([]struct{}, chartTicks+1) {
+		value := b.maxY * float64(tick) / chartTicks
+
+		fmt.Fprintf(svg, `<line x1="%d" x2="%d" y1="%.1f" y2="%.1f" class="grid"/>`,
+			chartPadLeft, chartWidth-chartPadRight, b.y(value), b.y(value))
+		fmt.Fprintf(svg, `<text x="%d" y="%.1f" text-anchor="end" class="tick">%s</text>`,
+			chartPadLeft-6, b.y(value)+4, formatValue(value))
+	}
+
+	fmt.Fprintf(svg, `<text x="14" y="%d" transform="rotate(-90 14 %d)" text-anchor="middle" class="label">%s</text>`,
+		chartHeight/2, chartHeight/2, html.EscapeString(label))
+}
+
+func legend(svg *strings.Builder, names []string) {
+	x := chartWidth - chartPadRight
+
+	for ind := len(names) - 1; ind >= 0; ind-- {
+		x -= 12 + 7*len(names[ind]) + 16
+
+		fmt.Fprintf(svg, `<rect x="%d" y="9" width="10" height="10" fill="%s"/>`, x, palette[ind%len(palette)])
+		fmt.Fprintf(svg, `<text x="%d" y="18" class="tick">%s</text>`, x+14, html.EscapeString(names[ind]))
+	}
+}
+
+func empty(title string) template.HTML {
+	return template.HTML(fmt.Sprintf(`<p class="empty">%s: no data</p>`, html.EscapeString(title)))
+}
+
+func seriesNames(series []Series) []string {
+	names := make([]string, 0, len(series))
+	for _, s := range series {
+		names = append(names, s.Name)
+	}
+
+	return names
+}
+
+// downsample keeps every n-th point, so that at most limit points are left, always keeping the last one
+func downsample(points []Point, limit int) []Point {
+	if len(points) <= limit {
+		return points
+	}
+
+	step := (len(points) + limit - 1) / limit
+	sampled := make([]Point, 0, limit+1)
+
+	for ind := 0; ind < len(points); ind += step {
+		sampled = append(sampled, points[ind])
+	}
+
+	if last := points[len(points)-1]; sampled[len(sampled)-1] != last {
+		sampled = append(sampled, last)
+	}
+
+	return sampled
+}
+
+// niceMax rounds the maximum value up to 1, 2, 2.5 or 5 times a power of ten, so that the grid values are round
+func niceMax(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if v <= step*magnitude {
+			return step * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
+
+func formatValue(v float64) string {
+	if math.Abs(v) >= 100 || v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+
+	return fmt.Sprintf("%.2f", v)
+}
@@ -0,0 +1,247 @@
+package htmlreport
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/latency"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+)
+
+//go:embed report.html.tmpl
+var reportTemplate string
+
+var tmpl = template.Must(template.New("report").Parse(reportTemplate))
+
+// Run holds the data of a blocks-fetcher or long-sender run
+type Run struct {
+	Mode     conf.Mode
+	Started  time.Time
+	Finished time.Time
+	Settings []Setting
+
+	// Blocks are the blocks of the run, ordered by number
+	Blocks []types.BlockInfo
+	// Rates are the intended and achieved send rates of every send interval
+	Rates []Rate
+	// Sent is the number of transactions sent, zero if the run did not send any
+	Sent      int64
+	Errors    []Count
+	Latencies []Latency
+}
+
+// Setting is a configuration value of the run
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// Rate is the intended and the achieved send rate of a send interval, in transactions per second
+type Rate struct {
+	Elapsed  time.Duration
+	Intended float64
+	Achieved float64
+}
+
+// Count is a named number of occurrences, like the number of failed sends
+type Count struct {
+	Name  string
+	Value int64
+}
+
+// Latency holds the percentiles of a latency distribution
+type Latency struct {
+	Name  string
+	Count int64
+	Mean  time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// NewLatency returns the named latency percentiles of the summary
+func NewLatency(name string, summary latency.Summary) Latency {
+	return Latency{
+		Name:  name,
+		Count: summary.Count,
+		Mean:  summary.Mean,
+		P50:   summary.P50,
+		P90:   summary.P90,
+		P99:   summary.P99,
+		Max:   summary.Max,
+	}
+}
+
+// view is the data of the report template
+type view struct {
+	Run
+	Duration time.Duration
+	Summary  []Setting
+	Charts   []template.HTML
+}
+
+// Write writes the html report of the run to the file at the path
+func Write(path string, run Run) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create html report: %w", err)
+	}
+
+	defer f.Close()
+
+	if err = Render(f, run); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Render writes the html report of the run, the charts are inline svg and the styles are embedded,
+// so the report does not load any external asset
+func Render(w io.Writer, run Run) error {
+	v := view{
+		Run:      run,
+		Duration: run.Finished.Sub(run.Started).Round(time.Second),
+		Summary:  summary(run),
+		Charts:   charts(run),
+	}
+
+	if err := tmpl.Execute(w, v); err != nil {
+		return fmt.Errorf("could not render html report: %w", err)
+	}
+
+	return nil
+}
+
+// summary returns the headline numbers of the run
+func summary(run Run) []Setting {
+	stats := make([]Setting, 0)
+
+	if len(run.Blocks) != 0 {
+		var (
+			totalTxs uint64
+			gasUsed  float64
+			first    = run.Blocks[0]
+			last     = run.Blocks[len(run.Blocks)-1]
+			duration = float64(last.Time) - float64(first.Time)
+		)
+
+		for _, block := range run.Blocks {
+			totalTxs += uint64(block.TransactionNum)
+			gasUsed += utilization(block)
+		}
+
+		stats = append(stats,
+			Setting{Name: "Blocks", Value: fmt.Sprintf("%d (%d - %d)", len(run.Blocks), first.Number, last.Number)},
+			Setting{Name: "Transactions", Value: fmt.Sprintf("%d", totalTxs)},
+			Setting{Name: "Average gas utilization", Value: fmt.Sprintf("%.2f%%", gasUsed/float64(len(run.Blocks)))},
+		)
+
+		if duration > 0 {
+			stats = append(stats,
+				Setting{Name: "TPS", Value: fmt.Sprintf("%.2f", float64(totalTxs)/duration)},
+				Setting{Name: "Average block time", Value: fmt.Sprintf("%.2f s", duration/float64(len(run.Blocks)-1))},
+			)
+		}
+	}
+
+	for _, count := range run.Errors {
+		stats = append(stats, Setting{Name: count.Name, Value: fmt.Sprintf("%d", count.Value)})
+	}
+
+	return stats
+}
+
+// charts returns the charts of the data collected by the run
+func charts(run Run) []template.HTML {
+	var (
+		tps       = Series{Name: "tps"}
+		gas       = Series{Name: "gas used %"}
+		intervals = Series{Name: "block time"}
+		intended  = Series{Name: "intended"}
+		achieved  = Series{Name: "achieved"}
+		result    = make([]template.HTML, 0)
+	)
+
+	for ind, block := range run.Blocks {
+		elapsed := float64(block.Time) - float64(run.Blocks[0].Time)
+		gas.Points = append(gas.Points, Point{X: elapsed, Y: utilization(block)})
+
+		if ind == 0 {
+			continue
+		}
+
+		// block timestamps have a resolution of a second, so blocks with the same timestamp count as a second apart
+		interval := float64(block.Time) - float64(run.Blocks[ind-1].Time)
+		intervals.Points = append(intervals.Points, Point{X: elapsed, Y: interval})
+		tps.Points = append(tps.Points, Point{X: elapsed, Y: float64(block.TransactionNum) / max(interval, 1)})
+	}
+
+	if len(run.Blocks) != 0 {
+		result = append(result,
+			LineChart{Title: "TPS per block", XLabel: "seconds since the first block", YLabel: "tx/s", Series: []Series{tps}}.SVG(),
+			LineChart{Title: "Gas utilization", XLabel: "seconds since the first block", YLabel: "%", Series: []Series{gas}}.SVG(),
+			LineChart{Title: "Block time", XLabel: "seconds since the first block", YLabel: "seconds", Series: []Series{intervals}}.SVG(),
+		)
+	}
+
+	for _, rate := range run.Rates {
+		intended.Points = append(intended.Points, Point{X: rate.Elapsed.Seconds(), Y: rate.Intended})
+		achieved.Points = append(achieved.Points, Point{X: rate.Elapsed.Seconds(), Y: rate.Achieved})
+	}
+
+	if len(run.Rates) != 0 {
+		result = append(result, LineChart{
+			Title:  "Send rate",
+			XLabel: "seconds since the send started",
+			YLabel: "tx/s",
+			Series: []Series{intended, achieved},
+		}.SVG())
+	}
+
+	if len(run.Errors) != 0 {
+		errors := BarChart{Title: "Errors", YLabel: "transactions", Series: []BarSeries{{Name: "count"}}}
+
+		for _, count := range run.Errors {
+			errors.Categories = append(errors.Categories, count.Name)
+			errors.Series[0].Values = append(errors.Series[0].Values, float64(count.Value))
+		}
+
+		result = append(result, errors.SVG())
+	}
+
+	if len(run.Latencies) != 0 {
+		latencies := BarChart{
+			Title:  "Latency percentiles",
+			YLabel: "seconds",
+			Series: []BarSeries{{Name: "p50"}, {Name: "p90"}, {Name: "p99"}, {Name: "max"}},
+		}
+
+		for _, l := range run.Latencies {
+			latencies.Categories = append(latencies.Categories, l.Name)
+
+			for ind, value := range []time.Duration{l.P50, l.P90, l.P99, l.Max} {
+				latencies.Series[ind].Values = append(latencies.Series[ind].Values, value.Seconds())
+			}
+		}
+
+		result = append(result, latencies.SVG())
+	}
+
+	return result
+}
+
+// utilization returns the share of the block gas limit used, in percent
+func utilization(block types.BlockInfo) float64 {
+	if block.GasLimit == 0 {
+		return 0
+	}
+
+	return float64(block.GasUsed) / float64(block.GasLimit) * 100
+}
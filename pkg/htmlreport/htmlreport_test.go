@@ -0,0 +1,133 @@
+package htmlreport
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRun() Run {
+	started := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	run := Run{
+		Mode:     conf.LongSender,
+		Started:  started,
+		Finished: started.Add(10 * time.Minute),
+		Settings: Settings(conf.Conf{
+			Mode:       conf.LongSender,
+			JsonRPC:    "https://node.example.com/v3/secret-api-key",
+			PrivateKey: "private-key",
+			Mnemonic:   "test test test",
+			TxPerSec:   100,
+			Blocks:     conf.Blocks{Since: started, Last: time.Hour},
+			Endpoints:  []conf.Endpoint{{URL: "http://node-1:8545/key", Weight: 2}},
+		}),
+		Errors: []Count{{Name: "failed sends", Value: 3}},
+		Latencies: []Latency{
+			{Name: "inclusion", Count: 100, P50: 2 * time.Second, P90: 4 * time.Second, P99: 6 * time.Second, Max: 7 * time.Second},
+		},
+	}
+
+	for ind := range make([]struct{}, 10) {
+		run.Blocks = append(run.Blocks, types.BlockInfo{
+			TransactionNum: 200,
+			GasLimit:       30_000_000,
+			GasUsed:        15_000_000,
+			Number:         uint64(100 + ind),
+			Time:           uint64(started.Unix()) + uint64(ind*2),
+		})
+
+		run.Rates = append(run.Rates, Rate{Elapsed: time.Duration(ind) * time.Second, Intended: 100, Achieved: 95})
+	}
+
+	return run
+}
+
+func TestHTMLReport_Render(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, Render(&buf, testRun()))
+
+	report := buf.String()
+
+	// TPS per block, gas utilization, block time, send rate, errors and latencies
+	assert.Equal(t, 6, strings.Count(report, "<svg"))
+	assert.Contains(t, report, "<td>TxPerSec</td><td>100</td>")
+	assert.Contains(t, report, "<td>JsonRPC</td><td>node.example.com</td>")
+	assert.Contains(t, report, "node-1:8545 (weight 2)")
+	assert.Contains(t, report, "<td>Blocks.Since</td><td>2024-01-01T12:00:00Z</td>")
+	assert.Contains(t, report, "<td>Blocks.Until</td><td></td>")
+	assert.Contains(t, report, "<td>Blocks.Last</td><td>1h0m0s</td>")
+	// 2000 transactions over 18 seconds
+	assert.Contains(t, report, "111.11")
+
+	// the secrets are left out
+	for _, secret := range []string{"secret-api-key", "private-key", "test test test", "/key"} {
+		assert.NotContains(t, report, secret)
+	}
+
+	// the report does not load any external asset
+	for _, external := range []string{"<script", "<link", "src=", "href="} {
+		assert.NotContains(t, report, external)
+	}
+}
+
+func TestHTMLReport_RenderWithoutData(t *testing.T) {
+	var buf bytes.Buffer
+
+	run := Run{Mode: conf.BlocksFetcher, Started: time.Now(), Finished: time.Now()}
+
+	require.NoError(t, Render(&buf, run))
+	assert.NotContains(t, buf.String(), "<svg")
+}
+
+func TestHTMLReport_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.html")
+
+	require.NoError(t, Write(path, testRun()))
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(written), "<!DOCTYPE html>"))
+}
+
+func TestHTMLReport_Downsample(t *testing.T) {
+	points := make([]Point, 0)
+	for ind := range make([]struct{}, 10001) {
+		points = append(points, Point{X: float64(ind), Y: float64(ind)})
+	}
+
+	sampled := downsample(points, 1000)
+
+	assert.LessOrEqual(t, len(sampled), 1001)
+	assert.Equal(t, points[0], sampled[0])
+	assert.Equal(t, points[len(points)-1], sampled[len(sampled)-1])
+	assert.Len(t, downsample(points[:10], 1000), 10)
+}
+
+func TestHTMLReport_NiceMax(t *testing.T) {
+	var maxes = []struct {
+		value float64
+		want  float64
+	}{
+		{value: 0, want: 1},
+		{value: 0.7, want: 1},
+		{value: 1, want: 1},
+		{value: 1.3, want: 2},
+		{value: 22, want: 25},
+		{value: 420, want: 500},
+		{value: 7_500, want: 10_000},
+	}
+
+	for _, tc := range maxes {
+		assert.Equal(t, tc.want, niceMax(tc.value), "value %v", tc.value)
+	}
+}
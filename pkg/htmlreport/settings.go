@@ -0,0 +1,82 @@
+package htmlreport
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+)
+
+// skippedSettings are the configuration fields left out of the report, the secrets and the per-phase configurations
+var skippedSettings = map[string]struct{}{
+	"PrivateKey": {},
+	"Mnemonic":   {},
+	"TxHashes":   {},
+	"Phases":     {},
+}
+
+// Settings returns the configuration of the run, without the secrets.
+// The endpoints are reduced to their hosts, as their URLs can hold API keys
+func Settings(cnf conf.Conf) []Setting {
+	settings := make([]Setting, 0)
+	appendSettings(&settings, "", reflect.ValueOf(cnf))
+
+	return settings
+}
+
+func appendSettings(settings *[]Setting, prefix string, v reflect.Value) {
+	for ind := 0; ind < v.NumField(); ind++ {
+		var (
+			field = v.Type().Field(ind)
+			value = v.Field(ind)
+			name  = prefix + field.Name
+		)
+
+		if _, ok := skippedSettings[field.Name]; ok {
+			continue
+		}
+
+		switch {
+		case field.Name == "JsonRPC":
+			*settings = append(*settings, Setting{Name: name, Value: host(value.String())})
+		case field.Name == "Endpoints":
+			hosts := make([]string, 0, value.Len())
+			for _, endpoint := range value.Interface().([]conf.Endpoint) {
+				hosts = append(hosts, fmt.Sprintf("%s (weight %d)", host(endpoint.URL), endpoint.Weight))
+			}
+
+			*settings = append(*settings, Setting{Name: name, Value: strings.Join(hosts, ", ")})
+		case value.Type() == reflect.TypeOf(time.Time{}):
+			if t := value.Interface().(time.Time); !t.IsZero() {
+				*settings = append(*settings, Setting{Name: name, Value: t.Format(time.RFC3339)})
+				continue
+			}
+
+			*settings = append(*settings, Setting{Name: name})
+		case value.Kind() == reflect.Struct:
+			appendSettings(settings, name+".", value)
+		case value.Kind() == reflect.Pointer:
+			if value.IsNil() {
+				*settings = append(*settings, Setting{Name: name})
+				continue
+			}
+
+			*settings = append(*settings, Setting{Name: name, Value: fmt.Sprint(value.Elem().Interface())})
+		default:
+			*settings = append(*settings, Setting{Name: name, Value: fmt.Sprint(value.Interface())})
+		}
+	}
+}
+
+// host returns the host of the endpoint URL
+func host(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return u.Host
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Open returns the destination of a report, the file at the path, created or truncated, or stdout if the path is empty.
+// Closing the returned writer does not close stdout
+func Open(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create output file: %w", err)
+	}
+
+	return f, nil
+}
+
+// JSON writes the report as indented JSON
+func JSON(w io.Writer, report interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("could not write json output: %w", err)
+	}
+
+	return nil
+}
+
+// CSV writes the header and the rows as comma separated values
+func CSV(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("could not write csv output: %w", err)
+	}
+
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("could not write csv output: %w", err)
+	}
+
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
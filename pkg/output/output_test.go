@@ -0,0 +1,49 @@
+package output
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutput_JSON(t *testing.T) {
+	var (
+		buf    bytes.Buffer
+		report = struct {
+			Blocks int     `json:"blocks"`
+			TPS    float64 `json:"tps"`
+		}{Blocks: 10, TPS: 12.5}
+	)
+
+	require.NoError(t, JSON(&buf, report))
+	assert.JSONEq(t, `{"blocks": 10, "tps": 12.5}`, buf.String())
+}
+
+func TestOutput_CSV(t *testing.T) {
+	var buf bytes.Buffer
+
+	require.NoError(t, CSV(&buf, []string{"number", "txs"}, [][]string{{"1", "20"}, {"2", "a,b"}}))
+	assert.Equal(t, "number,txs\n1,20\n2,\"a,b\"\n", buf.String())
+}
+
+func TestOutput_Open(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+
+	w, err := Open(path)
+	require.NoError(t, err)
+
+	require.NoError(t, JSON(w, []int{1, 2}))
+	require.NoError(t, w.Close())
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[1, 2]`, string(written))
+
+	stdout, err := Open("")
+	require.NoError(t, err)
+	assert.NoError(t, stdout.Close())
+}
@@ -22,6 +22,16 @@ type metrics struct {
 	transactionNumberPerInterval            prometheus.Gauge
 	transactionSendInterval                 prometheus.Gauge
 	transactionErrorCount                   prometheus.Counter
+	transactionScheduleLagHistogram         prometheus.Histogram
+	transactionAchievedRate                 prometheus.Gauge
+	endpointRequestDurationHistogram        *prometheus.HistogramVec
+	endpointErrorCount                      *prometheus.CounterVec
+	transactionInclusionLatencyHistogram    prometheus.Histogram
+	transactionPendingInclusion             prometheus.Gauge
+	transactionPendingConfirmation          prometheus.Gauge
+	transactionConfirmedCount               prometheus.Counter
+	transactionRevertedCount                prometheus.Counter
+	transactionStuckNonceCount              prometheus.Counter
 }
 
 func NewPrometheus(conf conf.Conf, log logger.Logger) *Prom {
@@ -49,6 +59,58 @@ func NewPrometheus(conf conf.Conf, log logger.Logger) *Prom {
 				Name:      "tx_send_error_count",
 				Help:      "the number of transaction send errors",
 			}),
+			transactionScheduleLagHistogram: promauto.NewHistogram(prometheus.HistogramOpts{
+				Namespace: "tpser",
+				Name:      "tx_schedule_lag_milliseconds",
+				Help:      "time between the intended and the actual send of a transaction in milliseconds",
+				Buckets:   prometheus.ExponentialBuckets(1, 2, 16),
+			}),
+			transactionAchievedRate: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "tpser",
+				Name:      "tx_achieved_rate",
+				Help:      "the number of transactions per second actually sent",
+			}),
+			endpointRequestDurationHistogram: promauto.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: "tpser",
+				Name:      "endpoint_request_duration_milliseconds",
+				Help:      "transaction send request duration of each endpoint in milliseconds",
+			}, []string{"endpoint"}),
+			endpointErrorCount: promauto.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "tpser",
+				Name:      "endpoint_error_count",
+				Help:      "the number of transaction send errors of each endpoint",
+			}, []string{"endpoint"}),
+			transactionInclusionLatencyHistogram: promauto.NewHistogram(prometheus.HistogramOpts{
+				Namespace: "tpser",
+				Name:      "tx_inclusion_latency_seconds",
+				Help:      "time between the send of a transaction and the timestamp of the block that included it in seconds",
+				Buckets:   []float64{1, 2, 3, 5, 10, 20, 30, 60},
+			}),
+			transactionPendingInclusion: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "tpser",
+				Name:      "tx_pending_inclusion",
+				Help:      "the number of sent transactions not included in a block yet",
+			}),
+			transactionPendingConfirmation: promauto.NewGauge(prometheus.GaugeOpts{
+				Namespace: "tpser",
+				Name:      "tx_pending_confirmation",
+				Help:      "the number of sent transactions not final yet",
+			}),
+			transactionConfirmedCount: promauto.NewCounter(prometheus.CounterOpts{
+				Namespace: "tpser",
+				Name:      "tx_confirmed_count",
+				Help:      "the number of final transactions whose execution succeeded",
+			}),
+			transactionRevertedCount: promauto.NewCounter(prometheus.CounterOpts{
+				Namespace: "tpser",
+				Name:      "tx_reverted_count",
+				Help:      "the number of final transactions whose execution reverted",
+			}),
+			transactionStuckNonceCount: promauto.NewCounter(prometheus.CounterOpts{
+				Namespace: "tpser",
+				Name:      "tx_stuck_nonce_count",
+				Help:      "the number of detected stuck account nonces",
+			}),
 		},
 	}
 }
@@ -79,3 +141,43 @@ func (p *Prom) SetTxSendInterval(txSendInterval float64) {
 func (p *Prom) IncreaseTxErrorCount() {
 	p.metrics.transactionErrorCount.Inc()
 }
+
+func (p *Prom) ObserveTxScheduleLag(observable float64) {
+	p.metrics.transactionScheduleLagHistogram.Observe(observable)
+}
+
+func (p *Prom) SetTxAchievedRate(rate float64) {
+	p.metrics.transactionAchievedRate.Set(rate)
+}
+
+func (p *Prom) ObserveEndpointRequestDuration(endpoint string, observable float64) {
+	p.metrics.endpointRequestDurationHistogram.WithLabelValues(endpoint).Observe(observable)
+}
+
+func (p *Prom) IncreaseEndpointErrorCount(endpoint string) {
+	p.metrics.endpointErrorCount.WithLabelValues(endpoint).Inc()
+}
+
+func (p *Prom) ObserveTxInclusionLatency(observable float64) {
+	p.metrics.transactionInclusionLatencyHistogram.Observe(observable)
+}
+
+func (p *Prom) SetTxPendingInclusion(txNumber float64) {
+	p.metrics.transactionPendingInclusion.Set(txNumber)
+}
+
+func (p *Prom) SetTxPendingConfirmation(txNumber float64) {
+	p.metrics.transactionPendingConfirmation.Set(txNumber)
+}
+
+func (p *Prom) AddTxConfirmedCount(txNumber float64) {
+	p.metrics.transactionConfirmedCount.Add(txNumber)
+}
+
+func (p *Prom) AddTxRevertedCount(txNumber float64) {
+	p.metrics.transactionRevertedCount.Add(txNumber)
+}
+
+func (p *Prom) IncreaseTxStuckNonceCount() {
+	p.metrics.transactionStuckNonceCount.Inc()
+}
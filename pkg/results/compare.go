@@ -0,0 +1,160 @@
+package results
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+)
+
+// Kind is how a metric is compared, and the tolerance its change is compared with
+type Kind string
+
+const (
+	// ThroughputKind is a metric that regresses when it drops, by more than the tps tolerance in percent
+	ThroughputKind Kind = "throughput"
+	// LatencyKind is a metric that regresses when it rises, by more than the latency tolerance in percent
+	LatencyKind Kind = "latency"
+	// ErrorRateKind is a rate that regresses when it rises, by more than the error tolerance in percentage points
+	ErrorRateKind Kind = "error_rate"
+	// InfoKind is a metric that is shown, but never regresses
+	InfoKind Kind = "info"
+)
+
+// Delta is the change of a metric from the baseline to the candidate run
+type Delta struct {
+	Metric    string  `json:"metric"`
+	Kind      Kind    `json:"kind"`
+	Baseline  float64 `json:"baseline"`
+	Candidate float64 `json:"candidate"`
+	// Change is in percentage points for an error rate, and in percent of the baseline for the other metrics,
+	// unless the baseline is zero, then it is the absolute change
+	Change float64 `json:"change"`
+	// Absolute is set if the change is the absolute change, of a metric with a zero baseline
+	Absolute bool `json:"absolute"`
+	// Missing is set if the metric of the baseline is missing or empty in the candidate, which has no change
+	Missing    bool `json:"missing"`
+	Regression bool `json:"regression"`
+}
+
+// Compare returns the changes of the metrics of the candidate from the baseline, with the regressions beyond
+// the tolerances flagged. The metrics of the baseline are compared: the block statistics if it has blocks,
+// and the latencies and the error rates by their names. A metric of the baseline that is missing or empty
+// in the candidate, like the block statistics of a candidate without blocks, is a regression
+func Compare(baseline, candidate Results, tolerances conf.Comparison) []Delta {
+	deltas := make([]Delta, 0)
+
+	add := func(metric string, kind Kind, base, cand float64, found bool) {
+		if !found {
+			deltas = append(deltas, missingDelta(metric, kind, base))
+			return
+		}
+
+		deltas = append(deltas, newDelta(metric, kind, base, cand, tolerances))
+	}
+
+	if base, cand := baseline.Blocks, candidate.Blocks; base.Blocks != 0 {
+		found := cand.Blocks != 0
+
+		add("tps", ThroughputKind, base.TPS, cand.TPS, found)
+		add("peak block tps", ThroughputKind, base.PeakTPS, cand.PeakTPS, found)
+
+		// the rolling rates of different windows are not comparable
+		if !found || base.RollingTPS.WindowSec == cand.RollingTPS.WindowSec {
+			add(fmt.Sprintf("rolling %ds tps min", base.RollingTPS.WindowSec), ThroughputKind, base.RollingTPS.Min, cand.RollingTPS.Min, found)
+		}
+
+		add("gas per second", ThroughputKind, base.GasPerSec, cand.GasPerSec, found)
+		add("block time mean", LatencyKind, base.BlockTime.Mean, cand.BlockTime.Mean, found)
+		add("empty blocks", InfoKind, float64(base.EmptyBlocks), float64(cand.EmptyBlocks), found)
+		add("gas used p50", InfoKind, base.Utilization.P50, cand.Utilization.P50, found)
+	}
+
+	for _, base := range baseline.Latencies {
+		// a latency without samples, like the inclusion of a run that included nothing, has no percentiles
+		if base.Count == 0 {
+			continue
+		}
+
+		var cand Latency
+
+		ind := slices.IndexFunc(candidate.Latencies, func(lat Latency) bool { return lat.Name == base.Name })
+		if ind != -1 {
+			cand = candidate.Latencies[ind]
+		}
+
+		found := cand.Count != 0
+
+		add(base.Name+" p50", LatencyKind, base.P50, cand.P50, found)
+		add(base.Name+" p90", LatencyKind, base.P90, cand.P90, found)
+		add(base.Name+" p99", LatencyKind, base.P99, cand.P99, found)
+	}
+
+	// the error rates are of the sent transactions
+	if baseline.Sent == 0 {
+		return deltas
+	}
+
+	for _, base := range baseline.Errors {
+		var cand Error
+
+		ind := slices.IndexFunc(candidate.Errors, func(errs Error) bool { return errs.Name == base.Name })
+		if ind != -1 {
+			cand = candidate.Errors[ind]
+		}
+
+		add(base.Name, ErrorRateKind, base.Rate, cand.Rate, ind != -1 && candidate.Sent != 0)
+	}
+
+	return deltas
+}
+
+// Regressions returns the number of the regressed metrics
+func Regressions(deltas []Delta) int {
+	regressions := 0
+
+	for _, delta := range deltas {
+		if delta.Regression {
+			regressions++
+		}
+	}
+
+	return regressions
+}
+
+// missingDelta returns the delta of a metric missing from the candidate, which regresses unless it is only shown
+func missingDelta(metric string, kind Kind, base float64) Delta {
+	return Delta{
+		Metric:     metric,
+		Kind:       kind,
+		Baseline:   base,
+		Missing:    true,
+		Regression: kind != InfoKind,
+	}
+}
+
+func newDelta(metric string, kind Kind, base, cand float64, tolerances conf.Comparison) Delta {
+	delta := Delta{Metric: metric, Kind: kind, Baseline: base, Candidate: cand}
+
+	switch {
+	case kind == ErrorRateKind:
+		delta.Change = cand - base
+	case base != 0:
+		delta.Change = (cand - base) / base * 100
+	default:
+		// the change from zero is not relative to anything
+		delta.Change, delta.Absolute = cand, true
+	}
+
+	switch kind {
+	case ThroughputKind:
+		delta.Regression = -delta.Change > tolerances.TPSTolerancePct
+	case LatencyKind:
+		// a latency rising from zero regresses by any amount, as no relative tolerance applies to it
+		delta.Regression = delta.Change > tolerances.LatencyTolerancePct || (delta.Absolute && cand > 0)
+	case ErrorRateKind:
+		delta.Regression = delta.Change > tolerances.ErrorTolerancePct
+	}
+
+	return delta
+}
@@ -0,0 +1,235 @@
+package results
+
+import (
+	"testing"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockstats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var tolerances = conf.Comparison{TPSTolerancePct: 5, LatencyTolerancePct: 10, ErrorTolerancePct: 1}
+
+func testResults(tps, blockTime, p99, errorRate float64) Results {
+	return Results{
+		Blocks: blockstats.Stats{
+			Blocks:     10,
+			TPS:        tps,
+			PeakTPS:    tps * 2,
+			RollingTPS: blockstats.RollingTPS{WindowSec: 10, Min: tps / 2},
+			GasPerSec:  tps * 21000,
+			BlockTime:  blockstats.Spread{Mean: blockTime},
+		},
+		Sent:      1000,
+		Latencies: []Latency{{Name: "inclusion", Count: 990, P50: 1000, P90: 2000, P99: p99}},
+		Errors:    []Error{{Name: "failed sends", Count: int64(errorRate * 10), Rate: errorRate}},
+	}
+}
+
+// deltaOf returns the delta of the metric
+func deltaOf(t *testing.T, deltas []Delta, metric string) Delta {
+	t.Helper()
+
+	for _, delta := range deltas {
+		if delta.Metric == metric {
+			return delta
+		}
+	}
+
+	require.Failf(t, "metric not compared", metric)
+
+	return Delta{}
+}
+
+func TestCompare(t *testing.T) {
+	var tests = []struct {
+		name        string
+		candidate   Results
+		regressions []string
+	}{
+		{
+			name:      "Within tolerances",
+			candidate: testResults(96, 2.1, 3200, 1.5),
+		},
+		{
+			name:        "Throughput dropped",
+			candidate:   testResults(90, 2, 3000, 1),
+			regressions: []string{"tps", "peak block tps", "rolling 10s tps min", "gas per second"},
+		},
+		{
+			name:        "Latency and block time rose",
+			candidate:   testResults(100, 2.5, 3500, 1),
+			regressions: []string{"block time mean", "inclusion p99"},
+		},
+		{
+			name:        "Error rate rose",
+			candidate:   testResults(100, 2, 3000, 2.5),
+			regressions: []string{"failed sends"},
+		},
+		{
+			name:      "Improved",
+			candidate: testResults(150, 1, 1000, 0),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deltas := Compare(testResults(100, 2, 3000, 1), tc.candidate, tolerances)
+
+			regressions := make([]string, 0)
+			for _, delta := range deltas {
+				if delta.Regression {
+					regressions = append(regressions, delta.Metric)
+				}
+			}
+
+			assert.ElementsMatch(t, tc.regressions, regressions)
+			assert.Equal(t, len(tc.regressions), Regressions(deltas))
+		})
+	}
+}
+
+func TestCompare_Changes(t *testing.T) {
+	deltas := Compare(testResults(100, 2, 3000, 1), testResults(90, 2, 3300, 2.5), tolerances)
+
+	// the throughput and the latencies change in percent, the error rates in percentage points
+	assert.InDelta(t, -10, deltaOf(t, deltas, "tps").Change, 1e-9)
+	assert.InDelta(t, 10, deltaOf(t, deltas, "inclusion p99").Change, 1e-9)
+	assert.InDelta(t, 1.5, deltaOf(t, deltas, "failed sends").Change, 1e-9)
+
+	// the latency rose by exactly its tolerance
+	assert.False(t, deltaOf(t, deltas, "inclusion p99").Regression)
+}
+
+func TestCompare_ZeroBaseline(t *testing.T) {
+	var (
+		baseline  = testResults(100, 2, 3000, 0)
+		candidate = testResults(100, 2, 3000, 0.5)
+	)
+
+	baseline.Latencies[0].P50 = 0
+	candidate.Latencies[0].P50 = 1
+	baseline.Blocks.PeakTPS = 0
+
+	deltas := Compare(baseline, candidate, tolerances)
+
+	// a latency moving away from zero regresses, with its absolute change
+	p50 := deltaOf(t, deltas, "inclusion p50")
+	assert.True(t, p50.Regression)
+	assert.True(t, p50.Absolute)
+	assert.Equal(t, 1.0, p50.Change)
+
+	// a throughput rising from zero does not regress
+	peak := deltaOf(t, deltas, "peak block tps")
+	assert.False(t, peak.Regression)
+	assert.Equal(t, 200.0, peak.Change)
+
+	// an error rate rising from zero within its tolerance does not regress, beyond it it does
+	assert.False(t, deltaOf(t, deltas, "failed sends").Regression)
+
+	candidate.Errors[0].Rate = 1.5
+	assert.True(t, deltaOf(t, Compare(baseline, candidate, tolerances), "failed sends").Regression)
+
+	// a latency staying at zero does not regress
+	candidate.Latencies[0].P50 = 0
+	assert.False(t, deltaOf(t, Compare(baseline, candidate, tolerances), "inclusion p50").Regression)
+}
+
+func TestCompare_MissingMetrics(t *testing.T) {
+	var (
+		baseline  = testResults(100, 2, 3000, 1)
+		candidate = testResults(50, 4, 6000, 5)
+	)
+
+	// the rolling rates of different windows are not compared
+	candidate.Blocks.RollingTPS.WindowSec = 30
+
+	deltas := Compare(baseline, candidate, tolerances)
+
+	for _, delta := range deltas {
+		assert.NotEqual(t, "rolling 10s tps min", delta.Metric)
+	}
+
+	// a blocks-fetcher candidate has no sends, so its latencies and error rates are missing
+	candidate.Sent = 0
+	candidate.Latencies = []Latency{{Name: "inclusion"}}
+	candidate.Errors = nil
+
+	deltas = Compare(baseline, candidate, tolerances)
+
+	for _, metric := range []string{"inclusion p50", "inclusion p90", "inclusion p99", "failed sends"} {
+		delta := deltaOf(t, deltas, metric)
+		assert.True(t, delta.Missing, metric)
+		assert.True(t, delta.Regression, metric)
+	}
+
+	// the tps, the peak tps, the gas per second, the block time, the inclusion percentiles and the failed sends
+	assert.Equal(t, 8, Regressions(deltas))
+
+	// the runs without blocks have no block statistics
+	assert.Empty(t, Compare(Results{}, Results{}, tolerances))
+}
+
+func TestCompare_EmptyCandidate(t *testing.T) {
+	var tests = []struct {
+		name      string
+		candidate Results
+		missing   []string
+	}{
+		{
+			name: "No blocks",
+			candidate: Results{
+				Sent:      1000,
+				Latencies: []Latency{{Name: "inclusion", Count: 990, P50: 1000, P90: 2000, P99: 3000}},
+				Errors:    []Error{{Name: "failed sends", Count: 10, Rate: 1}},
+			},
+			missing: []string{"tps", "peak block tps", "rolling 10s tps min", "gas per second", "block time mean", "empty blocks", "gas used p50"},
+		},
+		{
+			name: "No included transactions",
+			candidate: Results{
+				Blocks:    testResults(100, 2, 3000, 1).Blocks,
+				Sent:      1000,
+				Latencies: []Latency{{Name: "inclusion"}},
+				Errors:    []Error{{Name: "failed sends", Count: 10, Rate: 1}},
+			},
+			missing: []string{"inclusion p50", "inclusion p90", "inclusion p99"},
+		},
+		{
+			name: "Error only in the baseline",
+			candidate: Results{
+				Blocks:    testResults(100, 2, 3000, 1).Blocks,
+				Sent:      1000,
+				Latencies: []Latency{{Name: "inclusion", Count: 990, P50: 1000, P90: 2000, P99: 3000}},
+			},
+			missing: []string{"failed sends"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			deltas := Compare(testResults(100, 2, 3000, 1), tc.candidate, tolerances)
+
+			var (
+				missing     = make([]string, 0)
+				regressions = 0
+			)
+
+			for _, delta := range deltas {
+				if delta.Missing {
+					missing = append(missing, delta.Metric)
+				}
+
+				// the missing metrics regress, unless they are only shown
+				if delta.Missing && delta.Kind != InfoKind {
+					assert.True(t, delta.Regression, delta.Metric)
+					regressions++
+				}
+			}
+
+			assert.ElementsMatch(t, tc.missing, missing)
+			assert.Equal(t, regressions, Regressions(deltas))
+		})
+	}
+}
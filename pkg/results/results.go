@@ -0,0 +1,116 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/tools/blockstats"
+	"github.com/ZeljkoBenovic/tpser/pkg/htmlreport"
+	"github.com/ZeljkoBenovic/tpser/pkg/output"
+)
+
+// Results are the saved results of a blocks-fetcher or long-sender run, the compare mode compares two of them
+type Results struct {
+	Mode     conf.Mode `json:"mode"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	Blocks blockstats.Stats `json:"blocks"`
+	// Sent is the number of transactions sent, the error rates are of them. It is zero if the run did not send any
+	Sent      int64     `json:"sent"`
+	Latencies []Latency `json:"latencies"`
+	Errors    []Error   `json:"errors"`
+}
+
+// Latency holds the percentiles of a latency distribution, in milliseconds
+type Latency struct {
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Error is a named number of errors, with its share of the sent transactions in percent
+type Error struct {
+	Name  string  `json:"name"`
+	Count int64   `json:"count"`
+	Rate  float64 `json:"rate_pct"`
+}
+
+// New returns the results of the run, with the rolling TPS of the blocks over windows of windowSec
+func New(run htmlreport.Run, windowSec int64) Results {
+	res := Results{
+		Mode:      run.Mode,
+		Started:   run.Started,
+		Finished:  run.Finished,
+		Blocks:    blockstats.Calculate(run.Blocks, windowSec),
+		Sent:      run.Sent,
+		Latencies: make([]Latency, 0, len(run.Latencies)),
+		Errors:    make([]Error, 0, len(run.Errors)),
+	}
+
+	for _, lat := range run.Latencies {
+		res.Latencies = append(res.Latencies, Latency{
+			Name:  lat.Name,
+			Count: lat.Count,
+			Mean:  millis(lat.Mean),
+			P50:   millis(lat.P50),
+			P90:   millis(lat.P90),
+			P99:   millis(lat.P99),
+			Max:   millis(lat.Max),
+		})
+	}
+
+	for _, count := range run.Errors {
+		errs := Error{Name: count.Name, Count: count.Value}
+
+		if run.Sent > 0 {
+			errs.Rate = float64(count.Value) / float64(run.Sent) * 100
+		}
+
+		res.Errors = append(res.Errors, errs)
+	}
+
+	return res
+}
+
+// Write writes the results to the file at the path, as indented JSON
+func Write(path string, res Results) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create results file: %w", err)
+	}
+
+	defer f.Close()
+
+	if err = output.JSON(f, res); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// Read reads the results from the file at the path
+func Read(path string) (Results, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Results{}, fmt.Errorf("could not read results file: %w", err)
+	}
+
+	var res Results
+	if err = json.Unmarshal(raw, &res); err != nil {
+		return Results{}, fmt.Errorf("could not decode results file %s: %w", path, err)
+	}
+
+	return res, nil
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
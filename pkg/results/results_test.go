@@ -0,0 +1,67 @@
+package results
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZeljkoBenovic/tpser/pkg/conf"
+	"github.com/ZeljkoBenovic/tpser/pkg/eth/types"
+	"github.com/ZeljkoBenovic/tpser/pkg/htmlreport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRun() htmlreport.Run {
+	return htmlreport.Run{
+		Mode:     conf.LongSender,
+		Started:  time.Unix(1000, 0).UTC(),
+		Finished: time.Unix(1100, 0).UTC(),
+		Blocks: []types.BlockInfo{
+			{Number: 1, Time: 100, TransactionNum: 10, GasLimit: 1000, GasUsed: 500},
+			{Number: 2, Time: 102, TransactionNum: 30, GasLimit: 1000, GasUsed: 700},
+		},
+		Sent: 200,
+		Errors: []htmlreport.Count{
+			{Name: "failed sends", Value: 4},
+			{Name: "not included", Value: 0},
+		},
+		Latencies: []htmlreport.Latency{
+			{Name: "inclusion", Count: 196, Mean: 1500 * time.Millisecond, P50: time.Second, P90: 2 * time.Second, P99: 3 * time.Second, Max: 4 * time.Second},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	res := New(testRun(), 10)
+
+	assert.Equal(t, conf.LongSender, res.Mode)
+	assert.Equal(t, 2, res.Blocks.Blocks)
+	assert.Equal(t, 20.0, res.Blocks.TPS)
+	assert.Equal(t, int64(200), res.Sent)
+
+	assert.Equal(t, []Latency{
+		{Name: "inclusion", Count: 196, Mean: 1500, P50: 1000, P90: 2000, P99: 3000, Max: 4000},
+	}, res.Latencies)
+
+	assert.Equal(t, []Error{
+		{Name: "failed sends", Count: 4, Rate: 2},
+		{Name: "not included", Count: 0, Rate: 0},
+	}, res.Errors)
+}
+
+func TestWriteRead(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), "results.json")
+		res  = New(testRun(), 10)
+	)
+
+	require.NoError(t, Write(path, res))
+
+	read, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, res, read)
+
+	_, err = Read(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}